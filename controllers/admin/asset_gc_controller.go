@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"errors"
+	"exam_server/services"
+	"exam_server/utils"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CollectOrphanedAssets 手动回收没有被商品或系列引用的文件，默认只返回报告不删除
+func CollectOrphanedAssets(c *gin.Context) {
+	var request struct {
+		DryRun *bool `json:"dry_run"` // 默认 true
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	dryRun := request.DryRun == nil || *request.DryRun
+	result, err := services.CollectOrphanedAssets(dryRun)
+	if err != nil {
+		if errors.Is(err, services.ErrAssetGCRunning) {
+			utils.ErrorResponse(c, "已有回收任务在运行，请稍后再试", http.StatusConflict)
+			return
+		}
+		log.Printf("回收孤立文件失败: %v", err)
+		utils.ErrorResponse(c, "回收孤立文件失败", http.StatusInternalServerError)
+		return
+	}
+
+	message := "孤立文件回收完成"
+	if dryRun {
+		message = "孤立文件检查完成"
+	}
+	utils.SuccessResponse(c, message, result)
+}
@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/utils"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuditLogsPaginated 分页查询审计日志，按时间从新到旧排列
+// 可以按操作人、对象类型、对象 ID、请求方法、路由、结果、IP 和时间范围过滤
+func GetAuditLogsPaginated(c *gin.Context) {
+	sysUserID, _ := strconv.ParseInt(c.Query("sys_user_id"), 10, 64)
+	entityType := c.Query("entity_type")
+	entityID, _ := strconv.ParseInt(c.Query("entity_id"), 10, 64)
+	method := c.Query("method")
+	route := c.Query("route")
+	success := c.Query("success")
+	ip := c.Query("ip")
+	startTime := c.Query("start_time")
+	endTime := c.Query("end_time")
+	currentPage, _ := strconv.Atoi(c.DefaultQuery("currentPage", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	offset := (currentPage - 1) * pageSize
+
+	dbQuery := config.DB.Model(&models.AuditLog{})
+	if sysUserID > 0 {
+		dbQuery = dbQuery.Where("sys_user_id = ?", sysUserID)
+	}
+	if entityType != "" {
+		dbQuery = dbQuery.Where("entity_type = ?", entityType)
+	}
+	if entityID > 0 {
+		dbQuery = dbQuery.Where("JSON_CONTAINS(entity_ids, ?)", strconv.FormatInt(entityID, 10))
+	}
+	if method != "" {
+		dbQuery = dbQuery.Where("method = ?", method)
+	}
+	if route != "" {
+		dbQuery = dbQuery.Where("route LIKE ?", "%"+route+"%")
+	}
+	if success == "true" || success == "false" {
+		dbQuery = dbQuery.Where("success = ?", success == "true")
+	}
+	if ip != "" {
+		dbQuery = dbQuery.Where("ip = ?", ip)
+	}
+	if startTime != "" {
+		dbQuery = dbQuery.Where("created_at >= ?", startTime)
+	}
+	if endTime != "" {
+		dbQuery = dbQuery.Where("created_at <= ?", endTime)
+	}
+
+	var total int64
+	if err := dbQuery.Count(&total).Error; err != nil {
+		log.Printf("获取审计日志总数失败: %v", err)
+		utils.ErrorResponse(c, "获取审计日志失败", http.StatusInternalServerError)
+		return
+	}
+
+	var auditLogs []models.AuditLog
+	if err := dbQuery.Order("id DESC").Limit(pageSize).Offset(offset).Find(&auditLogs).Error; err != nil {
+		log.Printf("获取审计日志失败: %v", err)
+		utils.ErrorResponse(c, "获取审计日志失败", http.StatusInternalServerError)
+		return
+	}
+
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+	utils.SuccessResponse(c, "获取审计日志成功", gin.H{
+		"audit_logs":  auditLogs,
+		"total":       total,
+		"currentPage": currentPage,
+		"pageSize":    pageSize,
+		"totalPages":  totalPages,
+	})
+}
@@ -4,11 +4,13 @@ import (
 	"errors"
 	"exam_server/config"
 	"exam_server/models"
+	"exam_server/services"
 	"exam_server/utils"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
 	"log"
 	"net/http"
 	"strconv"
@@ -92,7 +94,11 @@ func CreateBrand(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Create(&brand).Error; err != nil {
+	err := services.CreateWithRevision(models.EntityBrand, currentSysUserID(c), func(tx *gorm.DB) (int64, error) {
+		err := tx.Create(&brand).Error
+		return brand.ID, err
+	})
+	if err != nil {
 		// 检查是否是唯一约束冲突错误
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
@@ -113,13 +119,16 @@ func CreateBrand(c *gin.Context) {
 	utils.SuccessResponse(c, "Brand created successfully", brand)
 }
 
+// brandUpdateRequest 更新品牌的请求
+type brandUpdateRequest struct {
+	ID          int64  `json:"id" binding:"required"`   // 从请求体中获取品牌 ID
+	Name        string `json:"name" binding:"required"` // 其他要更新的字段
+	Description string `json:"description"`
+}
+
 // UpdateBrand 更新产品品牌
 func UpdateBrand(c *gin.Context) {
-	var request struct {
-		ID          int64  `json:"id" binding:"required"`   // 从请求体中获取品牌 ID
-		Name        string `json:"name" binding:"required"` // 其他要更新的字段
-		Description string `json:"description"`
-	}
+	var request brandUpdateRequest
 	// 绑定并验证请求数据
 	if err := c.ShouldBindJSON(&request); err != nil {
 		// 将错误信息格式化为用户友好的消息
@@ -136,6 +145,11 @@ func UpdateBrand(c *gin.Context) {
 		return
 	}
 
+	updateBrand(c, request, 0)
+}
+
+// updateBrand 保存品牌修改，restoredVersion 不为 0 时表示恢复到该历史版本
+func updateBrand(c *gin.Context, request brandUpdateRequest, restoredVersion int) {
 	var brand models.Brand
 	// 根据 ID 查询品牌
 	if err := config.DB.First(&brand, "id = ?", request.ID).Error; err != nil {
@@ -143,17 +157,21 @@ func UpdateBrand(c *gin.Context) {
 		utils.ErrorResponse(c, "Brand not found", http.StatusNotFound)
 		return
 	}
-
 	// 更新品牌信息
 	brand.Name = request.Name
 	brand.Description = request.Description
 
-	// 保存更新
-	if err := config.DB.Save(&brand).Error; err != nil {
+	// 保存更新，并在同一个事务中保存版本
+	err := services.UpdateWithRevision(models.EntityBrand, brand.ID, revisionMeta(c, restoredVersion), func(tx *gorm.DB) error {
+		return tx.Save(&brand).Error
+	})
+	if err != nil {
 		log.Printf("Failed to update brand: %v", err)
 		utils.ErrorResponse(c, "Failed to update brand", http.StatusInternalServerError)
 		return
 	}
+	// 商品的搜索索引包含品牌名称
+	services.ScheduleSearchIndexWhere("brand_id = ?", brand.ID)
 
 	utils.SuccessResponse(c, "Brand updated successfully", brand)
 }
@@ -177,11 +195,16 @@ func DeleteBrand(c *gin.Context) {
 		return
 	}
 
-	// 删除品牌
-	if err := config.DB.Delete(&brand).Error; err != nil {
+	// 删除品牌，并在同一个事务中保存删除前的版本
+	err := services.DeleteWithRevisions(models.EntityBrand, []int64{brand.ID}, currentSysUserID(c), func(tx *gorm.DB) error {
+		return tx.Delete(&brand).Error
+	})
+	if err != nil {
+		log.Printf("删除品牌 %d 失败: %v", brand.ID, err)
 		utils.ErrorResponse(c, "Failed to delete brand", http.StatusInternalServerError)
 		return
 	}
+	services.ScheduleSearchIndexWhere("brand_id = ?", brand.ID)
 
 	utils.SuccessResponse(c, "Brand soft-deleted successfully", nil)
 }
@@ -199,12 +222,21 @@ func DeleteBrandsBatch(c *gin.Context) {
 		return
 	}
 
-	// 执行软删除
-	if err := config.DB.Where("id IN ?", request.IDs).Delete(&models.Brand{}).Error; err != nil {
+	brandIDs := make([]int64, 0, len(request.IDs))
+	for _, id := range request.IDs {
+		brandIDs = append(brandIDs, int64(id))
+	}
+
+	// 执行软删除，并在同一个事务中保存删除前的版本
+	err := services.DeleteWithRevisions(models.EntityBrand, brandIDs, currentSysUserID(c), func(tx *gorm.DB) error {
+		return tx.Where("id IN ?", brandIDs).Delete(&models.Brand{}).Error
+	})
+	if err != nil {
 		log.Printf("Failed to delete brands: %v", err)
 		utils.ErrorResponse(c, "Failed to delete brands", http.StatusInternalServerError)
 		return
 	}
+	services.ScheduleSearchIndexWhere("brand_id IN ?", request.IDs)
 
 	utils.SuccessResponse(c, "Brands soft-deleted successfully", nil)
 }
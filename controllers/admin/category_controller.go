@@ -64,8 +64,12 @@ func CreateCategory(c *gin.Context) {
 		}
 	}
 
-	// 插入新分类
-	if err := config.DB.Create(&category).Error; err != nil {
+	// 插入新分类，并在同一个事务中保存第一个版本
+	err := services.CreateWithRevision(models.EntityCategory, currentSysUserID(c), func(tx *gorm.DB) (int64, error) {
+		err := tx.Create(&category).Error
+		return category.ID, err
+	})
+	if err != nil {
 		//// 检查是否是唯一约束冲突错误
 		//var mysqlErr *mysql.MySQLError
 		//if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
@@ -86,16 +90,19 @@ func CreateCategory(c *gin.Context) {
 	utils.SuccessResponse(c, "Category created successfully", category)
 }
 
+// categoryUpdateRequest 更新分类的请求
+type categoryUpdateRequest struct {
+	ID           int64  `json:"id" binding:"required"`   // 从请求体中获取分类 ID
+	Name         string `json:"name" binding:"required"` // 其他要更新的字段
+	Slug         string `json:"slug" binding:"required"` // 其他要更新的字段
+	Description  string `json:"description"`             // 其他要更新的字段
+	DisplayOrder int64  `json:"display_order"`
+	Pid          int64  `json:"pid"` // 父分类ID
+}
+
 // UpdateCategory 更新产品类别
 func UpdateCategory(c *gin.Context) {
-	var request struct {
-		ID           int64  `json:"id" binding:"required"`   // 从请求体中获取分类 ID
-		Name         string `json:"name" binding:"required"` // 其他要更新的字段
-		Slug         string `json:"slug" binding:"required"` // 其他要更新的字段
-		Description  string `json:"description"`             // 其他要更新的字段
-		DisplayOrder int64  `json:"display_order"`
-		Pid          int64  `json:"pid"` // 父分类ID
-	}
+	var request categoryUpdateRequest
 	// 绑定并验证请求数据
 	if err := c.ShouldBindJSON(&request); err != nil {
 		// 将错误信息格式化为用户友好的消息
@@ -112,6 +119,11 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	updateCategory(c, request, 0)
+}
+
+// updateCategory 保存分类修改，restoredVersion 不为 0 时表示恢复到该历史版本
+func updateCategory(c *gin.Context, request categoryUpdateRequest, restoredVersion int) {
 	var category models.Category
 	// 根据 ID 查询分类
 	if err := config.DB.First(&category, request.ID).Error; err != nil {
@@ -120,6 +132,18 @@ func UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	// 父分类必须存在，恢复历史版本时原来的父分类可能已被删除
+	if request.Pid != 0 {
+		if request.Pid == request.ID {
+			utils.ErrorResponse(c, "Parent category cannot be itself", http.StatusBadRequest)
+			return
+		}
+		var parentCategory models.Category
+		if err := config.DB.Select("id").First(&parentCategory, request.Pid).Error; err != nil {
+			utils.ErrorResponse(c, "Parent category not found", http.StatusBadRequest)
+			return
+		}
+	}
 	// 更新分类信息
 	category.Name = request.Name
 	category.Pid = request.Pid
@@ -127,12 +151,17 @@ func UpdateCategory(c *gin.Context) {
 	category.Description = request.Description
 	category.DisplayOrder = request.DisplayOrder
 
-	// 保存更新
-	if err := config.DB.Save(&category).Error; err != nil {
+	// 保存更新，并在同一个事务中保存版本
+	err := services.UpdateWithRevision(models.EntityCategory, category.ID, revisionMeta(c, restoredVersion), func(tx *gorm.DB) error {
+		return tx.Save(&category).Error
+	})
+	if err != nil {
 		log.Printf("Failed to update category: %v", err)
 		utils.ErrorResponse(c, "Failed to update category", http.StatusInternalServerError)
 		return
 	}
+	// 商品的搜索索引包含分类名称
+	services.ScheduleSearchIndexWhere("category_id = ?", category.ID)
 
 	utils.SuccessResponse(c, "Category updated successfully", category)
 }
@@ -156,11 +185,16 @@ func DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	// 删除分类
-	if err := config.DB.Delete(&category).Error; err != nil {
+	// 删除分类，并在同一个事务中保存删除前的版本
+	err := services.DeleteWithRevisions(models.EntityCategory, []int64{category.ID}, currentSysUserID(c), func(tx *gorm.DB) error {
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		log.Printf("删除分类 %d 失败: %v", category.ID, err)
 		utils.ErrorResponse(c, "Failed to delete category", http.StatusInternalServerError)
 		return
 	}
+	services.ScheduleSearchIndexWhere("category_id = ?", category.ID)
 
 	utils.SuccessResponse(c, "Category soft-deleted successfully", nil)
 }
@@ -0,0 +1,250 @@
+package admin
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/services"
+	"exam_server/utils"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// draftPublishers 各类草稿发布时写入线上数据的方法
+var draftPublishers = map[string]services.DraftPublisher{
+	models.EntityProduct: publishProductDraft,
+	models.EntitySeries:  publishSeriesDraft,
+}
+
+// contentPublishRoute 审核发布草稿的路由，拥有该路由权限（content_publish）的用户可以直接修改线上内容
+const contentPublishRoute = "/drafts/review/publish"
+
+// draftInvalidError 草稿内容校验失败，内容为返回给前端的提示
+type draftInvalidError string
+
+func (e draftInvalidError) Error() string {
+	return string(e)
+}
+
+// draftActionRequest 草稿操作的请求
+type draftActionRequest struct {
+	ID      int64  `json:"id" binding:"required"`
+	Comment string `json:"comment" binding:"max=500"` // 审核意见，只在发布和驳回时使用
+}
+
+// GetDraftsPaginated 分页查询草稿，可以按状态、对象类型和对象 ID 过滤
+func GetDraftsPaginated(c *gin.Context) {
+	status := c.Query("status")
+	entityType := c.Query("entity_type")
+	entityID, _ := strconv.ParseInt(c.Query("entity_id"), 10, 64)
+	currentPage, _ := strconv.Atoi(c.DefaultQuery("currentPage", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+	offset := (currentPage - 1) * pageSize
+
+	dbQuery := config.DB.Model(&models.ContentDraft{})
+	if status != "" {
+		dbQuery = dbQuery.Where("status = ?", status)
+	}
+	if entityType != "" {
+		dbQuery = dbQuery.Where("entity_type = ?", entityType)
+	}
+	if entityID > 0 {
+		dbQuery = dbQuery.Where("entity_id = ?", entityID)
+	}
+
+	var total int64
+	if err := dbQuery.Count(&total).Error; err != nil {
+		log.Printf("获取草稿总数失败: %v", err)
+		utils.ErrorResponse(c, "获取草稿列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	var drafts []models.ContentDraft
+	if err := dbQuery.Order("id DESC").Limit(pageSize).Offset(offset).Find(&drafts).Error; err != nil {
+		log.Printf("获取草稿列表失败: %v", err)
+		utils.ErrorResponse(c, "获取草稿列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+	utils.SuccessResponse(c, "获取草稿列表成功", gin.H{
+		"drafts":      drafts,
+		"total":       total,
+		"currentPage": currentPage,
+		"pageSize":    pageSize,
+		"totalPages":  totalPages,
+	})
+}
+
+// GetDraft 查询单个草稿，同时返回线上的数据方便审核时对比
+func GetDraft(c *gin.Context) {
+	var draft models.ContentDraft
+	if err := config.DB.First(&draft, c.Param("id")).Error; err != nil {
+		respondDraftError(c, err, "获取草稿失败")
+		return
+	}
+
+	var current interface{}
+	switch draft.EntityType {
+	case models.EntityProduct:
+		var product models.Product
+		if err := config.DB.First(&product, draft.EntityID).Error; err == nil {
+			current = product
+		}
+	case models.EntitySeries:
+		var series models.Series
+		if err := config.DB.First(&series, draft.EntityID).Error; err == nil {
+			series.PdfURL = services.PreviewAssetURL(series.PdfURL)
+			current = series
+		}
+	}
+
+	utils.SuccessResponse(c, "获取草稿成功", gin.H{
+		"draft":   draft,
+		"current": current,
+	})
+}
+
+// SubmitDraft 提交草稿等待审核
+func SubmitDraft(c *gin.Context) {
+	var request draftActionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	draft, err := services.SubmitDraft(request.ID, currentSysUserID(c))
+	if err != nil {
+		respondDraftError(c, err, "提交审核失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "已提交审核", draft)
+}
+
+// DeleteDraft 放弃未发布的草稿
+func DeleteDraft(c *gin.Context) {
+	var request draftActionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := services.DiscardDraft(request.ID); err != nil {
+		respondDraftError(c, err, "删除草稿失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "草稿已删除", nil)
+}
+
+// PublishDraft 审核通过并发布草稿，需要拥有审核发布权限的角色
+func PublishDraft(c *gin.Context) {
+	var request draftActionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var entityType string
+	if err := config.DB.Model(&models.ContentDraft{}).Where("id = ?", request.ID).Select("entity_type").Scan(&entityType).Error; err != nil {
+		respondDraftError(c, err, "发布草稿失败")
+		return
+	}
+	publish, ok := draftPublishers[entityType]
+	if !ok {
+		utils.ErrorResponse(c, "草稿不存在", http.StatusNotFound)
+		return
+	}
+
+	draft, err := services.PublishDraft(request.ID, currentSysUserID(c), request.Comment, publish)
+	if err != nil {
+		respondDraftError(c, err, "发布草稿失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "草稿已发布", draft)
+}
+
+// RejectDraft 驳回待审核的草稿，需要拥有审核发布权限的角色
+func RejectDraft(c *gin.Context) {
+	var request draftActionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	draft, err := services.RejectDraft(request.ID, currentSysUserID(c), request.Comment)
+	if err != nil {
+		respondDraftError(c, err, "驳回草稿失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "草稿已驳回", draft)
+}
+
+// respondDraftError 根据草稿操作的错误类型返回对应的状态码
+func respondDraftError(c *gin.Context, err error, message string) {
+	var invalid draftInvalidError
+	switch {
+	case errors.As(err, &invalid):
+		utils.ErrorResponse(c, invalid.Error(), http.StatusBadRequest)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorResponse(c, "草稿不存在", http.StatusNotFound)
+	case errors.Is(err, services.ErrDraftStatus):
+		utils.ErrorResponse(c, "草稿当前状态不允许该操作", http.StatusConflict)
+	default:
+		log.Printf("%s: %v", message, err)
+		utils.ErrorResponse(c, message, http.StatusInternalServerError)
+	}
+}
+
+// canPublishContent 当前用户是否拥有审核发布权限，使用个人访问令牌时还需要令牌的权限范围包含该权限
+func canPublishContent(c *gin.Context) (bool, error) {
+	allowed, err := services.HasSysPermission(currentSysUserID(c), http.MethodPost, contentPublishRoute)
+	if err != nil || !allowed {
+		return false, err
+	}
+	if value, isToken := c.Get("tokenAbilities"); isToken {
+		abilities, _ := value.([]string)
+		return services.AbilitiesAllow(abilities, http.MethodPost, contentPublishRoute)
+	}
+	return true, nil
+}
+
+// canEditLiveProduct 直接修改线上商品数据（图片、款式）前校验商品存在且未删除，并且当前用户拥有审核发布权限
+// 这些修改不经过草稿，立即在前台生效；校验失败时已写入错误响应
+func canEditLiveProduct(c *gin.Context, productID int64) bool {
+	if err := config.DB.Select("id").First(&models.Product{}, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, "Product not found", http.StatusNotFound)
+			return false
+		}
+		log.Printf("Failed to fetch product %d: %v", productID, err)
+		utils.ErrorResponse(c, "Failed to fetch product", http.StatusInternalServerError)
+		return false
+	}
+
+	allowed, err := canPublishContent(c)
+	if err != nil {
+		log.Printf("校验审核发布权限失败: %v", err)
+		utils.ErrorResponse(c, "权限校验失败", http.StatusInternalServerError)
+		return false
+	}
+	if !allowed {
+		utils.ErrorResponse(c, "修改会立即在前台生效，需要审核发布权限", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// currentSysUserID 当前登录的后台用户 ID
+func currentSysUserID(c *gin.Context) int64 {
+	userID, _ := c.Get("userID")
+	sysUserID, _ := userID.(int64)
+	return sysUserID
+}
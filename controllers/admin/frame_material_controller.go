@@ -4,11 +4,13 @@ import (
 	"errors"
 	"exam_server/config"
 	"exam_server/models"
+	"exam_server/services"
 	"exam_server/utils"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
 	"log"
 	"net/http"
 	"strconv"
@@ -94,7 +96,11 @@ func CreateFrameMaterial(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Create(&frameMaterial).Error; err != nil {
+	err := services.CreateWithRevision(models.EntityFrameMaterial, currentSysUserID(c), func(tx *gorm.DB) (int64, error) {
+		err := tx.Create(&frameMaterial).Error
+		return frameMaterial.ID, err
+	})
+	if err != nil {
 		// 检查是否是唯一约束冲突错误
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
@@ -115,13 +121,16 @@ func CreateFrameMaterial(c *gin.Context) {
 	utils.SuccessResponse(c, "FrameMaterial created successfully", frameMaterial)
 }
 
+// frameMaterialUpdateRequest 更新框材质的请求
+type frameMaterialUpdateRequest struct {
+	ID          int64  `json:"id" binding:"required"`   // 从请求体中获取框材质 ID
+	Name        string `json:"name" binding:"required"` // 其他要更新的字段
+	Description string `json:"description"`
+}
+
 // UpdateFrameMaterial 更新产品框材质
 func UpdateFrameMaterial(c *gin.Context) {
-	var request struct {
-		ID          int64  `json:"id" binding:"required"`   // 从请求体中获取框材质 ID
-		Name        string `json:"name" binding:"required"` // 其他要更新的字段
-		Description string `json:"description"`
-	}
+	var request frameMaterialUpdateRequest
 	// 绑定并验证请求数据
 	if err := c.ShouldBindJSON(&request); err != nil {
 		// 将错误信息格式化为框材质友好的消息
@@ -138,6 +147,11 @@ func UpdateFrameMaterial(c *gin.Context) {
 		return
 	}
 
+	updateFrameMaterial(c, request, 0)
+}
+
+// updateFrameMaterial 保存框材质修改，restoredVersion 不为 0 时表示恢复到该历史版本
+func updateFrameMaterial(c *gin.Context, request frameMaterialUpdateRequest, restoredVersion int) {
 	var frameMaterial models.FrameMaterial
 	// 根据 ID 查询框材质
 	if err := config.DB.First(&frameMaterial, "id = ?", request.ID).Error; err != nil {
@@ -145,17 +159,21 @@ func UpdateFrameMaterial(c *gin.Context) {
 		utils.ErrorResponse(c, "FrameMaterial not found", http.StatusNotFound)
 		return
 	}
-
 	// 更新框材质信息
 	frameMaterial.Name = request.Name
 	frameMaterial.Description = request.Description
 
-	// 保存更新
-	if err := config.DB.Save(&frameMaterial).Error; err != nil {
+	// 保存更新，并在同一个事务中保存版本
+	err := services.UpdateWithRevision(models.EntityFrameMaterial, frameMaterial.ID, revisionMeta(c, restoredVersion), func(tx *gorm.DB) error {
+		return tx.Save(&frameMaterial).Error
+	})
+	if err != nil {
 		log.Printf("Failed to update frame_material: %v", err)
 		utils.ErrorResponse(c, "Failed to update frame_material", http.StatusInternalServerError)
 		return
 	}
+	// 商品的搜索索引包含全部材质名称
+	services.ScheduleSearchIndexWhere(services.ProductMaterialCondition, frameMaterial.ID)
 
 	utils.SuccessResponse(c, "FrameMaterial updated successfully", frameMaterial)
 }
@@ -179,11 +197,16 @@ func DeleteFrameMaterial(c *gin.Context) {
 		return
 	}
 
-	// 删除框材质
-	if err := config.DB.Delete(&frameMaterial).Error; err != nil {
+	// 删除框材质，并在同一个事务中保存删除前的版本
+	err := services.DeleteWithRevisions(models.EntityFrameMaterial, []int64{frameMaterial.ID}, currentSysUserID(c), func(tx *gorm.DB) error {
+		return tx.Delete(&frameMaterial).Error
+	})
+	if err != nil {
+		log.Printf("删除框材质 %d 失败: %v", frameMaterial.ID, err)
 		utils.ErrorResponse(c, "Failed to delete frame_material", http.StatusInternalServerError)
 		return
 	}
+	services.ScheduleSearchIndexWhere(services.ProductMaterialCondition, frameMaterial.ID)
 
 	utils.SuccessResponse(c, "FrameMaterial soft-deleted successfully", nil)
 }
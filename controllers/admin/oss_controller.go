@@ -1,10 +1,10 @@
 package admin
 
 import (
+	"errors"
 	"exam_server/services"
 	"exam_server/utils"
 	"log"
-	"mime/multipart"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -12,7 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// 添加允许的文件类型常量
+// 添加允许的文件类型常量，具体的大小限制和内容校验见 services.ValidateUpload
 var (
 	allowedImageExts = map[string]bool{
 		".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
@@ -22,7 +22,13 @@ var (
 	}
 )
 
-// UploadFiles 支持多文件上传
+// failedUpload 上传失败的文件及原因
+type failedUpload struct {
+	Filename string `json:"filename"`
+	Error    string `json:"error"`
+}
+
+// UploadFiles 支持多文件上传，按文件内容校验类型，相同内容的文件只保存一份
 func UploadFiles(c *gin.Context) {
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -37,41 +43,51 @@ func UploadFiles(c *gin.Context) {
 		return
 	}
 
+	currentUserID, _ := c.Get("userID")
+	sysUserID, _ := currentUserID.(int64)
+
 	folderPath := c.DefaultPostForm("folder", "files")
 	var responses []string
-	var failedFiles []string
+	var deduplicated []string
+	var failedFiles []failedUpload
+	images := make([]gin.H, 0)
 
-	// 检查所有文件类型
 	for _, file := range files {
 		fileExt := strings.ToLower(filepath.Ext(file.Filename))
-		if !isAllowedFileType(fileExt) {
-			failedFiles = append(failedFiles, file.Filename)
+		stored, err := services.StoreUpload(file, uploadFolderFor(folderPath, fileExt), sysUserID)
+		if err != nil {
+			failedFiles = append(failedFiles, failedUpload{Filename: file.Filename, Error: uploadErrorMessage(err)})
+			var validationErr *services.UploadValidationError
+			if !errors.As(err, &validationErr) {
+				log.Printf("上传文件 %s 失败: %v", file.Filename, err)
+			}
 			continue
 		}
 
-		// 如果未指定文件夹，根据文件类型自动设置存储目录
-		uploadFolder := folderPath
-		if folderPath == "files" {
-			if _, isImage := allowedImageExts[fileExt]; isImage {
-				uploadFolder = "images"
-			} else if _, isDoc := allowedDocExts[fileExt]; isDoc {
-				uploadFolder = "pdfs"
-			}
+		fileURL := services.PreviewAssetURL(stored.Key)
+		responses = append(responses, fileURL)
+		if stored.Deduplicated {
+			deduplicated = append(deduplicated, fileURL)
 		}
 
-		fileURLs, err := services.UploadFilesToOSS([]*multipart.FileHeader{file}, uploadFolder)
-		if err != nil {
-			log.Printf("上传文件 %s 到OSS失败: %v", file.Filename, err)
-			failedFiles = append(failedFiles, file.Filename)
-			continue
+		// 图片会去掉元数据并生成缩略图等尺寸
+		if stored.Image != nil {
+			images = append(images, gin.H{
+				"url":      fileURL,
+				"width":    stored.Image.Width,
+				"height":   stored.Image.Height,
+				"variants": services.ImageVariantURLs(stored.Image.ProductImage(0)),
+			})
 		}
-
-		responses = append(responses, fileURLs[0])
 	}
 
 	// 返回上传结果
 	result := gin.H{
 		"success_files": responses,
+		"images":        images,
+	}
+	if len(deduplicated) > 0 {
+		result["deduplicated_files"] = deduplicated
 	}
 	if len(failedFiles) > 0 {
 		result["failed_files"] = failedFiles
@@ -80,13 +96,29 @@ func UploadFiles(c *gin.Context) {
 	if len(responses) > 0 {
 		utils.SuccessResponse(c, "文件上传完成", result)
 	} else {
-		utils.ErrorResponse(c, "所有文件上传失败", http.StatusBadRequest)
+		utils.ErrorResponse(c, "所有文件上传失败", http.StatusBadRequest, gin.H{"failed_files": failedFiles})
+	}
+}
+
+// uploadErrorMessage 校验失败时返回具体原因，其他错误不暴露细节
+func uploadErrorMessage(err error) string {
+	var validationErr *services.UploadValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Message
 	}
+	return "上传失败"
 }
 
-// 辅助函数：检查文件类型是否允许
-func isAllowedFileType(ext string) bool {
-	_, isImage := allowedImageExts[ext]
-	_, isDoc := allowedDocExts[ext]
-	return isImage || isDoc
+// uploadFolderFor 如果未指定文件夹，根据文件类型自动设置存储目录
+func uploadFolderFor(folderPath, ext string) string {
+	if folderPath != "files" {
+		return folderPath
+	}
+	if _, isImage := allowedImageExts[ext]; isImage {
+		return services.AssetFolderImages
+	}
+	if _, isDoc := allowedDocExts[ext]; isDoc {
+		return services.AssetFolderPDFs
+	}
+	return folderPath
 }
@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PdfDownloadReportItem 按系列汇总的下载统计
+type PdfDownloadReportItem struct {
+	SeriesID         int64      `json:"series_id"`
+	SeriesName       string     `json:"series_name"`
+	DownloadCount    int64      `json:"download_count"`     // 下载次数
+	UserCount        int64      `json:"user_count"`         // 下载用户数
+	LastDownloadedAt *time.Time `json:"last_downloaded_at"` // 最近一次下载时间
+}
+
+// GetPdfDownloadReport 系列PDF下载统计（分页），可按日期范围和系列筛选
+// start_date、end_date 格式为 2006-01-02，包含当天
+func GetPdfDownloadReport(c *gin.Context) {
+	seriesID := c.DefaultQuery("series_id", "")
+	startDate := c.DefaultQuery("start_date", "")
+	endDate := c.DefaultQuery("end_date", "")
+	currentPage, _ := strconv.Atoi(c.DefaultQuery("currentPage", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+
+	// 计算偏移量
+	offset := (currentPage - 1) * pageSize
+
+	// 构建查询
+	dbQuery := config.DB.Model(&models.PdfDownload{})
+
+	if seriesID != "" {
+		dbQuery = dbQuery.Where("pdf_downloads.series_id = ?", seriesID)
+	}
+	if startDate != "" {
+		start, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			utils.ErrorResponse(c, "start_date 格式应为 YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		dbQuery = dbQuery.Where("pdf_downloads.created_at >= ?", start)
+	}
+	if endDate != "" {
+		end, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			utils.ErrorResponse(c, "end_date 格式应为 YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		dbQuery = dbQuery.Where("pdf_downloads.created_at < ?", end.AddDate(0, 0, 1))
+	}
+
+	// 汇总数据
+	var summary struct {
+		DownloadCount int64 `json:"download_count"`
+		UserCount     int64 `json:"user_count"`
+		SeriesCount   int64 `json:"series_count"`
+	}
+	if err := dbQuery.Session(&gorm.Session{}).
+		Select("COUNT(*) AS download_count, COUNT(DISTINCT pdf_downloads.user_id) AS user_count, COUNT(DISTINCT pdf_downloads.series_id) AS series_count").
+		Scan(&summary).Error; err != nil {
+		log.Printf("获取PDF下载统计失败 %v\n", err)
+		utils.ErrorResponse(c, "获取PDF下载统计失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 按系列分页
+	report := make([]PdfDownloadReportItem, 0)
+	if err := dbQuery.
+		Select(`
+			pdf_downloads.series_id,
+			series.name AS series_name,
+			COUNT(*) AS download_count,
+			COUNT(DISTINCT pdf_downloads.user_id) AS user_count,
+			MAX(pdf_downloads.created_at) AS last_downloaded_at
+		`).
+		Joins("LEFT JOIN series ON pdf_downloads.series_id = series.id").
+		Group("pdf_downloads.series_id, series.name").
+		Order("download_count DESC").
+		Limit(pageSize).
+		Offset(offset).
+		Scan(&report).Error; err != nil {
+		log.Printf("获取PDF下载统计失败 %v\n", err)
+		utils.ErrorResponse(c, "获取PDF下载统计失败", http.StatusInternalServerError)
+		return
+	}
+
+	total := summary.SeriesCount
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize)) // 计算总页数
+
+	// 返回响应
+	utils.SuccessResponse(c, "获取PDF下载统计成功", gin.H{
+		"summary":     summary,
+		"report":      report,
+		"total":       total,
+		"currentPage": currentPage,
+		"pageSize":    pageSize,
+		"totalPages":  totalPages,
+	})
+}
@@ -0,0 +1,207 @@
+package admin
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/services"
+	"exam_server/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PersonalAccessTokenResponse 个人访问令牌返回结构，不包含令牌哈希
+type PersonalAccessTokenResponse struct {
+	ID         int64      `json:"id"`
+	SysUserID  int64      `json:"sys_user_id"`
+	Name       string     `json:"name"`
+	Abilities  []string   `json:"abilities"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// toPersonalAccessTokenResponse 转换为返回结构
+func toPersonalAccessTokenResponse(token models.PersonalAccessToken) PersonalAccessTokenResponse {
+	return PersonalAccessTokenResponse{
+		ID:         token.ID,
+		SysUserID:  token.TokenableID,
+		Name:       token.Name,
+		Abilities:  services.PersonalAccessTokenAbilities(&token),
+		LastUsedAt: token.LastUsedAt,
+		ExpiresAt:  token.ExpiresAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
+
+// CreatePersonalAccessToken 为后台用户创建个人访问令牌，明文令牌只在创建时返回一次
+func CreatePersonalAccessToken(c *gin.Context) {
+	var request struct {
+		SysUserID     int64    `json:"sys_user_id"` // 不传则为当前登录用户创建，只有超级管理员可以为其他用户创建
+		Name          string   `json:"name" binding:"required"`
+		Abilities     []string `json:"abilities" binding:"required,min=1"` // sys_permissions.name 列表，或 ["*"]
+		ExpiresInDays int      `json:"expires_in_days" binding:"min=0"`    // 0 表示永不过期
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 不允许使用个人访问令牌再创建令牌
+	if _, isToken := c.Get("personalAccessTokenID"); isToken {
+		utils.ErrorResponse(c, "个人访问令牌不能用于创建令牌", http.StatusForbidden)
+		return
+	}
+
+	currentUserID := currentSysUserID(c)
+	if request.SysUserID == 0 {
+		request.SysUserID = currentUserID
+	}
+	if !canManagePersonalAccessTokens(c, request.SysUserID) {
+		return
+	}
+
+	var sysUser models.SysUser
+	if err := config.DB.First(&sysUser, request.SysUserID).Error; err != nil {
+		utils.ErrorResponse(c, "SysUser not found", http.StatusNotFound)
+		return
+	}
+
+	var expiresAt *time.Time
+	if request.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, request.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	token, plainToken, err := services.CreatePersonalAccessToken(sysUser.ID, request.Name, request.Abilities, expiresAt)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTokenAbilities) {
+			utils.ErrorResponse(c, "权限范围必须是 * 或已存在的权限名称", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, services.ErrTokenAbilitiesNotGranted) {
+			utils.ErrorResponse(c, "权限范围超出了令牌所属用户拥有的权限", http.StatusForbidden)
+			return
+		}
+		log.Printf("创建个人访问令牌失败: %v", err)
+		utils.ErrorResponse(c, "创建个人访问令牌失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, "个人访问令牌创建成功，请妥善保存，令牌只显示一次", gin.H{
+		"token":                 plainToken,
+		"personal_access_token": toPersonalAccessTokenResponse(*token),
+	})
+}
+
+// GetPersonalAccessTokens 查询个人访问令牌（分页），超级管理员可以查询所有用户并按用户筛选，其他用户只能查询自己的令牌
+func GetPersonalAccessTokens(c *gin.Context) {
+	var tokens []models.PersonalAccessToken
+
+	// 获取查询参数
+	sysUserID, _ := strconv.ParseInt(c.DefaultQuery("sys_user_id", "0"), 10, 64)
+	currentPage, _ := strconv.Atoi(c.DefaultQuery("currentPage", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+
+	// 计算偏移量
+	offset := (currentPage - 1) * pageSize
+
+	// 构建查询
+	dbQuery := config.DB.Model(&models.PersonalAccessToken{}).
+		Where("tokenable_type = ?", services.PersonalAccessTokenTypeSysUser)
+
+	currentUserID := currentSysUserID(c)
+	superAdmin, err := services.IsSysSuperAdmin(currentUserID)
+	if err != nil {
+		log.Printf("加载后台用户 %d 的权限失败: %v", currentUserID, err)
+		utils.ErrorResponse(c, "获取个人访问令牌列表失败", http.StatusInternalServerError)
+		return
+	}
+	if !superAdmin {
+		sysUserID = currentUserID
+	}
+	if sysUserID != 0 {
+		dbQuery = dbQuery.Where("tokenable_id = ?", sysUserID)
+	}
+
+	var total int64
+	dbQuery.Count(&total)
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize)) // 计算总页数
+
+	// 执行查询并分页
+	if err := dbQuery.Order("id DESC").Limit(pageSize).Offset(offset).Find(&tokens).Error; err != nil {
+		log.Printf("获取个人访问令牌列表失败 %v\n", err)
+		utils.ErrorResponse(c, "获取个人访问令牌列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	responseTokens := make([]PersonalAccessTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		responseTokens = append(responseTokens, toPersonalAccessTokenResponse(token))
+	}
+
+	// 返回响应
+	utils.SuccessResponse(c, "获取个人访问令牌列表成功", gin.H{
+		"personal_access_tokens": responseTokens,
+		"total":                  total,
+		"currentPage":            currentPage,
+		"pageSize":               pageSize,
+		"totalPages":             totalPages,
+	})
+}
+
+// RevokePersonalAccessToken 注销个人访问令牌
+func RevokePersonalAccessToken(c *gin.Context) {
+	var request struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	var token models.PersonalAccessToken
+	if err := config.DB.Where("id = ? AND tokenable_type = ?", request.ID, services.PersonalAccessTokenTypeSysUser).
+		First(&token).Error; err != nil {
+		utils.ErrorResponse(c, "个人访问令牌不存在", http.StatusNotFound)
+		return
+	}
+	if !canManagePersonalAccessTokens(c, token.TokenableID) {
+		return
+	}
+
+	if err := config.DB.Delete(&token).Error; err != nil {
+		log.Printf("注销个人访问令牌 %d 失败: %v", token.ID, err)
+		utils.ErrorResponse(c, "注销个人访问令牌失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, "个人访问令牌已注销", nil)
+}
+
+// canManagePersonalAccessTokens 判断当前用户能否管理指定用户的令牌，只有超级管理员可以管理其他用户的令牌
+// 不能管理时已返回错误响应
+func canManagePersonalAccessTokens(c *gin.Context, sysUserID int64) bool {
+	currentUserID := currentSysUserID(c)
+	if sysUserID == currentUserID {
+		return true
+	}
+
+	superAdmin, err := services.IsSysSuperAdmin(currentUserID)
+	if err != nil {
+		log.Printf("加载后台用户 %d 的权限失败: %v", currentUserID, err)
+		utils.ErrorResponse(c, "权限校验失败", http.StatusInternalServerError)
+		return false
+	}
+	if !superAdmin {
+		utils.ErrorResponse(c, "只能管理自己的个人访问令牌", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -1,6 +1,8 @@
 package admin
 
 import (
+	"encoding/json"
+	"errors"
 	"exam_server/config"
 	"exam_server/models"
 	"exam_server/services"
@@ -8,13 +10,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/guregu/null/v5"
+	"gorm.io/gorm"
 )
 
 // ProductResponse 定义扁平化的响应结构
@@ -36,19 +38,22 @@ type ProductResponse struct {
 	BrandID           null.Int64 `json:"brand_id"`
 	BrandName         string     `json:"brand_name"`
 	FrameMaterialID   int64      `json:"frame_material_id"`
-	FrameMaterialName string     `json:"frame_material_name"`
+	FrameMaterialName string     `json:"frame_material_name"` // 主材质
 	CategoryPath      []int      `json:"category_path"`
 	Description       string     `json:"description"`
 
-	ImageURLs []string          `json:"image_urls"`
-	CreatedAt *models.LocalTime `json:"created_at"`
-	UpdatedAt *models.LocalTime `json:"updated_at"`
+	Materials     []services.ProductMaterialInfo        `json:"materials"` // 全部材质，主材质排在第一位
+	ImageURLs     []string                              `json:"image_urls"`
+	ImageVariants []map[string]services.ImageVariantURL `json:"image_variants"`     // 与 image_urls 一一对应
+	Images        []services.ProductImageInfo           `json:"images"`             // 图片 ID、排序、主图、替代文本和标签，与 image_urls 一一对应
+	Variants      []services.ProductVariantInfo         `json:"variants,omitempty"` // 颜色款式，只在商品详情中返回
+	CreatedAt     *models.LocalTime                     `json:"created_at"`
+	UpdatedAt     *models.LocalTime                     `json:"updated_at"`
 }
 
 // GetAllProductsPaginated 获取所有商品（支持分页）
 func GetAllProductsPaginated(c *gin.Context) {
 	// 获取查询参数 page 和 pageSize
-	queryStr := c.DefaultQuery("query", "")
 	pageStr := c.DefaultQuery("currentPage", "1")
 	pageSizeStr := c.DefaultQuery("pageSize", "10")
 
@@ -60,7 +65,13 @@ func GetAllProductsPaginated(c *gin.Context) {
 	var total int64
 
 	// 构建查询
-	query := config.DB.Model(&models.Product{})
+	query, searchResult := filterAdminProducts(c, config.DB.Model(&models.Product{}))
+	var highlights map[int64]map[string][]string
+	if searchResult != nil {
+		// 有搜索词时按相关度排序
+		query = query.Order(searchResult.OrderBy("products.id"))
+		highlights = searchResult.Highlights()
+	}
 
 	// 修改查询以预加载关联数据
 	query = query.
@@ -69,12 +80,6 @@ func GetAllProductsPaginated(c *gin.Context) {
 		Preload("Brand").
 		Preload("FrameMaterial")
 
-	// 如果 query 参数存在，则根据 ItemCode 或 Title 进行模糊搜索
-	if queryStr != "" {
-		searchPattern := queryStr + "%"
-		query = query.Where("item_code LIKE ? OR model_no LIKE ?", searchPattern, searchPattern)
-	}
-
 	// 获取总数
 	query.Count(&total)
 
@@ -84,22 +89,37 @@ func GetAllProductsPaginated(c *gin.Context) {
 
 	query.Limit(pageSize).Offset(offset).Find(&products)
 
-	// 从环境变量获取域名
-	ossBaseURL := os.Getenv("OSS_BASE_URL") // 例如 "https://jx-ebook.oss-cn-hongkong.aliyuncs.com"
+	// 批量查询每个产品的主图
+	productIDs := make([]int64, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+	primaryImages, err := services.PrimaryProductImages(productIDs)
+	if err != nil {
+		log.Printf("Failed to fetch primary images: %v", err)
+		utils.ErrorResponse(c, "Failed to fetch product images", http.StatusInternalServerError)
+		return
+	}
 
-	// 遍历每个产品，查找其主图
-	for i, product := range products {
-		var mainFile models.ProductImage
+	materials, err := services.ProductMaterials(productIDs)
+	if err != nil {
+		log.Printf("Failed to fetch product materials: %v", err)
+		utils.ErrorResponse(c, "Failed to fetch product materials", http.StatusInternalServerError)
+		return
+	}
 
-		// 获取主图
-		if err := config.DB.Where("product_id = ?", product.ID).First(&mainFile).Error; err != nil {
-			log.Println("Failed to fetch main file for product", product.ID, err)
+	imageVariants := make(map[int64][]map[string]services.ImageVariantURL, len(products))
+	images := make(map[int64][]services.ProductImageInfo, len(products))
+	for i, product := range products {
+		mainFile, exists := primaryImages[product.ID]
+		if !exists {
 			continue
 		}
 
-		// 构建完整的URL并附加到产品数据，添加 images/ 前缀
-		fullImageURL := fmt.Sprintf("%s/images/%s", ossBaseURL, mainFile.ImageURL)
-		products[i].ImageURLs = append(products[i].ImageURLs, fullImageURL)
+		// 构建完整的URL并附加到产品数据
+		products[i].ImageURLs = append(products[i].ImageURLs, services.AssetURL(mainFile.ImageURL))
+		imageVariants[product.ID] = append(imageVariants[product.ID], services.ImageVariantURLs(mainFile))
+		images[product.ID] = services.ProductImageInfos([]models.ProductImage{mainFile})
 	}
 
 	// 定义扁平化的响应结构
@@ -123,9 +143,13 @@ func GetAllProductsPaginated(c *gin.Context) {
 		FrameMaterialID   int64      `json:"frame_material_id"`
 		FrameMaterialName string     `json:"frame_material_name"`
 
-		ImageURLs []string          `json:"image_urls"`
-		CreatedAt *models.LocalTime `json:"created_at"`
-		UpdatedAt *models.LocalTime `json:"updated_at"`
+		Materials     []services.ProductMaterialInfo        `json:"materials"`
+		ImageURLs     []string                              `json:"image_urls"`
+		ImageVariants []map[string]services.ImageVariantURL `json:"image_variants"`       // 与 image_urls 一一对应
+		Images        []services.ProductImageInfo           `json:"images"`               // 主图的排序、替代文本等信息
+		Highlights    map[string][]string                   `json:"highlights,omitempty"` // 搜索时各字段中匹配的片段
+		CreatedAt     *models.LocalTime                     `json:"created_at"`
+		UpdatedAt     *models.LocalTime                     `json:"updated_at"`
 	}
 
 	// 初始化空数组，确保即使没有数据也会返回空数组而不是 null
@@ -153,9 +177,13 @@ func GetAllProductsPaginated(c *gin.Context) {
 			FrameMaterialID:   p.FrameMaterialID,
 			FrameMaterialName: getSafeString(p.FrameMaterial, "Name"),
 
-			ImageURLs: p.ImageURLs,
-			CreatedAt: p.CreatedAt,
-			UpdatedAt: p.UpdatedAt,
+			Materials:     materials[p.ID],
+			ImageURLs:     p.ImageURLs,
+			ImageVariants: imageVariants[p.ID],
+			Images:        images[p.ID],
+			Highlights:    highlights[p.ID],
+			CreatedAt:     p.CreatedAt,
+			UpdatedAt:     p.UpdatedAt,
 		}
 		responseProducts = append(responseProducts, response)
 	}
@@ -173,6 +201,45 @@ func GetAllProductsPaginated(c *gin.Context) {
 	utils.SuccessResponse(c, "Products fetched successfully", data)
 }
 
+// filterAdminProducts 根据后台商品列表的查询参数添加筛选条件，列表和导出共用
+// query 在搜索索引中全文搜索，返回的搜索结果用于按相关度排序，没有搜索词或索引不可用时为 nil；
+// 索引不可用时退回按货号或型号前缀匹配。category_id 同时匹配其子分类
+func filterAdminProducts(c *gin.Context, query *gorm.DB) (*gorm.DB, *services.ProductSearchResult) {
+	var searchResult *services.ProductSearchResult
+	if queryStr := strings.TrimSpace(c.Query("query")); queryStr != "" {
+		result, err := services.SearchProducts(queryStr)
+		if err == nil {
+			searchResult = result
+			query = query.Where("products.id IN ?", result.IDs())
+		} else {
+			if !errors.Is(err, services.ErrSearchUnavailable) {
+				log.Printf("Failed to search products: %v", err)
+			}
+			searchPattern := queryStr + "%"
+			query = query.Where("item_code LIKE ? OR model_no LIKE ?", searchPattern, searchPattern)
+		}
+	}
+
+	if categoryID := c.Query("category_id"); categoryID != "" {
+		childCategories := config.DB.Model(&models.Category{}).Select("id").Where("pid = ?", categoryID)
+		query = query.Where("category_id = ? OR category_id IN (?)", categoryID, childCategories)
+	}
+
+	if brandID := c.Query("brand_id"); brandID != "" {
+		query = query.Where("brand_id = ?", brandID)
+	}
+
+	if seriesID := c.Query("series_id"); seriesID != "" {
+		query = query.Where("series_id = ?", seriesID)
+	}
+
+	if materialID := c.Query("frame_material_id"); materialID != "" {
+		query = query.Where(services.ProductMaterialCondition, materialID)
+	}
+
+	return query, searchResult
+}
+
 // getSafeString 安全地获取关联对象的字符串属性
 func getSafeString(obj interface{}, field string) string {
 	if obj == nil {
@@ -229,19 +296,32 @@ func GetProduct(c *gin.Context) {
 
 	// 查询所有相关文件
 	var productFiles []models.ProductImage
-	if err := config.DB.Where("product_id = ?", product.ID).Find(&productFiles).Error; err != nil {
+	if err := config.DB.Where("product_id = ?", product.ID).Order(services.ProductImageOrder).Find(&productFiles).Error; err != nil {
 		utils.ErrorResponse(c, "Failed to fetch product files", http.StatusInternalServerError)
 		return
 	}
 
-	// 从环境变量获取域名
-	ossBaseURL := os.Getenv("OSS_BASE_URL")
+	variants, err := services.LoadProductVariants(product.ID)
+	if err != nil {
+		log.Printf("Failed to fetch product variants: %v", err)
+		utils.ErrorResponse(c, "Failed to fetch product variants", http.StatusInternalServerError)
+		return
+	}
+
+	materials, err := services.ProductMaterials([]int64{product.ID})
+	if err != nil {
+		log.Printf("Failed to fetch product materials: %v", err)
+		utils.ErrorResponse(c, "Failed to fetch product materials", http.StatusInternalServerError)
+		return
+	}
 
 	// 构建完整的图片URL列表
 	var imageURLs []string
+	var imageVariants []map[string]services.ImageVariantURL
 	for _, file := range productFiles {
-		fullImageURL := fmt.Sprintf("%s/images/%s", ossBaseURL, file.ImageURL)
+		fullImageURL := services.AssetURL(file.ImageURL)
 		imageURLs = append(imageURLs, fullImageURL)
+		imageVariants = append(imageVariants, services.ImageVariantURLs(file))
 	}
 
 	// 将图片URL列表和分类路径添加到product对象中
@@ -270,10 +350,14 @@ func GetProduct(c *gin.Context) {
 		FrameMaterialName: getSafeString(product.FrameMaterial, "Name"),
 		Description:       product.Description,
 
-		ImageURLs:    imageURLs,
-		CategoryPath: categoryPath,
-		CreatedAt:    product.CreatedAt,
-		UpdatedAt:    product.UpdatedAt,
+		Materials:     materials[product.ID],
+		ImageURLs:     imageURLs,
+		ImageVariants: imageVariants,
+		Images:        services.ProductImageInfos(productFiles),
+		Variants:      variants,
+		CategoryPath:  categoryPath,
+		CreatedAt:     product.CreatedAt,
+		UpdatedAt:     product.UpdatedAt,
 	}
 
 	// 直接返回product对象
@@ -284,7 +368,8 @@ func GetProduct(c *gin.Context) {
 func CreateProduct(c *gin.Context) {
 	var request struct {
 		models.Product
-		ImageURLs []string `json:"image_urls" binding:"required,min=1"`
+		ImageURLs        []string `json:"image_urls" binding:"required,min=1"`
+		FrameMaterialIDs []int64  `json:"frame_material_ids"` // 全部材质，frame_material_id 为主材质
 	}
 
 	// 参数验证
@@ -315,6 +400,13 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
+	materialIDs := services.NormalizeMaterialIDs(request.Product.FrameMaterialID, request.FrameMaterialIDs)
+	if err := services.ValidateMaterialIDs(materialIDs); err != nil {
+		utils.ErrorResponse(c, "Frame material not found", http.StatusBadRequest)
+		return
+	}
+	request.Product.FrameMaterialID = materialIDs[0]
+
 	// 如果指定了系列ID，验证框材质是否匹配
 	if !request.Product.SeriesID.IsZero() {
 		var series models.Series
@@ -323,8 +415,8 @@ func CreateProduct(c *gin.Context) {
 			return
 		}
 
-		if series.FrameMaterialID != request.Product.FrameMaterialID {
-			utils.ErrorResponse(c, "Frame material must match the series frame material", http.StatusBadRequest)
+		if !services.SeriesMaterialMatches(series.FrameMaterialID, materialIDs) {
+			utils.ErrorResponse(c, "Frame materials must include the series frame material", http.StatusBadRequest)
 			return
 		}
 	}
@@ -337,8 +429,9 @@ func CreateProduct(c *gin.Context) {
 		}
 	}()
 
-	// 创建产品
-	if err := tx.Create(&request.Product).Error; err != nil {
+	// 创建产品，sku_count 由款式数量计算
+	request.Product.SkuCount = 0
+	if err := tx.Omit("sku_count").Create(&request.Product).Error; err != nil {
 		tx.Rollback()
 		// 处理数据库错误
 		message, statusCode := utils.HandleMySQLError(err)
@@ -346,20 +439,27 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
-	// 处理图片
-	for _, imageURL := range request.ImageURLs {
-		filename := filepath.Base(imageURL)
-		productImage := models.ProductImage{
-			ProductID: null.IntFrom(int64(request.Product.ID)),
-			ImageURL:  filename,
-		}
+	if err := services.SetProductMaterials(tx, request.Product.ID, materialIDs); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to save product materials: %v", err)
+		utils.ErrorResponse(c, "Failed to save product materials", http.StatusInternalServerError)
+		return
+	}
 
-		if err := tx.Create(&productImage).Error; err != nil {
-			tx.Rollback()
-			log.Printf("Failed to create product image: %v", err)
-			utils.ErrorResponse(c, "Failed to create product images", http.StatusInternalServerError)
-			return
-		}
+	// 处理图片，按提交顺序排序，第一张作为主图
+	if err := services.AddProductImages(tx, request.Product.ID, imageKeysFromURLs(validImageUrls)); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to create product image: %v", err)
+		utils.ErrorResponse(c, "Failed to create product images", http.StatusInternalServerError)
+		return
+	}
+
+	if err := services.RecordRevision(tx, models.EntityProduct, request.Product.ID, models.RevisionCreate, nil,
+		services.RevisionMeta{SysUserID: currentSysUserID(c)}); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to record product revision: %v", err)
+		utils.ErrorResponse(c, "Failed to create product", http.StatusInternalServerError)
+		return
 	}
 
 	// 提交事务
@@ -369,6 +469,10 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
+	// 系列目录需要包含新商品
+	services.ScheduleSeriesCatalog(request.Product.SeriesID.Int64)
+	services.ScheduleSearchIndex(request.Product.ID)
+
 	// 重新查询完整的产品信息，包括关联数据
 	var product models.Product
 	if err := config.DB.
@@ -383,11 +487,9 @@ func CreateProduct(c *gin.Context) {
 	}
 
 	// 构建图片URL列表
-	ossBaseURL := os.Getenv("OSS_BASE_URL")
 	var imageURLs []string
 	for _, url := range request.ImageURLs {
-		filename := filepath.Base(url)
-		fullImageURL := fmt.Sprintf("%s/images/%s", ossBaseURL, filename)
+		fullImageURL := services.AssetURL(services.AssetKey(url, services.AssetFolderImages))
 		imageURLs = append(imageURLs, fullImageURL)
 	}
 	log.Printf("imageURLs: %v", imageURLs)
@@ -469,6 +571,10 @@ func CreateProduct(c *gin.Context) {
 
 	log.Printf("设置 FrameMaterial 字段完成")
 
+	if materials, err := services.ProductMaterials([]int64{product.ID}); err == nil {
+		response.Materials = materials[product.ID]
+	}
+
 	// 设置图片URLs和时间戳
 	response.ImageURLs = imageURLs
 	response.CreatedAt = product.CreatedAt
@@ -481,147 +587,166 @@ func CreateProduct(c *gin.Context) {
 	log.Printf("响应已发送")
 }
 
-// UpdateProduct 更新商品
+// productUpdateRequest 更新商品的请求，先保存为草稿，审核发布时再写入商品表
+type productUpdateRequest struct {
+	models.Product
+	ImageURLs        []string `json:"image_urls"`
+	DeletedImageURLs []string `json:"deleted_image_urls"`
+	FrameMaterialIDs []int64  `json:"frame_material_ids"`         // 全部材质，不传时保留原有的其他材质
+	RestoredVersion  int      `json:"restored_version,omitempty"` // 由历史版本生成的草稿，发布时覆盖全部字段
+}
+
+// UpdateProduct 保存商品的修改草稿，提交审核并发布后才会生效
 func UpdateProduct(c *gin.Context) {
-	var request struct {
-		models.Product
-		ImageURLs        []string `json:"image_urls"`
-		DeletedImageURLs []string `json:"deleted_image_urls"`
-	}
+	var request productUpdateRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 如果指定了系列ID，验证框材质是否匹配
-	if !request.Product.SeriesID.IsZero() {
-		var series models.Series
-		if err := config.DB.First(&series, request.Product.SeriesID).Error; err != nil {
-			utils.ErrorResponse(c, "框材质不存在", http.StatusBadRequest)
-			return
-		}
+	saveProductDraft(c, request)
+}
 
-		if series.FrameMaterialID != request.Product.FrameMaterialID {
-			utils.ErrorResponse(c, "产品框材质必须与系列框材质一致", http.StatusBadRequest)
-			return
-		}
+// saveProductDraft 校验并保存商品草稿，更新商品和恢复历史版本共用
+func saveProductDraft(c *gin.Context, request productUpdateRequest) {
+	if err := config.DB.Select("id").First(&models.Product{}, request.ID).Error; err != nil {
+		utils.ErrorResponse(c, "商品不存在", http.StatusNotFound)
+		return
 	}
 
-	// 开始事务
-	tx := config.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+	// 保存草稿时先校验一次，发布时会基于当时的数据再校验
+	check := request
+	if _, err := check.materialIDs(config.DB); err != nil {
+		respondDraftError(c, err, "更新产品信息失败")
+		return
+	}
 
-	// 1. 更新产品基本信息
-	if err := tx.Model(&models.Product{}).Where("id = ?", request.ID).Updates(request.Product).Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, "更新产品信息失败", http.StatusInternalServerError)
+	draft, err := services.SaveDraft(models.EntityProduct, request.ID, request, currentSysUserID(c))
+	if err != nil {
+		log.Printf("Failed to save product draft: %v", err)
+		utils.ErrorResponse(c, "保存草稿失败", http.StatusInternalServerError)
 		return
 	}
 
-	// 2. 处理要删除的图片
-	if len(request.DeletedImageURLs) > 0 {
-		// 从 OSS 删除文件
-		if err := services.DeleteFilesFromOSS(request.DeletedImageURLs); err != nil {
-			log.Printf("Warning: Failed to delete files from OSS: %v", err)
+	utils.SuccessResponse(c, "修改已保存为草稿，审核发布后生效", draft)
+}
+
+// materialIDs 校验框材质和系列，返回商品的全部材质，主材质排在第一位
+// 未传主材质时沿用原来的主材质；未传全部材质时保留原有的其他材质，原来的主材质被替换掉
+func (r *productUpdateRequest) materialIDs(db *gorm.DB) ([]int64, error) {
+	var currentMaterialID int64
+	db.Model(&models.Product{}).Where("id = ?", r.ID).Select("frame_material_id").Scan(&currentMaterialID)
+	if r.Product.FrameMaterialID == 0 {
+		r.Product.FrameMaterialID = currentMaterialID
+	}
+	otherMaterialIDs := r.FrameMaterialIDs
+	if otherMaterialIDs == nil {
+		existingIDs, err := services.ProductMaterialIDs(r.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch product materials: %v", err)
 		}
+		otherMaterialIDs = make([]int64, 0, len(existingIDs))
+		for _, id := range existingIDs {
+			if id != currentMaterialID {
+				otherMaterialIDs = append(otherMaterialIDs, id)
+			}
+		}
+	}
+	materialIDs := services.NormalizeMaterialIDs(r.Product.FrameMaterialID, otherMaterialIDs)
+	if err := services.ValidateMaterialIDs(materialIDs); err != nil {
+		return nil, draftInvalidError("框材质不存在")
+	}
 
-		// 从数据库删除记录
-		deleteFilenames := make([]string, 0, len(request.DeletedImageURLs))
-		for _, url := range request.DeletedImageURLs {
-			filename := filepath.Base(url)
-			deleteFilenames = append(deleteFilenames, filename)
+	// 如果指定了系列ID，验证框材质是否匹配
+	if !r.Product.SeriesID.IsZero() {
+		var series models.Series
+		if err := db.First(&series, r.Product.SeriesID).Error; err != nil {
+			return nil, draftInvalidError("系列不存在")
 		}
 
-		if err := tx.Where("product_id = ? AND image_url IN ?", request.ID, deleteFilenames).
-			Delete(&models.ProductImage{}).Error; err != nil {
-			tx.Rollback()
-			utils.ErrorResponse(c, "Failed to delete image records", http.StatusInternalServerError)
-			return
+		if !services.SeriesMaterialMatches(series.FrameMaterialID, materialIDs) {
+			return nil, draftInvalidError("产品框材质必须包含系列框材质")
 		}
 	}
 
-	// 3. 处理图片记录
-	// 3.1 获取现有图片记录
-	var existingImages []models.ProductImage
-	if err := tx.Where("product_id = ?", request.ID).Find(&existingImages).Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, "Failed to fetch existing images", http.StatusInternalServerError)
-		return
+	return materialIDs, nil
+}
+
+// productRestoreColumns 恢复历史版本时写入的商品字段
+var productRestoreColumns = []string{
+	"model_no", "frame_material_id", "lens_width", "nose_bridge", "temple_length", "title",
+	"description", "category_id", "series_id", "brand_id", "item_code", "gender",
+}
+
+// publishProductDraft 在发布事务中把商品草稿写入商品表、材质和图片
+func publishProductDraft(tx *gorm.DB, draft *models.ContentDraft) (func(), error) {
+	var request productUpdateRequest
+	if err := json.Unmarshal(draft.Payload, &request); err != nil {
+		return nil, fmt.Errorf("failed to decode product draft: %v", err)
 	}
+	request.ID = draft.EntityID
 
-	// 3.2 创建现有图片map，用于检查重复
-	existingImageMap := make(map[string]bool)
-	for _, img := range existingImages {
-		existingImageMap[img.ImageURL] = true
+	if err := tx.Select("id").First(&models.Product{}, request.ID).Error; err != nil {
+		return nil, draftInvalidError("商品不存在")
 	}
 
-	// 3.3 只添加新的图片记录
-	for _, imageURL := range request.ImageURLs {
-		filename := filepath.Base(imageURL)
-		// 如果图片已存在，跳过
-		if existingImageMap[filename] {
-			continue
-		}
+	materialIDs, err := request.materialIDs(tx)
+	if err != nil {
+		return nil, err
+	}
 
-		newImage := models.ProductImage{
-			ProductID: null.IntFrom(request.ID),
-			ImageURL:  filename,
-		}
-		if err := tx.Create(&newImage).Error; err != nil {
-			tx.Rollback()
-			utils.ErrorResponse(c, "Failed to create new image record", http.StatusInternalServerError)
-			return
-		}
+	before, err := services.LoadRevisionSnapshot(tx, models.EntityProduct, request.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, "Failed to commit transaction", http.StatusInternalServerError)
-		return
+	// 记录原来的系列，商品换系列时两个系列的目录都需要更新
+	var oldSeriesID, newSeriesID null.Int64
+	tx.Model(&models.Product{}).Where("id = ?", request.ID).Select("series_id").Scan(&oldSeriesID)
+
+	// 1. 更新产品基本信息，恢复历史版本时空值也要写入
+	update := tx.Model(&models.Product{}).Where("id = ?", request.ID)
+	if request.RestoredVersion != 0 {
+		update = update.Select(productRestoreColumns)
+	} else {
+		update = update.Omit("sku_count")
+	}
+	if err := update.Updates(request.Product).Error; err != nil {
+		return nil, fmt.Errorf("failed to update product: %v", err)
 	}
+	tx.Model(&models.Product{}).Where("id = ?", request.ID).Select("series_id").Scan(&newSeriesID)
 
-	// 重新加载产品信息
-	var product models.Product
-	config.DB.
-		Preload("Series").
-		Preload("Category").
-		Preload("Brand").
-		Preload("FrameMaterial").
-		First(&product, request.ID)
+	if err := services.SetProductMaterials(tx, request.ID, materialIDs); err != nil {
+		return nil, fmt.Errorf("failed to save product materials: %v", err)
+	}
 
-	// 构建响应
-	response := ProductResponse{
-		ID:           product.ID,
-		ModelNo:      product.ModelNO,
-		ItemCode:     product.ItemCode,
-		Title:        product.Title,
-		Gender:       product.Gender,
-		LensWidth:    product.LensWidth,
-		NoseBridge:   product.NoseBridge,
-		TempleLength: product.TempleLength,
+	// 2. 删除图片记录，相同内容的文件可能被其他商品或系列共用，文件由孤立文件回收任务清理
+	if len(request.DeletedImageURLs) > 0 {
+		deleteKeys := make([]string, 0, len(request.DeletedImageURLs))
+		for _, url := range request.DeletedImageURLs {
+			deleteKeys = append(deleteKeys, services.AssetKey(url, services.AssetFolderImages))
+		}
 
-		SeriesID:          product.SeriesID,
-		SkuCount:          product.SkuCount,
-		SeriesName:        getSafeString(product.Series, "Name"),
-		CategoryID:        product.CategoryID,
-		CategoryName:      getSafeString(product.Category, "Name"),
-		BrandID:           product.BrandID,
-		BrandName:         getSafeString(product.Brand, "Name"),
-		FrameMaterialID:   product.FrameMaterialID,
-		FrameMaterialName: getSafeString(product.FrameMaterial, "Name"),
+		if err := tx.Where("product_id = ? AND image_url IN ?", request.ID, deleteKeys).
+			Delete(&models.ProductImage{}).Error; err != nil {
+			return nil, fmt.Errorf("failed to delete image records: %v", err)
+		}
+	}
 
-		ImageURLs: request.ImageURLs,
-		CreatedAt: product.CreatedAt,
-		UpdatedAt: product.UpdatedAt,
+	// 3. 新图片追加到末尾，已存在的跳过；主图被删除时由排在最前的图片接替
+	if err := services.AddProductImages(tx, request.ID, imageKeysFromURLs(request.ImageURLs)); err != nil {
+		return nil, fmt.Errorf("failed to update product images: %v", err)
 	}
 
-	utils.SuccessResponse(c, "Product updated successfully", response)
+	if err := services.RecordRevision(tx, models.EntityProduct, request.ID, revisionAction(request.RestoredVersion), before, draftRevisionMeta(draft, request.RestoredVersion)); err != nil {
+		return nil, fmt.Errorf("failed to record product revision: %v", err)
+	}
+
+	return func() {
+		services.ScheduleSeriesCatalog(oldSeriesID.Int64, newSeriesID.Int64)
+		services.ScheduleSearchIndex(request.ID)
+	}, nil
 }
 
 // DeleteProduct 删除商品
@@ -635,55 +760,29 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	// 1. 先查询所有相关的图片记录
-	var productImages []models.ProductImage
-	if err := config.DB.Where("product_id = ?", request.ID).Find(&productImages).Error; err != nil {
-		utils.ErrorResponse(c, "Failed to fetch product images", http.StatusInternalServerError)
-		return
-	}
+	// 记录所属系列，删除后更新系列目录
+	var seriesID null.Int64
+	config.DB.Model(&models.Product{}).Where("id = ?", request.ID).Select("series_id").Scan(&seriesID)
 
-	// 2. 开始事务
-	tx := config.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	// 删除图片记录和商品，并在同一个事务中保存删除前的版本
+	err := services.DeleteWithRevisions(models.EntityProduct, []int64{request.ID}, currentSysUserID(c), func(tx *gorm.DB) error {
+		// 相同内容的文件可能被其他商品或系列共用，文件由孤立文件回收任务清理
+		if err := tx.Where("product_id = ?", request.ID).Delete(&models.ProductImage{}).Error; err != nil {
+			return fmt.Errorf("failed to delete product images: %v", err)
 		}
-	}()
-
-	// 2.1 删除图片记录
-	if err := tx.Where("product_id = ?", request.ID).Delete(&models.ProductImage{}).Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, "Failed to delete product images", http.StatusInternalServerError)
-		return
-	}
-
-	// 2.2 删除产品记录
-	if err := tx.Delete(&models.Product{}, request.ID).Error; err != nil {
-		tx.Rollback()
+		if err := services.DeleteProductVariants(tx, []int64{request.ID}); err != nil {
+			return err
+		}
+		return tx.Delete(&models.Product{}, request.ID).Error
+	})
+	if err != nil {
+		log.Printf("删除商品 %d 失败: %v", request.ID, err)
 		utils.ErrorResponse(c, "Failed to delete product", http.StatusInternalServerError)
 		return
 	}
 
-	// 2.3 提交事务
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, "Failed to commit transaction", http.StatusInternalServerError)
-		return
-	}
-
-	// 3. 删除 OSS 文件
-	if len(productImages) > 0 {
-		ossUrls := make([]string, 0, len(productImages))
-		ossBaseURL := os.Getenv("OSS_BASE_URL")
-		for _, img := range productImages {
-			ossUrls = append(ossUrls, fmt.Sprintf("%s/images/%s", ossBaseURL, img.ImageURL))
-		}
-
-		if err := services.DeleteFilesFromOSS(ossUrls); err != nil {
-			// 记录错误但不影响返回结果，因为数据库记录已经删除
-			log.Printf("Warning: Failed to delete files from OSS: %v", err)
-		}
-	}
+	services.ScheduleSeriesCatalog(seriesID.Int64)
+	services.ScheduleSearchIndex(request.ID)
 
 	utils.SuccessResponse(c, "Product deleted successfully", nil)
 }
@@ -701,12 +800,31 @@ func DeleteProductBatch(c *gin.Context) {
 		return
 	}
 
-	// 执行软删除
-	if err := config.DB.Where("id IN ?", request.IDs).Delete(&models.Product{}).Error; err != nil {
+	// 记录所属系列，删除后更新系列目录
+	var seriesIDs []int64
+	config.DB.Model(&models.Product{}).Where("id IN ? AND series_id IS NOT NULL", request.IDs).
+		Distinct().Pluck("series_id", &seriesIDs)
+
+	productIDs := make([]int64, 0, len(request.IDs))
+	for _, id := range request.IDs {
+		productIDs = append(productIDs, int64(id))
+	}
+
+	// 执行软删除，并在同一个事务中保存删除前的版本
+	err := services.DeleteWithRevisions(models.EntityProduct, productIDs, currentSysUserID(c), func(tx *gorm.DB) error {
+		if err := services.DeleteProductVariants(tx, productIDs); err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", productIDs).Delete(&models.Product{}).Error
+	})
+	if err != nil {
 		log.Printf("Failed to delete products: %v", err)
 		utils.ErrorResponse(c, "Failed to delete products", http.StatusInternalServerError)
 		return
 	}
 
+	services.ScheduleSeriesCatalog(seriesIDs...)
+	services.ScheduleSearchIndex(productIDs...)
+
 	utils.SuccessResponse(c, "商品信息批量删除成功", nil)
 }
@@ -0,0 +1,212 @@
+package admin
+
+import (
+	"encoding/csv"
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/services"
+	"exam_server/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guregu/null/v5"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// 每批查询的商品数量
+const productExportBatchSize = 500
+
+// productExportHeaders 导出的列，与 ProductResponse 的字段保持一致
+var productExportHeaders = []interface{}{
+	"id", "model_no", "item_code", "title", "gender", "lens_width", "nose_bridge", "temple_length",
+	"series_id", "series_name", "sku_count", "category_id", "category_name", "brand_id", "brand_name",
+	"frame_material_id", "frame_material_name", "description", "image_urls", "created_at", "updated_at",
+}
+
+// ExportProducts 按商品列表的筛选条件导出商品，format 可选 xlsx（默认）或 csv
+func ExportProducts(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "xlsx"))
+	if format != "xlsx" && format != "csv" {
+		utils.ErrorResponse(c, "format 只支持 xlsx 或 csv", http.StatusBadRequest)
+		return
+	}
+
+	query, _ := filterAdminProducts(c, config.DB.Model(&models.Product{}))
+	query = query.
+		Preload("Series").
+		Preload("Category").
+		Preload("Brand").
+		Preload("FrameMaterial")
+
+	filename := fmt.Sprintf("products_%s.%s", time.Now().Format("20060102150405"), format)
+
+	if format == "csv" {
+		exportProductsCSV(c, query, filename)
+		return
+	}
+	exportProductsXLSX(c, query, filename)
+}
+
+// exportProductsCSV 边查询边写入响应
+func exportProductsCSV(c *gin.Context, query *gorm.DB, filename string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Status(http.StatusOK)
+
+	// 写入 UTF-8 BOM，避免 Excel 打开中文乱码
+	if _, err := c.Writer.Write([]byte("\xef\xbb\xbf")); err != nil {
+		log.Printf("导出商品 CSV 失败: %v", err)
+		return
+	}
+
+	writer := csv.NewWriter(c.Writer)
+	writeRow := func(row []interface{}) error {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = fmt.Sprint(value)
+		}
+		return writer.Write(record)
+	}
+
+	if err := writeRow(productExportHeaders); err != nil {
+		log.Printf("导出商品 CSV 失败: %v", err)
+		return
+	}
+
+	// 响应头已发出，出错时只能记录日志并中断输出
+	if err := eachProductExportRow(query, writeRow); err != nil {
+		log.Printf("导出商品 CSV 失败: %v", err)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("导出商品 CSV 失败: %v", err)
+	}
+}
+
+// exportProductsXLSX 使用流式写入生成工作表，数据较多时由 excelize 暂存到临时文件
+func exportProductsXLSX(c *gin.Context, query *gorm.DB, filename string) {
+	f := excelize.NewFile()
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("关闭导出文件错误: %v", err)
+		}
+	}()
+
+	sheet := f.GetSheetName(0)
+	streamWriter, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		log.Printf("创建导出工作表失败: %v", err)
+		utils.ErrorResponse(c, "导出商品失败", http.StatusInternalServerError)
+		return
+	}
+
+	rowIndex := 1
+	writeRow := func(row []interface{}) error {
+		cell, err := excelize.CoordinatesToCellName(1, rowIndex)
+		if err != nil {
+			return err
+		}
+		rowIndex++
+		return streamWriter.SetRow(cell, row)
+	}
+
+	if err := writeRow(productExportHeaders); err != nil {
+		log.Printf("写入导出表头失败: %v", err)
+		utils.ErrorResponse(c, "导出商品失败", http.StatusInternalServerError)
+		return
+	}
+
+	if err := eachProductExportRow(query, writeRow); err != nil {
+		log.Printf("导出商品失败: %v", err)
+		utils.ErrorResponse(c, "导出商品失败", http.StatusInternalServerError)
+		return
+	}
+
+	if err := streamWriter.Flush(); err != nil {
+		log.Printf("导出商品失败: %v", err)
+		utils.ErrorResponse(c, "导出商品失败", http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Status(http.StatusOK)
+	if err := f.Write(c.Writer); err != nil {
+		log.Printf("写入导出文件失败: %v", err)
+	}
+}
+
+// eachProductExportRow 分批查询商品及其图片，逐行回调，避免一次加载整张表
+func eachProductExportRow(query *gorm.DB, fn func(row []interface{}) error) error {
+	var products []models.Product
+	return query.FindInBatches(&products, productExportBatchSize, func(tx *gorm.DB, batch int) error {
+		productIDs := make([]int64, 0, len(products))
+		for _, p := range products {
+			productIDs = append(productIDs, p.ID)
+		}
+
+		var productImages []models.ProductImage
+		if err := config.DB.Where("product_id IN ?", productIDs).Order("product_id, " + services.ProductImageOrder).Find(&productImages).Error; err != nil {
+			return err
+		}
+
+		imageURLs := make(map[int64][]string)
+		for _, image := range productImages {
+			productID := image.ProductID.Int64
+			imageURLs[productID] = append(imageURLs[productID], services.AssetURL(image.ImageURL))
+		}
+
+		for _, p := range products {
+			row := []interface{}{
+				p.ID,
+				p.ModelNO,
+				p.ItemCode.ValueOrZero(),
+				p.Title,
+				p.Gender.ValueOrZero(),
+				p.LensWidth,
+				p.NoseBridge,
+				p.TempleLength,
+				exportNullInt64(p.SeriesID),
+				getSafeString(p.Series, "Name"),
+				p.SkuCount,
+				p.CategoryID,
+				getSafeString(p.Category, "Name"),
+				exportNullInt64(p.BrandID),
+				getSafeString(p.Brand, "Name"),
+				p.FrameMaterialID,
+				getSafeString(p.FrameMaterial, "Name"),
+				p.Description,
+				strings.Join(imageURLs[p.ID], ","),
+				exportLocalTime(p.CreatedAt),
+				exportLocalTime(p.UpdatedAt),
+			}
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}).Error
+}
+
+// exportNullInt64 空值导出为空单元格
+func exportNullInt64(v null.Int64) interface{} {
+	if !v.Valid {
+		return ""
+	}
+	return v.Int64
+}
+
+// exportLocalTime 空值导出为空单元格
+func exportLocalTime(t *models.LocalTime) string {
+	if t == nil {
+		return ""
+	}
+	return t.String()
+}
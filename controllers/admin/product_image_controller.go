@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/services"
+	"exam_server/utils"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guregu/null/v5"
+	"gorm.io/gorm"
+)
+
+// ReorderProductImages 按传入的图片 ID 顺序重新排列商品图片，需要包含商品的全部图片
+func ReorderProductImages(c *gin.Context) {
+	var request struct {
+		ProductID int64   `json:"product_id" binding:"required"`
+		ImageIDs  []int64 `json:"image_ids" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	if !canEditLiveProduct(c, request.ProductID) {
+		return
+	}
+
+	if err := services.ReorderProductImages(request.ProductID, request.ImageIDs); err != nil {
+		if errors.Is(err, services.ErrInvalidImageOrder) {
+			utils.ErrorResponse(c, "图片列表与商品现有图片不一致", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Failed to reorder product images: %v", err)
+		utils.ErrorResponse(c, "图片排序失败", http.StatusInternalServerError)
+		return
+	}
+
+	respondProductImages(c, request.ProductID, "图片排序成功")
+}
+
+// SetPrimaryProductImage 设置商品主图
+func SetPrimaryProductImage(c *gin.Context) {
+	var request struct {
+		ProductID int64 `json:"product_id" binding:"required"`
+		ImageID   int64 `json:"image_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+	if !canEditLiveProduct(c, request.ProductID) {
+		return
+	}
+
+	if err := services.SetPrimaryProductImage(request.ProductID, request.ImageID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, "Image not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to set primary image: %v", err)
+		utils.ErrorResponse(c, "设置主图失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 系列目录使用商品主图
+	var seriesID null.Int64
+	config.DB.Model(&models.Product{}).Where("id = ?", request.ProductID).Select("series_id").Scan(&seriesID)
+	services.ScheduleSeriesCatalog(seriesID.Int64)
+
+	respondProductImages(c, request.ProductID, "设置主图成功")
+}
+
+// UpdateProductImage 修改商品图片的替代文本和颜色/角度标签
+func UpdateProductImage(c *gin.Context) {
+	var request struct {
+		ID      int64  `json:"id" binding:"required"`
+		AltText string `json:"alt_text" binding:"max=255"`
+		Color   string `json:"color" binding:"max=50"`
+		Angle   string `json:"angle" binding:"max=50"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	var productImage models.ProductImage
+	if err := config.DB.First(&productImage, request.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, "Image not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to fetch product image: %v", err)
+		utils.ErrorResponse(c, "修改图片信息失败", http.StatusInternalServerError)
+		return
+	}
+	if !productImage.ProductID.Valid {
+		utils.ErrorResponse(c, "Image not found", http.StatusNotFound)
+		return
+	}
+	if !canEditLiveProduct(c, productImage.ProductID.Int64) {
+		return
+	}
+
+	if err := config.DB.Model(&productImage).Updates(map[string]interface{}{
+		"alt_text": request.AltText,
+		"color":    request.Color,
+		"angle":    request.Angle,
+	}).Error; err != nil {
+		log.Printf("Failed to update product image: %v", err)
+		utils.ErrorResponse(c, "修改图片信息失败", http.StatusInternalServerError)
+		return
+	}
+
+	respondProductImages(c, productImage.ProductID.Int64, "修改图片信息成功")
+}
+
+// respondProductImages 返回商品按顺序排列的全部图片
+func respondProductImages(c *gin.Context, productID int64, message string) {
+	var productImages []models.ProductImage
+	if err := config.DB.Where("product_id = ?", productID).Order(services.ProductImageOrder).
+		Find(&productImages).Error; err != nil {
+		log.Printf("Failed to fetch product images: %v", err)
+		utils.ErrorResponse(c, "Failed to fetch product images", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, message, gin.H{"images": services.ProductImageInfos(productImages)})
+}
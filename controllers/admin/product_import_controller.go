@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"errors"
+	"exam_server/services"
+	"exam_server/utils"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportProducts 从 Excel/CSV 批量导入商品，按型号新建或更新
+// 表单字段 file 为导入文件；dry_run 默认为 true，只校验并返回每行的处理结果，传 false 时才写入数据库
+// 导入直接写入线上数据，更新已有商品需要审核发布权限，否则这些行作为错误返回
+func ImportProducts(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, "No file provided", http.StatusBadRequest)
+		return
+	}
+
+	dryRun, err := strconv.ParseBool(c.DefaultPostForm("dry_run", "true"))
+	if err != nil {
+		utils.ErrorResponse(c, "dry_run 参数无效", http.StatusBadRequest)
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		log.Printf("打开导入文件 %s 失败: %v", file.Filename, err)
+		utils.ErrorResponse(c, "Failed to open file", http.StatusBadRequest)
+		return
+	}
+	defer func() {
+		if err := src.Close(); err != nil {
+			log.Printf("关闭文件错误: %v", err)
+		}
+	}()
+
+	allowUpdates, err := canPublishContent(c)
+	if err != nil {
+		log.Printf("校验审核发布权限失败: %v", err)
+		utils.ErrorResponse(c, "权限校验失败", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := services.ImportProducts(file.Filename, src, dryRun, allowUpdates, currentSysUserID(c))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidImportFile) {
+			utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("导入商品失败: %v", err)
+		utils.ErrorResponse(c, "导入商品失败", http.StatusInternalServerError)
+		return
+	}
+
+	if dryRun {
+		utils.SuccessResponse(c, "导入数据校验完成", result)
+		return
+	}
+
+	// 存在错误的行时整个文件都不会写入
+	if result.Failed > 0 {
+		utils.ErrorResponseWithResult(c, "导入数据校验失败，未写入任何数据", http.StatusBadRequest, result)
+		return
+	}
+
+	utils.SuccessResponse(c, "商品导入成功", result)
+}
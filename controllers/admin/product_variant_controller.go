@@ -0,0 +1,217 @@
+package admin
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/services"
+	"exam_server/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// productVariantRequest 新增和修改款式的请求参数
+type productVariantRequest struct {
+	SkuCode     string   `json:"sku_code" binding:"required,max=100"`
+	ColorName   string   `json:"color_name" binding:"required,max=100"`
+	ColorSwatch string   `json:"color_swatch"` // 色卡图片地址
+	StockStatus string   `json:"stock_status" binding:"omitempty,oneof=in_stock low_stock out_of_stock"`
+	SortOrder   int      `json:"sort_order"`
+	ImageURLs   []string `json:"image_urls"` // 追加的款式图片
+}
+
+// GetProductVariants 获取商品的全部款式
+func GetProductVariants(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.ErrorResponse(c, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	variants, err := services.LoadProductVariants(productID)
+	if err != nil {
+		log.Printf("Failed to fetch product variants: %v", err)
+		utils.ErrorResponse(c, "获取款式失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, "获取款式成功", gin.H{"variants": variants})
+}
+
+// CreateProductVariant 新增商品款式，商品的 sku_count 自动更新
+func CreateProductVariant(c *gin.Context) {
+	var request struct {
+		ProductID int64 `json:"product_id" binding:"required"`
+		productVariantRequest
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !canEditLiveProduct(c, request.ProductID) {
+		return
+	}
+
+	variant := models.ProductVariant{ProductID: request.ProductID}
+	applyProductVariantRequest(&variant, request.productVariantRequest)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.CheckSkuCodeAvailable(tx, variant.SkuCode, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(&variant).Error; err != nil {
+			return err
+		}
+		if err := services.AddVariantImages(tx, variant.ProductID, variant.ID, imageKeysFromURLs(request.ImageURLs)); err != nil {
+			return err
+		}
+		return services.SyncProductSkuCount(tx, variant.ProductID)
+	})
+	if err != nil {
+		respondProductVariantError(c, err, "新增款式失败")
+		return
+	}
+
+	respondProductVariants(c, variant.ProductID, "新增款式成功")
+}
+
+// UpdateProductVariant 修改商品款式，image_urls 中的新图片追加到款式图片
+func UpdateProductVariant(c *gin.Context) {
+	var request struct {
+		ID int64 `json:"id" binding:"required"`
+		productVariantRequest
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var variant models.ProductVariant
+	if err := config.DB.First(&variant, request.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, "Variant not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to fetch product variant: %v", err)
+		utils.ErrorResponse(c, "修改款式失败", http.StatusInternalServerError)
+		return
+	}
+	if !canEditLiveProduct(c, variant.ProductID) {
+		return
+	}
+	applyProductVariantRequest(&variant, request.productVariantRequest)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.CheckSkuCodeAvailable(tx, variant.SkuCode, variant.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&variant).Select("sku_code", "color_name", "color_swatch", "stock_status", "sort_order").
+			Updates(&variant).Error; err != nil {
+			return err
+		}
+		return services.AddVariantImages(tx, variant.ProductID, variant.ID, imageKeysFromURLs(request.ImageURLs))
+	})
+	if err != nil {
+		respondProductVariantError(c, err, "修改款式失败")
+		return
+	}
+
+	respondProductVariants(c, variant.ProductID, "修改款式成功")
+}
+
+// DeleteProductVariant 删除商品款式，款式图片保留为商品通用图片
+func DeleteProductVariant(c *gin.Context) {
+	var request struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var variant models.ProductVariant
+	if err := config.DB.First(&variant, request.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, "Variant not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to fetch product variant: %v", err)
+		utils.ErrorResponse(c, "删除款式失败", http.StatusInternalServerError)
+		return
+	}
+	if !canEditLiveProduct(c, variant.ProductID) {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ProductImage{}).Where("variant_id = ?", variant.ID).
+			Update("variant_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&variant).Error; err != nil {
+			return err
+		}
+		return services.SyncProductSkuCount(tx, variant.ProductID)
+	})
+	if err != nil {
+		respondProductVariantError(c, err, "删除款式失败")
+		return
+	}
+
+	respondProductVariants(c, variant.ProductID, "删除款式成功")
+}
+
+// applyProductVariantRequest 将请求参数写入款式，色卡保存为对象路径
+func applyProductVariantRequest(variant *models.ProductVariant, request productVariantRequest) {
+	variant.SkuCode = strings.TrimSpace(request.SkuCode)
+	variant.ColorName = strings.TrimSpace(request.ColorName)
+	variant.ColorSwatch = services.AssetKey(request.ColorSwatch, services.AssetFolderImages)
+	variant.StockStatus = request.StockStatus
+	if variant.StockStatus == "" {
+		variant.StockStatus = models.StockStatusInStock
+	}
+	variant.SortOrder = request.SortOrder
+}
+
+// imageKeysFromURLs 将图片地址转换为对象路径
+func imageKeysFromURLs(imageURLs []string) []string {
+	keys := make([]string, 0, len(imageURLs))
+	for _, imageURL := range imageURLs {
+		if key := services.AssetKey(imageURL, services.AssetFolderImages); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// respondProductVariantError 货号重复时返回 400，其他错误按数据库错误处理
+func respondProductVariantError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrDuplicateSkuCode) {
+		utils.ErrorResponse(c, "款式货号已存在", http.StatusBadRequest)
+		return
+	}
+	log.Printf("%s: %v", message, err)
+	msg, statusCode := utils.HandleMySQLError(err)
+	utils.ErrorResponse(c, msg, statusCode)
+}
+
+// respondProductVariants 返回商品的全部款式
+func respondProductVariants(c *gin.Context, productID int64, message string) {
+	variants, err := services.LoadProductVariants(productID)
+	if err != nil {
+		log.Printf("Failed to fetch product variants: %v", err)
+		utils.ErrorResponse(c, "获取款式失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, message, gin.H{"variants": variants})
+}
@@ -0,0 +1,280 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/services"
+	"exam_server/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// revisionRestorers 各类对象恢复到历史版本的方法，按对象的更新接口校验并保存
+var revisionRestorers = map[string]func(c *gin.Context, revision *models.Revision){
+	models.EntityProduct:       restoreProductRevision,
+	models.EntitySeries:        restoreSeriesRevision,
+	models.EntityBrand:         restoreBrandRevision,
+	models.EntityCategory:      restoreCategoryRevision,
+	models.EntityFrameMaterial: restoreFrameMaterialRevision,
+}
+
+// GetRevisions 返回查询对象修订记录的接口，按版本从新到旧分页
+func GetRevisions(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			utils.ErrorResponse(c, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+		currentPage, _ := strconv.Atoi(c.DefaultQuery("currentPage", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+		offset := (currentPage - 1) * pageSize
+
+		dbQuery := config.DB.Model(&models.Revision{}).Where("entity_type = ? AND entity_id = ?", entityType, entityID)
+
+		var total int64
+		if err := dbQuery.Count(&total).Error; err != nil {
+			log.Printf("获取修订记录总数失败: %v", err)
+			utils.ErrorResponse(c, "获取修订记录失败", http.StatusInternalServerError)
+			return
+		}
+
+		var revisions []models.Revision
+		if err := dbQuery.Order("version DESC").Limit(pageSize).Offset(offset).Find(&revisions).Error; err != nil {
+			log.Printf("获取修订记录失败: %v", err)
+			utils.ErrorResponse(c, "获取修订记录失败", http.StatusInternalServerError)
+			return
+		}
+
+		totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+		utils.SuccessResponse(c, "获取修订记录成功", gin.H{
+			"revisions":   revisions,
+			"total":       total,
+			"currentPage": currentPage,
+			"pageSize":    pageSize,
+			"totalPages":  totalPages,
+		})
+	}
+}
+
+// RestoreRevision 返回恢复到历史版本的接口。商品和系列生成草稿，审核发布后生效；其他对象直接保存
+// 历史版本与更新接口一样校验，已删除的对象不能恢复
+func RestoreRevision(entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			ID      int64 `json:"id" binding:"required"`      // 对象 ID
+			Version int   `json:"version" binding:"required"` // 要恢复的版本号
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		revision, err := services.FindRevision(config.DB, entityType, request.ID, request.Version)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				utils.ErrorResponse(c, "版本不存在", http.StatusNotFound)
+				return
+			}
+			log.Printf("获取修订记录失败: %v", err)
+			utils.ErrorResponse(c, "获取修订记录失败", http.StatusInternalServerError)
+			return
+		}
+
+		var latest models.Revision
+		config.DB.Where("entity_type = ? AND entity_id = ?", entityType, request.ID).Order("version DESC").First(&latest)
+		if latest.Action == models.RevisionDelete {
+			utils.ErrorResponse(c, "对象已删除，不能恢复历史版本", http.StatusConflict)
+			return
+		}
+
+		revisionRestorers[entityType](c, revision)
+	}
+}
+
+// restoreProductRevision 按历史版本生成商品草稿，版本中没有的通用图片会在发布时删除
+func restoreProductRevision(c *gin.Context, revision *models.Revision) {
+	var request productUpdateRequest
+	if err := json.Unmarshal(revision.Snapshot, &request); err != nil {
+		log.Printf("解析修订记录 %d 失败: %v", revision.ID, err)
+		utils.ErrorResponse(c, "恢复历史版本失败", http.StatusInternalServerError)
+		return
+	}
+	request.ID = revision.EntityID
+	request.RestoredVersion = revision.Version
+
+	imageKeys := imageKeysFromURLs(request.ImageURLs)
+	if !restoredAssetsExist(c, imageKeys) {
+		return
+	}
+
+	var currentKeys []string
+	config.DB.Model(&models.ProductImage{}).Where("product_id = ? AND variant_id IS NULL", request.ID).Pluck("image_url", &currentKeys)
+	keep := make(map[string]bool, len(imageKeys))
+	for _, key := range imageKeys {
+		keep[key] = true
+	}
+	for _, key := range currentKeys {
+		if !keep[key] {
+			request.DeletedImageURLs = append(request.DeletedImageURLs, key)
+		}
+	}
+
+	if !validateRestoredRequest(c, &request) {
+		return
+	}
+	saveProductDraft(c, request)
+}
+
+// restoreSeriesRevision 按历史版本生成系列草稿，发布时间为空的字段会被清除
+func restoreSeriesRevision(c *gin.Context, revision *models.Revision) {
+	var snapshot struct {
+		Name            string  `json:"name"`
+		Description     string  `json:"description"`
+		PdfURL          string  `json:"pdf_url"`
+		FrameMaterialID int64   `json:"frame_material_id"`
+		AutoCatalog     bool    `json:"auto_catalog"`
+		VipFrom         *string `json:"vip_from"`
+		PublicFrom      *string `json:"public_from"`
+		UnpublishAt     *string `json:"unpublish_at"`
+	}
+	if err := json.Unmarshal(revision.Snapshot, &snapshot); err != nil {
+		log.Printf("解析修订记录 %d 失败: %v", revision.ID, err)
+		utils.ErrorResponse(c, "恢复历史版本失败", http.StatusInternalServerError)
+		return
+	}
+
+	if !restoredAssetsExist(c, []string{snapshot.PdfURL}) {
+		return
+	}
+
+	request := seriesUpdateRequest{
+		ID:              revision.EntityID,
+		Name:            snapshot.Name,
+		PdfURL:          snapshot.PdfURL,
+		Description:     snapshot.Description,
+		FrameMaterialID: snapshot.FrameMaterialID,
+		AutoCatalog:     &snapshot.AutoCatalog,
+		RestoredVersion: revision.Version,
+	}
+	request.VipFrom = restoredTime(snapshot.VipFrom)
+	request.PublicFrom = restoredTime(snapshot.PublicFrom)
+	request.UnpublishAt = restoredTime(snapshot.UnpublishAt)
+
+	if !validateRestoredRequest(c, &request) {
+		return
+	}
+	saveSeriesDraft(c, request)
+}
+
+// restoreBrandRevision 把品牌恢复到历史版本
+func restoreBrandRevision(c *gin.Context, revision *models.Revision) {
+	var request brandUpdateRequest
+	if err := json.Unmarshal(revision.Snapshot, &request); err != nil {
+		log.Printf("解析修订记录 %d 失败: %v", revision.ID, err)
+		utils.ErrorResponse(c, "恢复历史版本失败", http.StatusInternalServerError)
+		return
+	}
+	request.ID = revision.EntityID
+
+	if !validateRestoredRequest(c, &request) {
+		return
+	}
+	updateBrand(c, request, revision.Version)
+}
+
+// restoreCategoryRevision 把分类恢复到历史版本
+func restoreCategoryRevision(c *gin.Context, revision *models.Revision) {
+	var request categoryUpdateRequest
+	if err := json.Unmarshal(revision.Snapshot, &request); err != nil {
+		log.Printf("解析修订记录 %d 失败: %v", revision.ID, err)
+		utils.ErrorResponse(c, "恢复历史版本失败", http.StatusInternalServerError)
+		return
+	}
+	request.ID = revision.EntityID
+
+	if !validateRestoredRequest(c, &request) {
+		return
+	}
+	updateCategory(c, request, revision.Version)
+}
+
+// restoreFrameMaterialRevision 把框材质恢复到历史版本
+func restoreFrameMaterialRevision(c *gin.Context, revision *models.Revision) {
+	var request frameMaterialUpdateRequest
+	if err := json.Unmarshal(revision.Snapshot, &request); err != nil {
+		log.Printf("解析修订记录 %d 失败: %v", revision.ID, err)
+		utils.ErrorResponse(c, "恢复历史版本失败", http.StatusInternalServerError)
+		return
+	}
+	request.ID = revision.EntityID
+
+	if !validateRestoredRequest(c, &request) {
+		return
+	}
+	updateFrameMaterial(c, request, revision.Version)
+}
+
+// validateRestoredRequest 按更新接口的 binding 规则校验由历史版本生成的请求
+func validateRestoredRequest(c *gin.Context, request interface{}) bool {
+	if err := binding.Validator.ValidateStruct(request); err != nil {
+		utils.ErrorResponse(c, fmt.Sprintf("历史版本的数据未通过校验: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// restoredAssetsExist 检查历史版本引用的文件是否还在，超过保留期限的修订记录引用的旧文件会被孤立文件回收任务删除
+func restoredAssetsExist(c *gin.Context, keys []string) bool {
+	missing, err := services.MissingAssets(keys)
+	if err != nil {
+		log.Printf("检查历史版本的文件失败: %v", err)
+		utils.ErrorResponse(c, "恢复历史版本失败", http.StatusInternalServerError)
+		return false
+	}
+	if len(missing) > 0 {
+		utils.ErrorResponse(c, "历史版本引用的文件已被删除: "+strings.Join(missing, ", "), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// restoredTime 历史版本中为空的发布时间在恢复时需要清除
+func restoredTime(value *string) *string {
+	if value == nil {
+		empty := ""
+		return &empty
+	}
+	return value
+}
+
+// revisionMeta 直接修改对象时的修订记录信息，restoredVersion 不为 0 时表示恢复到该历史版本
+func revisionMeta(c *gin.Context, restoredVersion int) services.RevisionMeta {
+	return services.RevisionMeta{SysUserID: currentSysUserID(c), RestoredVersion: restoredVersion}
+}
+
+// revisionAction 恢复历史版本时记录为 restore，否则为 update
+func revisionAction(restoredVersion int) string {
+	if restoredVersion != 0 {
+		return models.RevisionRestore
+	}
+	return models.RevisionUpdate
+}
+
+// draftRevisionMeta 通过草稿发布时的修订记录信息，操作人为最后修改草稿的后台用户
+func draftRevisionMeta(draft *models.ContentDraft, restoredVersion int) services.RevisionMeta {
+	meta := services.RevisionMeta{DraftID: draft.ID, RestoredVersion: restoredVersion}
+	if draft.UpdatedBy != nil {
+		meta.SysUserID = *draft.UpdatedBy
+	}
+	return meta
+}
@@ -0,0 +1,30 @@
+package admin
+
+import (
+	"errors"
+	"exam_server/services"
+	"exam_server/utils"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RebuildSearchIndex 从数据库重建商品搜索索引，用于索引与数据库不一致时手动修复
+func RebuildSearchIndex(c *gin.Context) {
+	count, err := services.RebuildSearchIndex()
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSearchUnavailable):
+			utils.ErrorResponse(c, "搜索索引未初始化", http.StatusServiceUnavailable)
+		case errors.Is(err, services.ErrSearchRebuildRunning):
+			utils.ErrorResponse(c, "已有重建任务在运行，请稍后再试", http.StatusConflict)
+		default:
+			log.Printf("重建搜索索引失败: %v", err)
+			utils.ErrorResponse(c, "重建搜索索引失败", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, "搜索索引重建完成", gin.H{"count": count})
+}
@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"encoding/json"
 	"errors"
 	"exam_server/config"
 	"exam_server/models"
@@ -11,10 +12,13 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetAllSeries 获取所有产品系列
@@ -63,7 +67,10 @@ func GetAllSeriesPaginated(c *gin.Context) {
 		IsNewDesign       bool   `json:"is_new_design"`
 		FrameMaterialID   int64  `json:"frame_material_id"`
 		FrameMaterialName string `json:"frame_material_name"`
-		// ... 其他需要的字段
+
+		VipFrom     *models.LocalTime `json:"vip_from"`
+		PublicFrom  *models.LocalTime `json:"public_from"`
+		UnpublishAt *models.LocalTime `json:"unpublish_at"`
 	}
 
 	// 转换数据为扁平化结构
@@ -73,10 +80,14 @@ func GetAllSeriesPaginated(c *gin.Context) {
 			ID:                series.ID,
 			Name:              series.Name,
 			Description:       series.Description,
-			PdfURL:            series.PdfURL,
+			PdfURL:            services.PreviewAssetURL(series.PdfURL),
 			IsNewDesign:       series.IsNewDesign,
 			FrameMaterialID:   series.FrameMaterialID,
 			FrameMaterialName: series.FrameMaterial.Name,
+
+			VipFrom:     series.VipFrom,
+			PublicFrom:  series.PublicFrom,
+			UnpublishAt: series.UnpublishAt,
 		}
 	}
 
@@ -115,14 +126,23 @@ func GetSeries(c *gin.Context) {
 		PdfURL            string `json:"pdf_url"`
 		FrameMaterialID   int64  `json:"frame_material_id"`
 		FrameMaterialName string `json:"frame_material_name"`
-		// ... 其他需要的字段
+
+		IsNewDesign bool              `json:"is_new_design"`
+		VipFrom     *models.LocalTime `json:"vip_from"`
+		PublicFrom  *models.LocalTime `json:"public_from"`
+		UnpublishAt *models.LocalTime `json:"unpublish_at"`
 	}{
 		ID:                series.ID,
 		Name:              series.Name,
 		Description:       series.Description,
-		PdfURL:            series.PdfURL,
+		PdfURL:            services.PreviewAssetURL(series.PdfURL),
 		FrameMaterialID:   series.FrameMaterialID,
 		FrameMaterialName: series.FrameMaterial.Name,
+
+		IsNewDesign: series.IsNewDesign,
+		VipFrom:     series.VipFrom,
+		PublicFrom:  series.PublicFrom,
+		UnpublishAt: series.UnpublishAt,
 	}
 
 	utils.SuccessResponse(c, "Series fetched successfully", flattenedSeries)
@@ -136,7 +156,7 @@ func CreateSeries(c *gin.Context) {
 		Description     string `json:"description"`
 		PdfURL          string `json:"pdf_url" binding:"required"`
 		FrameMaterialID int64  `json:"frame_material_id" binding:"required"`
-		IsNewDesign     bool   `json:"is_new_design"` // 新增字段：是否为最新设计
+		seriesPublishRequest
 	}
 
 	// 绑定并验证请求数据
@@ -174,12 +194,23 @@ func CreateSeries(c *gin.Context) {
 	series := models.Series{
 		Name:            request.Name,
 		Description:     request.Description,
-		PdfURL:          request.PdfURL,
+		PdfURL:          services.AssetKey(request.PdfURL, services.AssetFolderPDFs),
 		FrameMaterialID: request.FrameMaterialID,
-		IsNewDesign:     request.IsNewDesign, // 设置新设计标志
 	}
 
-	if err := config.DB.Create(&series).Error; err != nil {
+	// 未设置发布时间时与原来一样立即公开
+	window, err := request.publishWindow(&series)
+	if err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	window.Apply(&series, true)
+
+	err = services.CreateWithRevision(models.EntitySeries, currentSysUserID(c), func(tx *gorm.DB) (int64, error) {
+		err := tx.Create(&series).Error
+		return series.ID, err
+	})
+	if err != nil {
 		// 检查是否是唯一约束冲突错误
 		var mysqlErr *mysql.MySQLError
 		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
@@ -190,20 +221,30 @@ func CreateSeries(c *gin.Context) {
 		utils.ErrorResponse(c, "Failed to create series", http.StatusInternalServerError)
 		return
 	}
-
+	series.PdfURL = services.PreviewAssetURL(series.PdfURL)
 	utils.SuccessResponse(c, "Series created successfully", series)
 }
 
-// UpdateSeries 更新产品系列
+// seriesUpdateRequest 更新系列的请求，先保存为草稿，审核发布时再写入系列表
+type seriesUpdateRequest struct {
+	ID              int64  `json:"id" binding:"required"`
+	Name            string `json:"name" binding:"required"`
+	PdfURL          string `json:"pdf_url"  binding:"required"`
+	Description     string `json:"description"`
+	FrameMaterialID int64  `json:"frame_material_id" binding:"required"`
+	AutoCatalog     *bool  `json:"auto_catalog"` // 不传则保持不变
+	seriesPublishRequest
+	RestoredVersion int `json:"restored_version,omitempty"` // 由历史版本生成的草稿
+
+	// 保存草稿时系列的 PDF 和自动生成设置，由服务端填写。发布时只有草稿相对这两个值做了修改才会覆盖，
+	// 避免草稿期间自动重新生成的目录被草稿中旧的 PDF 覆盖
+	BasePdfURL      *string `json:"base_pdf_url,omitempty"`
+	BaseAutoCatalog *bool   `json:"base_auto_catalog,omitempty"`
+}
+
+// UpdateSeries 保存系列的修改草稿，提交审核并发布后才会生效
 func UpdateSeries(c *gin.Context) {
-	var request struct {
-		ID              int64  `json:"id" binding:"required"`
-		Name            string `json:"name" binding:"required"`
-		PdfURL          string `json:"pdf_url"  binding:"required"`
-		Description     string `json:"description"`
-		FrameMaterialID int64  `json:"frame_material_id" binding:"required"`
-		IsNewDesign     bool   `json:"is_new_design"` // 新增字段：是否为最新设计
-	}
+	var request seriesUpdateRequest
 	// 绑定并验证请求数据
 	if err := c.ShouldBindJSON(&request); err != nil {
 		// 将错误信息格式化为用户友好的消息
@@ -220,6 +261,11 @@ func UpdateSeries(c *gin.Context) {
 		return
 	}
 
+	saveSeriesDraft(c, request)
+}
+
+// saveSeriesDraft 校验并保存系列草稿，更新系列和恢复历史版本共用
+func saveSeriesDraft(c *gin.Context, request seriesUpdateRequest) {
 	var series models.Series
 	// 根据 ID 查询系列
 	if err := config.DB.First(&series, "id = ?", request.ID).Error; err != nil {
@@ -228,37 +274,157 @@ func UpdateSeries(c *gin.Context) {
 		return
 	}
 
+	// 记录草稿基于的 PDF 和自动生成设置，继续编辑已有草稿时沿用草稿最初的值
+	request.BasePdfURL = &series.PdfURL
+	request.BaseAutoCatalog = &series.AutoCatalog
+	if request.RestoredVersion == 0 {
+		draft, err := services.FindOpenDraft(models.EntitySeries, request.ID)
+		if err != nil {
+			log.Printf("Failed to fetch series draft: %v", err)
+			utils.ErrorResponse(c, "保存草稿失败", http.StatusInternalServerError)
+			return
+		}
+		if draft != nil {
+			var previous seriesUpdateRequest
+			if err := json.Unmarshal(draft.Payload, &previous); err == nil && previous.BasePdfURL != nil {
+				request.BasePdfURL = previous.BasePdfURL
+				request.BaseAutoCatalog = previous.BaseAutoCatalog
+			}
+		}
+	}
+
+	// 保存草稿时先在副本上校验一次，发布时会基于当时的数据再校验
+	if _, err := request.apply(config.DB, &series); err != nil {
+		respondDraftError(c, err, "Failed to update series")
+		return
+	}
+
+	draft, err := services.SaveDraft(models.EntitySeries, request.ID, request, currentSysUserID(c))
+	if err != nil {
+		log.Printf("Failed to save series draft: %v", err)
+		utils.ErrorResponse(c, "保存草稿失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, "修改已保存为草稿，审核发布后生效", draft)
+}
+
+// apply 校验请求并写入 series，不保存。返回被替换掉的 PDF，没有替换时为空
+func (r *seriesUpdateRequest) apply(db *gorm.DB, series *models.Series) (string, error) {
 	// 验证frame_material是否存在
 	var frameMaterial models.FrameMaterial
-	if err := config.DB.First(&frameMaterial, request.FrameMaterialID).Error; err != nil {
-		utils.ErrorResponse(c, "Invalid frame material ID", http.StatusBadRequest)
-		return
+	if err := db.First(&frameMaterial, r.FrameMaterialID).Error; err != nil {
+		return "", draftInvalidError("Invalid frame material ID")
+	}
+
+	// 未传的发布时间保持不变
+	window, err := r.publishWindow(series)
+	if err != nil {
+		return "", draftInvalidError(err.Error())
 	}
 
-	// 如果PDF URL发生变化，删除旧文件
-	if series.PdfURL != "" && series.PdfURL != request.PdfURL {
-		if err := services.DeleteFilesFromOSS([]string{series.PdfURL}); err != nil {
-			log.Printf("Failed to delete old PDF file from OSS: %v", err)
+	// 数据库中保存对象路径
+	pdfKey := services.AssetKey(r.PdfURL, services.AssetFolderPDFs)
+
+	// 只有草稿修改了 PDF 时才替换，旧草稿没有记录基准时与当前数据对比
+	basePdf := series.PdfURL
+	if r.BasePdfURL != nil {
+		basePdf = *r.BasePdfURL
+	}
+	var replacedPdf string
+	if pdfKey != basePdf && pdfKey != series.PdfURL {
+		if series.PdfURL != "" {
+			replacedPdf = series.PdfURL
 		}
+		// 手动上传了新的 PDF 时关闭自动生成
+		series.PdfURL = pdfKey
+		series.AutoCatalog = false
+	}
+	if r.AutoCatalog != nil && (r.BaseAutoCatalog == nil || *r.AutoCatalog != *r.BaseAutoCatalog) {
+		series.AutoCatalog = *r.AutoCatalog
 	}
 
 	// 更新系列信息
-	series.Name = request.Name
-	series.Description = request.Description
-	series.PdfURL = request.PdfURL
-	series.FrameMaterialID = request.FrameMaterialID
-	series.IsNewDesign = request.IsNewDesign
-
-	fmt.Printf("series.PdfURL :%v\n", request.PdfURL)
-
-	// 保存更新
-	if err := config.DB.Save(&series).Error; err != nil {
-		log.Printf("Failed to update series: %v", err)
-		utils.ErrorResponse(c, "Failed to update series", http.StatusInternalServerError)
+	series.Name = r.Name
+	series.Description = r.Description
+	series.FrameMaterialID = r.FrameMaterialID
+	window.Apply(series, false)
+
+	return replacedPdf, nil
+}
+
+// publishSeriesDraft 在发布事务中把系列草稿写入系列表
+func publishSeriesDraft(tx *gorm.DB, draft *models.ContentDraft) (func(), error) {
+	var request seriesUpdateRequest
+	if err := json.Unmarshal(draft.Payload, &request); err != nil {
+		return nil, fmt.Errorf("failed to decode series draft: %v", err)
+	}
+	request.ID = draft.EntityID
+
+	var series models.Series
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&series, "id = ?", request.ID).Error; err != nil {
+		return nil, draftInvalidError("Series not found")
+	}
+
+	before, err := services.LoadRevisionSnapshot(tx, models.EntitySeries, series.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	replacedPdf, err := request.apply(tx, &series)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Save(&series).Error; err != nil {
+		return nil, fmt.Errorf("failed to update series: %v", err)
+	}
+
+	if err := services.RecordRevision(tx, models.EntitySeries, series.ID, revisionAction(request.RestoredVersion), before, draftRevisionMeta(draft, request.RestoredVersion)); err != nil {
+		return nil, fmt.Errorf("failed to record series revision: %v", err)
+	}
+
+	return func() {
+		// PDF 发生变化时删除带水印的副本，旧文件可能被其他系列共用，由孤立文件回收任务清理
+		if replacedPdf != "" {
+			if err := services.DeleteWatermarkedSeriesPDFs(series.ID); err != nil {
+				log.Printf("Failed to delete watermarked PDF files: %v", err)
+			}
+		}
+
+		// 系列名称、描述等会出现在目录中
+		if series.AutoCatalog {
+			services.ScheduleSeriesCatalog(series.ID)
+		}
+		// 商品的搜索索引包含系列名称
+		services.ScheduleSearchIndexWhere("series_id = ?", series.ID)
+	}, nil
+}
+
+// GenerateSeriesCatalog 根据系列下的商品生成目录PDF，之后商品变化时会自动重新生成
+func GenerateSeriesCatalog(c *gin.Context) {
+	var request struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, "Invalid request data", http.StatusBadRequest)
 		return
 	}
 
-	utils.SuccessResponse(c, "Series updated successfully", series)
+	series, err := services.GenerateSeriesCatalog(request.ID, currentSysUserID(c))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, "Series not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to generate catalog for series %d: %v", request.ID, err)
+		utils.ErrorResponse(c, "Failed to generate series catalog", http.StatusInternalServerError)
+		return
+	}
+
+	series.PdfURL = services.PreviewAssetURL(series.PdfURL)
+	utils.SuccessResponse(c, "系列目录PDF生成成功", series)
 }
 
 // DeleteSeries 删除产品系列
@@ -281,18 +447,23 @@ func DeleteSeries(c *gin.Context) {
 		return
 	}
 
-	// 删除OSS中的PDF文件
-	if series.PdfURL != "" {
-		if err := services.DeleteFilesFromOSS([]string{series.PdfURL}); err != nil {
-			log.Printf("Failed to delete PDF file from OSS: %v", err)
-		}
-	}
-
-	// 删除系列
-	if err := config.DB.Delete(&series).Error; err != nil {
+	// 删除系列，并在同一个事务中保存删除前的版本
+	err := services.DeleteWithRevisions(models.EntitySeries, []int64{series.ID}, currentSysUserID(c), func(tx *gorm.DB) error {
+		return tx.Delete(&series).Error
+	})
+	if err != nil {
+		log.Printf("删除系列 %d 失败: %v", series.ID, err)
 		utils.ErrorResponse(c, "Failed to delete series", http.StatusInternalServerError)
 		return
 	}
+	services.ScheduleSearchIndexWhere("series_id = ?", series.ID)
+
+	// 删除带水印的副本，PDF 文件可能被其他系列共用，由孤立文件回收任务清理
+	if series.PdfURL != "" {
+		if err := services.DeleteWatermarkedSeriesPDFs(series.ID); err != nil {
+			log.Printf("Failed to delete watermarked PDF files: %v", err)
+		}
+	}
 
 	utils.SuccessResponse(c, "Series soft-deleted successfully", nil)
 }
@@ -317,26 +488,86 @@ func DeleteSeriesBatch(c *gin.Context) {
 		return
 	}
 
-	// 收集所有需要删除的PDF URLs
-	var pdfURLs []string
+	seriesIDs := make([]int64, 0, len(seriesToDelete))
+	for _, series := range seriesToDelete {
+		seriesIDs = append(seriesIDs, series.ID)
+	}
+
+	// 执行软删除，并在同一个事务中保存删除前的版本
+	err := services.DeleteWithRevisions(models.EntitySeries, seriesIDs, currentSysUserID(c), func(tx *gorm.DB) error {
+		return tx.Where("id IN ?", request.IDs).Delete(&models.Series{}).Error
+	})
+	if err != nil {
+		log.Printf("Failed to delete series: %v", err)
+		utils.ErrorResponse(c, "Failed to delete series", http.StatusInternalServerError)
+		return
+	}
+	services.ScheduleSearchIndexWhere("series_id IN ?", request.IDs)
+
+	// 删除带水印的副本，PDF 文件可能被其他系列共用，由孤立文件回收任务清理
 	for _, series := range seriesToDelete {
 		if series.PdfURL != "" {
-			pdfURLs = append(pdfURLs, series.PdfURL)
+			if err := services.DeleteWatermarkedSeriesPDFs(series.ID); err != nil {
+				log.Printf("Failed to delete watermarked PDF files: %v", err)
+			}
 		}
 	}
 
-	// 批量删除OSS中的PDF文件
-	if len(pdfURLs) > 0 {
-		if err := services.DeleteFilesFromOSS(pdfURLs); err != nil {
-			log.Printf("Failed to delete PDF files from OSS: %v", err)
+	utils.SuccessResponse(c, "Series deleted successfully", nil)
+}
+
+// seriesPublishRequest 新建和更新系列时的发布时间，时间格式为 "2006-01-02 15:04:05"，空字符串表示清除
+type seriesPublishRequest struct {
+	VipFrom     *string `json:"vip_from"`      // VIP 用户可见的时间，为空时立即可见
+	PublicFrom  *string `json:"public_from"`   // 所有用户可见的时间，为空时只有 VIP 用户可见
+	UnpublishAt *string `json:"unpublish_at"`  // 下架时间，为空时不下架
+	IsNewDesign *bool   `json:"is_new_design"` // 兼容旧接口，未传 public_from 时生效：true 取消公开，false 立即公开
+}
+
+// publishWindow 在系列现有的发布时间上应用请求中传入的字段，新建的系列默认立即公开
+func (r seriesPublishRequest) publishWindow(series *models.Series) (services.SeriesPublishWindow, error) {
+	now := time.Now()
+	window := services.SeriesPublishWindow{
+		VipFrom:     (*time.Time)(series.VipFrom),
+		PublicFrom:  (*time.Time)(series.PublicFrom),
+		UnpublishAt: (*time.Time)(series.UnpublishAt),
+	}
+	if series.ID == 0 && r.PublicFrom == nil && (r.IsNewDesign == nil || !*r.IsNewDesign) {
+		window.PublicFrom = &now
+	}
+
+	fields := []struct {
+		name   string
+		value  *string
+		target **time.Time
+	}{
+		{"vip_from", r.VipFrom, &window.VipFrom},
+		{"public_from", r.PublicFrom, &window.PublicFrom},
+		{"unpublish_at", r.UnpublishAt, &window.UnpublishAt},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			continue
 		}
+		t, err := services.ParsePublishTime(*field.value)
+		if err != nil {
+			return window, fmt.Errorf("%s 格式应为 %s", field.name, services.SeriesPublishTimeLayout)
+		}
+		*field.target = t
 	}
 
-	// 执行软删除
-	if err := config.DB.Where("id IN ?", request.IDs).Delete(&models.Series{}).Error; err != nil {
-		utils.ErrorResponse(c, "Failed to delete series", http.StatusInternalServerError)
-		return
+	if r.PublicFrom == nil && r.IsNewDesign != nil && series.ID != 0 {
+		isPublic := window.PublicFrom != nil && !window.PublicFrom.After(now)
+		switch {
+		case *r.IsNewDesign && isPublic:
+			window.PublicFrom = nil
+		case !*r.IsNewDesign && !isPublic:
+			window.PublicFrom = &now
+		}
 	}
 
-	utils.SuccessResponse(c, "Series deleted successfully", nil)
+	if err := window.Validate(); err != nil {
+		return window, errors.New("VIP 可见时间不能晚于公开时间，下架时间必须晚于上架时间")
+	}
+	return window, nil
 }
@@ -83,6 +83,9 @@ func SysPermissionDelete(c *gin.Context) {
 		return
 	}
 
+	// 权限删除后清空权限缓存
+	services.InvalidateSysPermissionCache()
+
 	utils.SuccessResponse(c, "SysPermission deleted successfully", nil)
 }
 
@@ -127,6 +130,9 @@ func SysPermissionUpdate(c *gin.Context) {
 		return
 	}
 
+	// 路由或请求方法可能已变更，清空权限缓存
+	services.InvalidateSysPermissionCache()
+
 	// 返回成功响应
 	utils.SuccessResponse(c, "权限更新成功", sysPermission)
 }
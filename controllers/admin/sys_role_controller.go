@@ -3,6 +3,7 @@ package admin
 import (
 	"exam_server/config"
 	"exam_server/models"
+	"exam_server/services"
 	"exam_server/utils"
 	"github.com/gin-gonic/gin"
 	"log"
@@ -60,6 +61,9 @@ func SysRoleDelete(c *gin.Context) {
 		return
 	}
 
+	// 角色删除后清空权限缓存
+	services.InvalidateSysPermissionCache()
+
 	utils.SuccessResponse(c, "SysRole deleted successfully", nil)
 }
 
@@ -95,6 +99,9 @@ func SysRoleUpdate(c *gin.Context) {
 		return
 	}
 
+	// 角色变更后清空权限缓存
+	services.InvalidateSysPermissionCache()
+
 	// 返回成功响应
 	utils.SuccessResponse(c, "SysRole updated successfully", role)
 }
@@ -140,6 +147,9 @@ func SetSysRolePermissions(c *gin.Context) {
 		return
 	}
 
+	// 无论分配是否全部成功，都清空权限缓存
+	defer services.InvalidateSysPermissionCache()
+
 	// 删除当前管理员角色的所有权限
 	if err := config.DB.Debug().Where("sys_role_id = ?", uint(request.SysRoleID)).Delete(&models.SysRolePermission{}).Error; err != nil {
 		log.Printf("删除当前管理员角色的所有权限 %v", err)
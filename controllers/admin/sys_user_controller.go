@@ -1,8 +1,10 @@
 package admin
 
 import (
+	"errors"
 	"exam_server/config"
 	"exam_server/models"
+	"exam_server/services"
 	"exam_server/utils"
 	"fmt"
 	"github.com/google/uuid"
@@ -14,8 +16,6 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-var tokenBlacklist = make(map[string]bool)
-
 //// SysUserCreate 显示注册页面并生成验证码
 //func SysUserCreate(c *gin.Context) {
 //	captchaID := GenerateCaptchaID() // 使用封装好的生成函数
@@ -198,16 +198,25 @@ func SysUserLogin(c *gin.Context) {
 		return
 	}
 
-	// 生成 JWT Token
-	token, err := utils.GenerateJWT(user.ID)
+	// 检查账号状态，禁用的账号不能登录
+	if user.Status != 1 {
+		utils.ErrorResponse(c, "Account is disabled", http.StatusForbidden)
+		return
+	}
+
+	// 生成访问 Token 和刷新 Token
+	tokens, err := utils.IssueTokenPair(uint(user.ID), utils.UserTypeSysUser)
 	if err != nil {
+		log.Printf("生成 Token 失败: %v", err)
 		utils.ErrorResponse(c, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	// 登录成功，返回 token
 	data := gin.H{
-		"token": token,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"user": gin.H{
 			"email":    user.Email,
 			"username": user.Username,
@@ -216,6 +225,31 @@ func SysUserLogin(c *gin.Context) {
 	utils.SuccessResponse(c, "登录成功", data)
 }
 
+// RefreshToken - 使用刷新 Token 换取新的访问 Token
+func RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := utils.RotateRefreshToken(input.RefreshToken, utils.UserTypeSysUser)
+	if errors.Is(err, utils.ErrUserDisabled) {
+		utils.ErrorResponse(c, "Account is disabled", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("刷新 Token 失败: %v", err)
+		utils.ErrorResponse(c, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	utils.SuccessResponse(c, "刷新成功", tokens)
+}
+
 // RequestPasswordReset - 处理后台用户密码重置请求
 func RequestPasswordReset(c *gin.Context) {
 	var input struct {
@@ -315,14 +349,56 @@ func ResetPassword(c *gin.Context) {
 	// 删除 password_reset_tokens 表中的记录
 	config.DB.Delete(&resetTokenRecord)
 
+	// 密码重置后注销该用户的所有会话
+	if err := utils.RevokeAllUserTokens(utils.UserTypeSysUser, user.ID); err != nil {
+		log.Printf("注销后台用户 %d 的会话失败: %v", user.ID, err)
+	}
+
 	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{"message": "密码已成功重置"})
 }
 
-// Logout - 退出登录
+// Logout - 退出登录，注销当前 Token
 func Logout(c *gin.Context) {
-	// 清除客户端的 JWT Token （实际由前端来处理）
-	c.JSON(http.StatusOK, gin.H{"message": "Logout successful"})
+	value, _ := c.Get("claims")
+	claims, ok := value.(*utils.Claims)
+	if !ok {
+		utils.ErrorResponse(c, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := utils.RevokeToken(claims); err != nil {
+		log.Printf("注销 Token 失败: %v", err)
+		utils.ErrorResponse(c, "退出登录失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, "Logout successful", nil)
+}
+
+// SysUserRevokeSessions 注销指定后台用户的所有会话
+func SysUserRevokeSessions(c *gin.Context) {
+	var request struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	var user models.SysUser
+	if err := config.DB.First(&user, request.ID).Error; err != nil {
+		utils.ErrorResponse(c, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := utils.RevokeAllUserTokens(utils.UserTypeSysUser, user.ID); err != nil {
+		log.Printf("注销后台用户 %d 的会话失败: %v", user.ID, err)
+		utils.ErrorResponse(c, "注销会话失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, "该用户的所有会话已注销", nil)
 }
 
 // SysRoleResponse 用于返回角色的完整信息
@@ -490,6 +566,16 @@ func SysUserUpdate(c *gin.Context) {
 		return
 	}
 
+	// 用户角色可能已变更，清空权限缓存
+	services.InvalidateSysPermissionCache()
+
+	// 禁用用户时注销其所有会话
+	if sysUser.Status == 0 {
+		if err := utils.RevokeAllUserTokens(utils.UserTypeSysUser, sysUser.ID); err != nil {
+			log.Printf("注销后台用户 %d 的会话失败: %v", sysUser.ID, err)
+		}
+	}
+
 	// 返回成功响应
 	utils.SuccessResponse(c, "SysUser updated successfully", sysUser)
 }
@@ -531,6 +617,13 @@ func SysUserDelete(c *gin.Context) {
 		return
 	}
 
+	services.InvalidateSysPermissionCache()
+
+	// 删除用户后注销其所有会话
+	if err := utils.RevokeAllUserTokens(utils.UserTypeSysUser, user.ID); err != nil {
+		log.Printf("注销后台用户 %d 的会话失败: %v", user.ID, err)
+	}
+
 	//// 操作日志记录（可选）
 	//// 假设有一个日志记录表，记录用户操作
 	//if err := logAction(currentUserID.(uint), "delete_user", request.ID); err != nil {
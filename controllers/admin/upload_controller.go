@@ -0,0 +1,223 @@
+package admin
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/services"
+	"exam_server/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 直传签名的有效期，只需覆盖浏览器开始上传前的时间
+const presignedUploadLifetime = 30 * time.Minute
+
+// PresignUpload 签发浏览器直传到存储的签名，文件类型和大小与普通上传的限制一致
+func PresignUpload(c *gin.Context) {
+	var request struct {
+		Filename string `json:"filename" binding:"required"`
+		Size     int64  `json:"size"` // 文件大小，传入时提前校验
+		Folder   string `json:"folder"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	filename := services.SanitizeFilename(request.Filename)
+	fileExt := strings.ToLower(filepath.Ext(filename))
+	contentType, allowed := services.UploadContentType(fileExt)
+	if !allowed {
+		utils.ErrorResponse(c, "不支持的文件类型", http.StatusBadRequest)
+		return
+	}
+
+	maxSize := services.UploadMaxSize(fileExt)
+	if request.Size > maxSize {
+		utils.ErrorResponse(c, fmt.Sprintf("文件不能超过 %dMB", maxSize>>20), http.StatusBadRequest)
+		return
+	}
+
+	folderPath := request.Folder
+	if folderPath == "" {
+		folderPath = "files"
+	}
+	objectKey := services.NewUploadKey(uploadFolderFor(folderPath, fileExt), filename)
+
+	presigned, err := services.GetStorage().PresignUpload(objectKey, contentType, maxSize, presignedUploadLifetime)
+	if err != nil {
+		log.Printf("签发直传签名失败: %v", err)
+		utils.ErrorResponse(c, "签发上传签名失败", http.StatusInternalServerError)
+		return
+	}
+
+	currentUserID, _ := c.Get("userID")
+	sysUserID, _ := currentUserID.(int64)
+	upload := models.Upload{
+		SysUserID:   sysUserID,
+		ObjectKey:   objectKey,
+		Filename:    filename,
+		ContentType: contentType,
+		MaxSize:     maxSize,
+		Status:      models.UploadStatusPending,
+	}
+	if err := config.DB.Create(&upload).Error; err != nil {
+		log.Printf("创建直传记录失败: %v", err)
+		utils.ErrorResponse(c, "签发上传签名失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, "签发上传签名成功", gin.H{
+		"upload_id": upload.ID,
+		"key":       objectKey,
+		"url":       services.PreviewAssetURL(objectKey),
+		"upload":    presigned,
+	})
+}
+
+// CompleteUpload 浏览器直传完成后回调，确认文件已存在并按内容校验，图片会生成各尺寸
+// 已经上传过相同内容的文件时返回已有文件
+func CompleteUpload(c *gin.Context) {
+	var request struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, "Invalid request data", http.StatusBadRequest)
+		return
+	}
+
+	currentUserID, _ := c.Get("userID")
+	var upload models.Upload
+	if err := config.DB.Where("id = ? AND sys_user_id = ?", request.ID, currentUserID).First(&upload).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, "Upload not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to fetch upload: %v", err)
+		utils.ErrorResponse(c, "确认上传失败", http.StatusInternalServerError)
+		return
+	}
+
+	result := gin.H{
+		"id":  upload.ID,
+		"key": upload.ObjectKey,
+		"url": services.PreviewAssetURL(upload.ObjectKey),
+	}
+
+	// 重复回调直接返回结果
+	if upload.Status == models.UploadStatusCompleted {
+		result["size"] = upload.Size
+		utils.SuccessResponse(c, "文件上传完成", result)
+		return
+	}
+
+	info, err := services.GetStorage().Stat(upload.ObjectKey)
+	if err != nil {
+		if errors.Is(err, services.ErrObjectNotFound) {
+			utils.ErrorResponse(c, "文件尚未上传", http.StatusBadRequest)
+			return
+		}
+		log.Printf("获取直传文件 %s 信息失败: %v", upload.ObjectKey, err)
+		utils.ErrorResponse(c, "确认上传失败", http.StatusInternalServerError)
+		return
+	}
+
+	// 签名已限制类型和大小，这里再次校验，不符合时删除文件
+	if info.Size > upload.MaxSize || (info.ContentType != "" && info.ContentType != upload.ContentType) {
+		if err := services.DeleteFiles([]string{upload.ObjectKey}); err != nil {
+			log.Printf("删除不符合限制的直传文件失败: %v", err)
+		}
+		utils.ErrorResponse(c, "文件类型或大小不符合要求", http.StatusBadRequest)
+		return
+	}
+
+	// 按文件内容再次校验，签名无法限制文件的实际内容
+	validated, err := services.ValidateStoredObject(upload.ObjectKey)
+	if err != nil {
+		var validationErr *services.UploadValidationError
+		if !errors.As(err, &validationErr) {
+			log.Printf("校验直传文件 %s 失败: %v", upload.ObjectKey, err)
+			utils.ErrorResponse(c, "确认上传失败", http.StatusInternalServerError)
+			return
+		}
+		if err := services.DeleteFiles([]string{upload.ObjectKey}); err != nil {
+			log.Printf("删除不符合要求的直传文件失败: %v", err)
+		}
+		utils.ErrorResponse(c, validationErr.Message, http.StatusBadRequest)
+		return
+	}
+
+	// 已经上传过相同内容的文件时删除本次上传的文件和记录，返回已有文件
+	existing, err := services.FindUploadByHash(validated.SHA256)
+	if err != nil {
+		log.Printf("查找重复文件失败: %v", err)
+		utils.ErrorResponse(c, "确认上传失败", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		if err := services.DeleteFiles([]string{upload.ObjectKey}); err != nil {
+			log.Printf("删除重复的直传文件失败: %v", err)
+		}
+		if err := config.DB.Delete(&upload).Error; err != nil {
+			log.Printf("Failed to delete upload: %v", err)
+		}
+
+		result = gin.H{
+			"id":           existing.ID,
+			"key":          existing.ObjectKey,
+			"url":          services.PreviewAssetURL(existing.ObjectKey),
+			"size":         existing.Size,
+			"deduplicated": true,
+		}
+		if _, isImage := allowedImageExts[validated.Ext]; isImage {
+			described, err := services.DescribeImage(existing.ObjectKey)
+			if err != nil {
+				log.Printf("读取图片 %s 信息失败: %v", existing.ObjectKey, err)
+				utils.ErrorResponse(c, "确认上传失败", http.StatusInternalServerError)
+				return
+			}
+			result["width"] = described.Width
+			result["height"] = described.Height
+			result["variants"] = services.ImageVariantURLs(described.ProductImage(0))
+		}
+		utils.SuccessResponse(c, "文件上传完成", result)
+		return
+	}
+
+	if _, isImage := allowedImageExts[validated.Ext]; isImage {
+		processed, err := services.ProcessStoredImage(upload.ObjectKey)
+		if err != nil {
+			log.Printf("处理直传图片 %s 失败: %v", upload.ObjectKey, err)
+			utils.ErrorResponse(c, "图片处理失败", http.StatusBadRequest)
+			return
+		}
+		result["width"] = processed.Width
+		result["height"] = processed.Height
+		result["variants"] = services.ImageVariantURLs(processed.ProductImage(0))
+	}
+
+	now := time.Now()
+	if err := config.DB.Model(&upload).Updates(map[string]interface{}{
+		"status":       models.UploadStatusCompleted,
+		"size":         info.Size,
+		"sha256":       validated.SHA256,
+		"completed_at": now,
+	}).Error; err != nil {
+		log.Printf("Failed to update upload: %v", err)
+		utils.ErrorResponse(c, "确认上传失败", http.StatusInternalServerError)
+		return
+	}
+
+	result["size"] = info.Size
+	utils.SuccessResponse(c, "文件上传完成", result)
+}
@@ -111,6 +111,13 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// 禁用用户时注销其所有会话
+	if req.Status == 0 {
+		if err := utils.RevokeAllUserTokens(utils.UserTypeUser, int64(req.ID)); err != nil {
+			logrus.WithError(err).WithField("userID", req.ID).Error("Failed to revoke user sessions")
+		}
+	}
+
 	utils.SuccessResponse(c, "User updated successfully", nil)
 }
 
@@ -233,9 +240,45 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
+	// 删除用户后注销其所有会话
+	if err := utils.RevokeAllUserTokens(utils.UserTypeUser, int64(req.ID)); err != nil {
+		logrus.WithError(err).WithField("userID", req.ID).Error("Failed to revoke user sessions")
+	}
+
 	utils.SuccessResponse(c, "用户删除成功", nil)
 }
 
+// RevokeUserSessions 注销指定前台用户的所有会话
+func RevokeUserSessions(c *gin.Context) {
+	var req struct {
+		ID uint `json:"id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, "无效的用户ID", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, req.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, "用户不存在", http.StatusNotFound)
+			return
+		}
+		message, statusCode := utils.HandleMySQLError(err)
+		utils.ErrorResponse(c, message, statusCode)
+		return
+	}
+
+	if err := utils.RevokeAllUserTokens(utils.UserTypeUser, int64(user.ID)); err != nil {
+		logrus.WithError(err).WithField("userID", user.ID).Error("Failed to revoke user sessions")
+		utils.ErrorResponse(c, "注销会话失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, "该用户的所有会话已注销", nil)
+}
+
 // GetRoleOptions 获取角色选项列表
 func GetRoleOptions(c *gin.Context) {
 	var roles []models.Role
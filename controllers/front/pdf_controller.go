@@ -1,32 +1,179 @@
 package front
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
+
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/services"
+	"exam_server/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
-// DownloadPDF 下载PDF
+// PDF 下载地址的有效期
+const pdfDownloadURLLifetime = 5 * time.Minute
+
+// user_agent 字段长度
+const maxUserAgentLength = 512
+
+// DownloadHistoryItem 下载历史记录
+type DownloadHistoryItem struct {
+	ID           int64     `json:"id"`
+	SeriesID     int64     `json:"series_id"`
+	SeriesName   string    `json:"series_name"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// DownloadHistoryQueryParams 下载历史查询参数
+type DownloadHistoryQueryParams struct {
+	Page     int `form:"page,default=1"`       // 页码，默认1
+	PageSize int `form:"page_size,default=10"` // 每页数量，默认10
+}
+
+// DownloadPDF 下载系列PDF，返回带用户水印副本的限时下载地址并记录下载历史
 func DownloadPDF(c *gin.Context) {
-	// TODO: 实现PDF下载逻辑
-	// 1. 获取PDF ID
-	// 2. 验证用户权限
-	// 3. 获取PDF文件
-	// 4. 记录下载历史
-	pdfID := c.Param("id")
-	c.JSON(http.StatusOK, gin.H{
-		"message": "PDF下载成功",
-		"pdfId":   pdfID,
+	seriesID := c.Param("id")
+	userID, _ := c.Get("userID")
+
+	// 尚未公开的系列只有 VIP 用户可以下载，与系列详情保持一致
+	isVIP := utils.CheckUserRole(userID, "vip")
+
+	var series models.Series
+	query := config.DB.Where("id = ?", seriesID).Where(services.SeriesVisibility(isVIP))
+	if err := query.First(&series).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, "Series not found or access denied", http.StatusNotFound)
+			return
+		}
+		logrus.WithError(err).Error("Failed to query series for download")
+		utils.ErrorResponse(c, "Failed to download PDF", http.StatusInternalServerError)
+		return
+	}
+
+	if series.PdfURL == "" {
+		utils.ErrorResponse(c, "该系列暂无PDF", http.StatusNotFound)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		utils.ErrorResponse(c, "User not found", http.StatusNotFound)
+		return
+	}
+
+	// 每个用户下载的是带有自己信息水印的副本，便于追溯外泄来源
+	objectKey, err := services.WatermarkedSeriesPDF(&series, &user)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"userID":   userID,
+			"seriesID": series.ID,
+		}).Error("Failed to watermark series PDF")
+		utils.ErrorResponse(c, "Failed to download PDF", http.StatusInternalServerError)
+		return
+	}
+
+	downloadURL, err := services.SignDownloadURL(objectKey, pdfDownloadURLLifetime, series.Name+".pdf")
+	if err != nil {
+		logrus.WithError(err).WithField("seriesID", series.ID).Error("Failed to sign PDF download URL")
+		utils.ErrorResponse(c, "Failed to download PDF", http.StatusInternalServerError)
+		return
+	}
+
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	download := models.PdfDownload{
+		UserID:    toInt64(userID),
+		SeriesID:  series.ID,
+		IpAddress: c.ClientIP(),
+		UserAgent: userAgent,
+	}
+	if err := config.DB.Create(&download).Error; err != nil {
+		// 下载记录失败不影响用户下载
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"userID":   userID,
+			"seriesID": series.ID,
+		}).Error("Failed to record PDF download")
+	}
+
+	utils.SuccessResponse(c, "PDF下载成功", gin.H{
+		"url":        downloadURL,
+		"expires_in": int64(pdfDownloadURLLifetime.Seconds()),
 	})
 }
 
-// GetDownloadHistory 获取下载历史
+// GetDownloadHistory 获取当前用户的下载历史
 func GetDownloadHistory(c *gin.Context) {
-	// TODO: 实现获取下载历史逻辑
-	// 1. 从JWT获取用户ID
-	// 2. 查询用户的下载历史
-	c.JSON(http.StatusOK, gin.H{
-		"message": "获取下载历史成功",
-		// 添加下载历史数据
+	var params DownloadHistoryQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		utils.ErrorResponse(c, "参数无效", http.StatusBadRequest)
+		return
+	}
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = defaultProductPageSize
+	}
+	if params.PageSize > maxProductPageSize {
+		params.PageSize = maxProductPageSize
+	}
+
+	userID, _ := c.Get("userID")
+
+	query := config.DB.Model(&models.PdfDownload{}).
+		Where("pdf_downloads.user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logrus.WithError(err).Error("Failed to count download history")
+		utils.ErrorResponse(c, "获取下载历史失败", http.StatusInternalServerError)
+		return
+	}
+
+	history := make([]DownloadHistoryItem, 0)
+	offset := (params.Page - 1) * params.PageSize
+	if err := query.
+		Select("pdf_downloads.id, pdf_downloads.series_id, series.name AS series_name, pdf_downloads.created_at AS downloaded_at").
+		Joins("LEFT JOIN series ON pdf_downloads.series_id = series.id").
+		Order("pdf_downloads.id DESC").
+		Limit(params.PageSize).
+		Offset(offset).
+		Scan(&history).Error; err != nil {
+		logrus.WithError(err).Error("Failed to get download history")
+		utils.ErrorResponse(c, "获取下载历史失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, "获取下载历史成功", gin.H{
+		"history": history,
+		"pagination": gin.H{
+			"total":     total,
+			"page":      params.Page,
+			"page_size": params.PageSize,
+		},
 	})
 }
+
+// toInt64 将 context 中的用户 ID 转换为 int64
+func toInt64(v interface{}) int64 {
+	switch id := v.(type) {
+	case uint:
+		return int64(id)
+	case int64:
+		return id
+	case int:
+		return int64(id)
+	default:
+		logrus.WithField("userID", fmt.Sprint(v)).Warn("Unexpected userID type")
+		return 0
+	}
+}
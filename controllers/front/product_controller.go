@@ -1,31 +1,418 @@
 package front
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+
+	"exam_server/config"
+	"exam_server/models"
+	"exam_server/services"
+	"exam_server/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/guregu/null/v5"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// 商品列表每页默认数量和最大数量
+const (
+	defaultProductPageSize = 10
+	maxProductPageSize     = 100
 )
 
+// productSortColumns 允许排序的字段
+var productSortColumns = map[string]string{
+	"created_at":    "products.created_at",
+	"model_no":      "products.model_no",
+	"lens_width":    "products.lens_width",
+	"nose_bridge":   "products.nose_bridge",
+	"temple_length": "products.temple_length",
+	"sku_count":     "products.sku_count",
+}
+
+// 按搜索相关度排序，只在有搜索词时可用
+const productSortRelevance = "relevance"
+
+// ProductFilterParams 商品列表和首页共用的筛选参数
+type ProductFilterParams struct {
+	CategoryID      int64    `form:"category_id"` // 包含所有子分类
+	MaterialID      int64    `form:"material_id"`
+	BrandID         int64    `form:"brand_id"`
+	SeriesID        int64    `form:"series_id"`
+	Gender          string   `form:"gender"`
+	LensWidthMin    *float32 `form:"lens_width_min"`
+	LensWidthMax    *float32 `form:"lens_width_max"`
+	NoseBridgeMin   *float32 `form:"nose_bridge_min"`
+	NoseBridgeMax   *float32 `form:"nose_bridge_max"`
+	TempleLengthMin *float32 `form:"temple_length_min"`
+	TempleLengthMax *float32 `form:"temple_length_max"`
+}
+
+// ProductQueryParams 商品列表查询参数
+type ProductQueryParams struct {
+	ProductFilterParams
+	Keyword  string `form:"keyword"`                    // 全文搜索型号、货号、标题、描述及系列、品牌、分类、材质名称
+	SortBy   string `form:"sort_by,default=created_at"` // 见 productSortColumns；有搜索词且未指定时按相关度（relevance）排序
+	Order    string `form:"order,default=desc"`         // asc 或 desc
+	Page     int    `form:"page,default=1"`             // 页码，默认1
+	PageSize int    `form:"page_size,default=10"`       // 每页数量，默认10
+}
+
+// toFilter 转换为筛选条件
+func (p ProductFilterParams) toFilter() *services.ProductFilter {
+	return &services.ProductFilter{
+		CategoryID:   p.CategoryID,
+		MaterialID:   p.MaterialID,
+		BrandID:      p.BrandID,
+		SeriesID:     p.SeriesID,
+		Gender:       p.Gender,
+		LensWidth:    services.FloatRange{Min: p.LensWidthMin, Max: p.LensWidthMax},
+		NoseBridge:   services.FloatRange{Min: p.NoseBridgeMin, Max: p.NoseBridgeMax},
+		TempleLength: services.FloatRange{Min: p.TempleLengthMin, Max: p.TempleLengthMax},
+	}
+}
+
+// ProductListItem 商品列表项
+type ProductListItem struct {
+	ID                int64       `json:"id"`
+	ModelNo           string      `json:"model_no"`
+	ItemCode          null.String `json:"item_code"`
+	Title             string      `json:"title"`
+	Gender            null.String `json:"gender"`
+	LensWidth         float32     `json:"lens_width"`
+	NoseBridge        float32     `json:"nose_bridge"`
+	TempleLength      float32     `json:"temple_length"`
+	SkuCount          int64       `json:"sku_count"`
+	SeriesID          null.Int64  `json:"series_id"`
+	SeriesName        string      `json:"series_name"`
+	IsNewDesign       bool        `json:"is_new_design"`
+	CategoryID        int64       `json:"category_id"`
+	CategoryName      string      `json:"category_name"`
+	BrandID           null.Int64  `json:"brand_id"`
+	BrandName         string      `json:"brand_name"`
+	FrameMaterialID   int64       `json:"frame_material_id"`
+	FrameMaterialName string      `json:"frame_material_name"` // 主材质
+	ImageURL          string      `json:"image_url"`           // 主图
+	ImageAlt          string      `json:"image_alt"`           // 主图的替代文本
+
+	ImageVariants map[string]services.ImageVariantURL `json:"image_variants"`       // 主图各尺寸，列表使用 thumb
+	Materials     []services.ProductMaterialInfo      `json:"materials"`            // 全部材质，主材质排在第一位
+	Highlights    map[string][]string                 `json:"highlights,omitempty"` // 搜索时各字段中匹配的片段
+}
+
+// ProductListResponse 商品列表响应结构
+type ProductListResponse struct {
+	Products   []ProductListItem       `json:"products"`
+	Facets     *services.ProductFacets `json:"facets"` // 当前筛选条件下各筛选选项的数量
+	Pagination struct {
+		Total    int64 `json:"total"`     // 总记录数
+		Page     int   `json:"page"`      // 当前页码
+		PageSize int   `json:"page_size"` // 每页数量
+	} `json:"pagination"`
+}
+
+// ProductDetailResponse 商品详情响应结构
+type ProductDetailResponse struct {
+	ProductListItem
+	Description   string                                `json:"description"`
+	ImageURLs     []string                              `json:"image_urls"`
+	Images        []map[string]services.ImageVariantURL `json:"images"`        // 每张图片的各尺寸，与 image_urls 一一对应
+	ImageDetails  []services.ProductImageInfo           `json:"image_details"` // 每张图片的替代文本、颜色/角度标签，与 image_urls 一一对应
+	Variants      []services.ProductVariantInfo         `json:"variants"`      // 颜色款式
+	CategoryPath  []int64                               `json:"category_path"`
+	Series        *models.Series                        `json:"series"`
+	Category      *models.Category                      `json:"category"`
+	Brand         *models.Brand                         `json:"brand"`
+	FrameMaterial *models.FrameMaterial                 `json:"frame_material"`
+	CreatedAt     *models.LocalTime                     `json:"created_at"`
+	UpdatedAt     *models.LocalTime                     `json:"updated_at"`
+}
+
 // GetProducts 获取商品列表
 func GetProducts(c *gin.Context) {
-	// TODO: 实现获取商品列表逻辑
-	// 1. 获取查询参数（分页、筛选条件等）
-	// 2. 查询商品列表
-	c.JSON(http.StatusOK, gin.H{
-		"message": "获取商品列表成功",
-		// 添加商品列表数据
-	})
+	var params ProductQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error":  err.Error(),
+			"params": c.Request.URL.Query(),
+		}).Error("Failed to parse product list parameters")
+		utils.ErrorResponse(c, "参数无效", http.StatusBadRequest)
+		return
+	}
+
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = defaultProductPageSize
+	}
+	if params.PageSize > maxProductPageSize {
+		params.PageSize = maxProductPageSize
+	}
+
+	params.Keyword = strings.TrimSpace(params.Keyword)
+	if params.Keyword != "" && c.Query("sort_by") == "" {
+		params.SortBy = productSortRelevance
+	}
+	sortColumn, ok := productSortColumns[params.SortBy]
+	if !ok && (params.SortBy != productSortRelevance || params.Keyword == "") {
+		utils.ErrorResponse(c, "排序字段无效", http.StatusBadRequest)
+		return
+	}
+	order := strings.ToLower(params.Order)
+	if order != "asc" && order != "desc" {
+		utils.ErrorResponse(c, "排序方式无效", http.StatusBadRequest)
+		return
+	}
+
+	// 获取用户VIP状态
+	isVIP := false
+	if userID, exists := c.Get("userID"); exists && userID != nil {
+		isVIP = utils.CheckUserRole(userID, "vip")
+	}
+
+	filter := params.toFilter()
+	if params.Keyword != "" {
+		filter.Keyword = params.Keyword
+		result, err := services.SearchProducts(params.Keyword)
+		if err == nil {
+			filter.Search = result
+		} else if !errors.Is(err, services.ErrSearchUnavailable) {
+			// 索引不可用时退回型号、货号前缀匹配
+			logrus.WithError(err).WithField("keyword", params.Keyword).Error("Failed to search products")
+		}
+	}
+	searchResult := filter.Search
+
+	query, err := filter.Apply(visibleProducts(config.DB, isVIP))
+	if err != nil {
+		logrus.WithError(err).Error("Failed to apply product filters")
+		utils.ErrorResponse(c, "获取商品列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	var response ProductListResponse
+
+	if err := query.Count(&response.Pagination.Total).Error; err != nil {
+		logrus.WithError(err).Error("Failed to count products")
+		utils.ErrorResponse(c, "获取总数失败", http.StatusInternalServerError)
+		return
+	}
+
+	orderBy := fmt.Sprintf("%s %s, products.id %s", sortColumn, order, order)
+	if params.SortBy == productSortRelevance {
+		orderBy = "products.created_at DESC, products.id DESC" // 索引不可用时没有相关度
+		if searchResult != nil && len(searchResult.Hits) > 0 {
+			orderBy = searchResult.OrderBy("products.id")
+		}
+	}
+
+	var products []models.Product
+	offset := (params.Page - 1) * params.PageSize
+	if err := query.
+		Preload("Series").
+		Preload("Category").
+		Preload("Brand").
+		Preload("FrameMaterial").
+		Order(orderBy).
+		Limit(params.PageSize).
+		Offset(offset).
+		Find(&products).Error; err != nil {
+		logrus.WithError(err).Error("Failed to get product list")
+		utils.ErrorResponse(c, "获取商品列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	productIDs := make([]int64, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+	mainImages, err := services.PrimaryProductImages(productIDs)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get product images")
+		utils.ErrorResponse(c, "获取商品列表失败", http.StatusInternalServerError)
+		return
+	}
+	materials, err := services.ProductMaterials(productIDs)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get product materials")
+		utils.ErrorResponse(c, "获取商品列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	response.Facets, err = services.LoadProductFacets(func() *gorm.DB {
+		return visibleProducts(config.DB, isVIP)
+	}, filter)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to count product facets")
+		utils.ErrorResponse(c, "获取商品列表失败", http.StatusInternalServerError)
+		return
+	}
+
+	var highlights map[int64]map[string][]string
+	if searchResult != nil {
+		highlights = searchResult.Highlights()
+	}
+
+	// 初始化空数组，确保即使没有数据也会返回空数组而不是 null
+	response.Products = make([]ProductListItem, 0, len(products))
+	for _, product := range products {
+		item := toProductListItem(product)
+		item.Materials = materials[product.ID]
+		item.Highlights = highlights[product.ID]
+		if image, exists := mainImages[product.ID]; exists {
+			item.ImageURL = services.AssetURL(image.ImageURL)
+			item.ImageAlt = image.AltText
+			item.ImageVariants = services.ImageVariantURLs(image)
+		}
+		response.Products = append(response.Products, item)
+	}
+	response.Pagination.Page = params.Page
+	response.Pagination.PageSize = params.PageSize
+
+	utils.SuccessResponse(c, "获取商品列表成功", response)
 }
 
 // GetProductDetail 获取商品详情
 func GetProductDetail(c *gin.Context) {
-	// TODO: 实现获取商品详情逻辑
-	// 1. 获取商品ID
-	// 2. 查询商品详情
 	productID := c.Param("id")
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "获取商品详情成功",
-		"productId": productID,
-		// 添加商品详情数据
-	})
+
+	// 获取用户VIP状态
+	isVIP := false
+	if userID, exists := c.Get("userID"); exists && userID != nil {
+		isVIP = utils.CheckUserRole(userID, "vip")
+	}
+
+	var product models.Product
+	err := visibleProducts(config.DB, isVIP).
+		Preload("Series").
+		Preload("Series.FrameMaterial").
+		Preload("Category").
+		Preload("Brand").
+		Preload("FrameMaterial").
+		Where("products.id = ?", productID).
+		First(&product).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, "Product not found or access denied", http.StatusNotFound)
+			return
+		}
+		logrus.WithError(err).WithField("productID", productID).Error("Failed to query product details")
+		utils.ErrorResponse(c, "Failed to get product details", http.StatusInternalServerError)
+		return
+	}
+
+	// 查询所有图片
+	var productImages []models.ProductImage
+	if err := config.DB.Where("product_id = ?", product.ID).Order(services.ProductImageOrder).Find(&productImages).Error; err != nil {
+		logrus.WithError(err).WithField("productID", product.ID).Error("Failed to query product images")
+		utils.ErrorResponse(c, "Failed to get product details", http.StatusInternalServerError)
+		return
+	}
+
+	variants, err := services.LoadProductVariants(product.ID)
+	if err != nil {
+		logrus.WithError(err).WithField("productID", product.ID).Error("Failed to query product variants")
+		utils.ErrorResponse(c, "Failed to get product details", http.StatusInternalServerError)
+		return
+	}
+
+	materials, err := services.ProductMaterials([]int64{product.ID})
+	if err != nil {
+		logrus.WithError(err).WithField("productID", product.ID).Error("Failed to query product materials")
+		utils.ErrorResponse(c, "Failed to get product details", http.StatusInternalServerError)
+		return
+	}
+
+	imageURLs := make([]string, 0, len(productImages))
+	images := make([]map[string]services.ImageVariantURL, 0, len(productImages))
+	for _, image := range productImages {
+		imageURLs = append(imageURLs, services.AssetURL(image.ImageURL))
+		images = append(images, services.ImageVariantURLs(image))
+	}
+
+	// 获取分类路径
+	var categoryPath []int64
+	currentCategoryID := product.CategoryID
+	for currentCategoryID > 0 {
+		var category models.Category
+		if err := config.DB.First(&category, currentCategoryID).Error; err != nil {
+			break
+		}
+		categoryPath = append([]int64{currentCategoryID}, categoryPath...)
+		currentCategoryID = category.Pid
+	}
+
+	// 系列中保存的是对象路径
+	if product.Series != nil {
+		product.Series.PdfURL = services.AssetURL(product.Series.PdfURL)
+	}
+
+	response := ProductDetailResponse{
+		ProductListItem: toProductListItem(product),
+		Description:     product.Description,
+		ImageURLs:       imageURLs,
+		Images:          images,
+		ImageDetails:    services.ProductImageInfos(productImages),
+		Variants:        variants,
+		CategoryPath:    categoryPath,
+		Series:          product.Series,
+		Category:        product.Category,
+		Brand:           product.Brand,
+		FrameMaterial:   product.FrameMaterial,
+		CreatedAt:       product.CreatedAt,
+		UpdatedAt:       product.UpdatedAt,
+	}
+	response.Materials = materials[product.ID]
+	if mainImage, exists := services.PrimaryProductImage(productImages); exists {
+		response.ImageURL = services.AssetURL(mainImage.ImageURL)
+		response.ImageAlt = mainImage.AltText
+		response.ImageVariants = services.ImageVariantURLs(mainImage)
+	}
+
+	utils.SuccessResponse(c, "获取商品详情成功", response)
+}
+
+// visibleProducts 当前用户可见的商品，按所属系列的发布时间判断，与首页保持一致；
+// 不属于任何系列或所属系列已删除的商品始终可见
+func visibleProducts(db *gorm.DB, isVIP bool) *gorm.DB {
+	return db.Model(&models.Product{}).
+		Joins("LEFT JOIN series ON products.series_id = series.id AND series.deleted_at IS NULL").
+		Where("(series.id IS NULL OR ?)", services.SeriesVisibility(isVIP))
+}
+
+// toProductListItem 转换为列表项
+func toProductListItem(product models.Product) ProductListItem {
+	item := ProductListItem{
+		ID:              product.ID,
+		ModelNo:         product.ModelNO,
+		ItemCode:        product.ItemCode,
+		Title:           product.Title,
+		Gender:          product.Gender,
+		LensWidth:       product.LensWidth,
+		NoseBridge:      product.NoseBridge,
+		TempleLength:    product.TempleLength,
+		SkuCount:        product.SkuCount,
+		SeriesID:        product.SeriesID,
+		CategoryID:      product.CategoryID,
+		BrandID:         product.BrandID,
+		FrameMaterialID: product.FrameMaterialID,
+	}
+	if product.Series != nil {
+		item.SeriesName = product.Series.Name
+		item.IsNewDesign = product.Series.IsNewDesign
+	}
+	if product.Category != nil {
+		item.CategoryName = product.Category.Name
+	}
+	if product.Brand != nil {
+		item.BrandName = product.Brand.Name
+	}
+	if product.FrameMaterial != nil {
+		item.FrameMaterialName = product.FrameMaterial.Name
+	}
+	return item
 }
@@ -2,12 +2,11 @@ package front
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
-	"os"
 
 	"exam_server/config"
 	"exam_server/models"
+	"exam_server/services"
 	"exam_server/utils"
 
 	"github.com/gin-gonic/gin"
@@ -20,11 +19,14 @@ import (
 type ProductInfo struct {
 	ID            uint   `json:"id"`
 	ModelNo       string `json:"model_no"`
-	ImageURL      string `json:"image_url"`
 	LensWidth     string `json:"lens_width"`
 	NoseBridge    string `json:"nose_bridge"`
 	TempleLength  string `json:"temple_length"`
 	FrameMaterial string `json:"frame_material"`
+
+	ImageURL string                              `json:"image_url" gorm:"-"`      // 主图
+	ImageAlt string                              `json:"image_alt" gorm:"-"`      // 主图的替代文本
+	Variants map[string]services.ImageVariantURL `json:"image_variants" gorm:"-"` // 主图各尺寸，前端按需选择
 }
 
 // SeriesDetailResponse 系列详情响应结构体
@@ -54,10 +56,8 @@ func GetSeriesDetail(c *gin.Context) {
 
 	// Query series with permission check
 	var series models.Series
-	query := config.DB.Where("id = ? AND deleted_at IS NULL", seriesID)
-	if !isVIP {
-		query = query.Where("is_new_design = 0 OR is_new_design IS NULL")
-	}
+	query := config.DB.Where("id = ? AND deleted_at IS NULL", seriesID).
+		Where(services.SeriesVisibility(isVIP))
 
 	if err := query.First(&series).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -70,20 +70,20 @@ func GetSeriesDetail(c *gin.Context) {
 	}
 
 	// Query products with permission check
+	// 主图单独查询，JOIN 图片表会让有多张图片的商品重复出现
 	var products []ProductInfo
 	productsQuery := config.DB.Table("products").
 		Select(`
 			products.id,
 			products.model_no,
-			product_images.image_url,
 			products.lens_width,
 			products.nose_bridge,
 			products.temple_length,
 			frame_materials.name as frame_material
 		`).
 		Joins("LEFT JOIN frame_materials ON products.frame_material_id = frame_materials.id").
-		Joins("LEFT JOIN product_images ON products.id = product_images.product_id").
-		Where("products.series_id = ? AND products.deleted_at IS NULL", seriesID)
+		Where("products.series_id = ? AND products.deleted_at IS NULL", seriesID).
+		Order("products.id")
 
 	err := productsQuery.Find(&products).Error
 	if err != nil {
@@ -92,12 +92,23 @@ func GetSeriesDetail(c *gin.Context) {
 		return
 	}
 
-	ossBaseURL := os.Getenv("OSS_BASE_URL")
+	productIDs := make([]int64, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, int64(product.ID))
+	}
+	mainImages, err := services.PrimaryProductImages(productIDs)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to query product images")
+		utils.ErrorResponse(c, "Failed to get product details", http.StatusInternalServerError)
+		return
+	}
 
-	// 处理图片URL，添加OSS域名前缀
+	// 处理图片URL，转换为完整的访问地址
 	for i := range products {
-		if products[i].ImageURL != "" {
-			products[i].ImageURL = fmt.Sprintf("%s/images/%s", ossBaseURL, products[i].ImageURL)
+		if image, exists := mainImages[int64(products[i].ID)]; exists {
+			products[i].ImageURL = services.AssetURL(image.ImageURL)
+			products[i].ImageAlt = image.AltText
+			products[i].Variants = services.ImageVariantURLs(image)
 		}
 	}
 
@@ -0,0 +1,94 @@
+package front
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"exam_server/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ServeStorageFile 使用本地存储时提供文件访问，签名地址会校验有效期并按指定文件名下载
+func ServeStorageFile(c *gin.Context) {
+	local, ok := services.GetStorage().(*services.LocalStorage)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err := local.VerifySignedURL(key, c.Request.URL.Query()); err != nil {
+		if errors.Is(err, services.ErrSignatureExpired) {
+			c.String(http.StatusForbidden, "链接已过期")
+			return
+		}
+		c.String(http.StatusForbidden, "链接无效")
+		return
+	}
+
+	filePath, err := local.FilePath(key)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if _, err := local.Stat(key); err != nil {
+		if !errors.Is(err, services.ErrObjectNotFound) {
+			logrus.WithError(err).WithField("key", key).Error("Failed to stat storage file")
+		}
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if filename := c.Query("filename"); filename != "" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(filename)))
+	}
+	c.File(filePath)
+}
+
+// UploadStorageFile 使用本地存储时接收浏览器直传的文件，校验签名中的类型和大小限制
+func UploadStorageFile(c *gin.Context) {
+	local, ok := services.GetStorage().(*services.LocalStorage)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	policy, err := local.VerifyUploadURL(key, c.Request.URL.Query())
+	if err != nil {
+		if errors.Is(err, services.ErrSignatureExpired) {
+			c.String(http.StatusForbidden, "链接已过期")
+			return
+		}
+		c.String(http.StatusForbidden, "链接无效")
+		return
+	}
+
+	if c.ContentType() != policy.ContentType {
+		c.String(http.StatusBadRequest, "文件类型不符合要求")
+		return
+	}
+	if c.Request.ContentLength > policy.MaxSize {
+		c.String(http.StatusRequestEntityTooLarge, "文件过大")
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, policy.MaxSize)
+	if err := local.Put(key, body, policy.ContentType); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.String(http.StatusRequestEntityTooLarge, "文件过大")
+			return
+		}
+		logrus.WithError(err).WithField("key", key).Error("Failed to save uploaded file")
+		c.String(http.StatusInternalServerError, "上传失败")
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -1,6 +1,7 @@
 package front
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
@@ -108,15 +109,18 @@ func UserLogin(c *gin.Context) {
 		return
 	}
 
-	// 生成JWT token
-	token, err := utils.GenerateJWT(user.ID)
+	// 生成访问 Token 和刷新 Token
+	tokens, err := utils.IssueTokenPair(user.ID, utils.UserTypeUser)
 	if err != nil {
+		log.Printf("Error generating token: %v", err)
 		utils.ErrorResponse(c, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	utils.SuccessResponse(c, "Login successful", gin.H{
-		"token": token,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
 		"user": gin.H{
 			//"id":         user.ID,
 			"username": user.Username,
@@ -126,13 +130,49 @@ func UserLogin(c *gin.Context) {
 	})
 }
 
-// UserLogout 用户退出
+// RefreshTokenRequest 刷新 Token 请求结构体
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken 使用刷新 Token 换取新的访问 Token
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, "绑定参数失败", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := utils.RotateRefreshToken(req.RefreshToken, utils.UserTypeUser)
+	if errors.Is(err, utils.ErrUserDisabled) {
+		utils.ErrorResponse(c, "Account is disabled", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("Error refreshing token: %v", err)
+		utils.ErrorResponse(c, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	utils.SuccessResponse(c, "Token refreshed", tokens)
+}
+
+// UserLogout 用户退出，注销当前 Token
 func UserLogout(c *gin.Context) {
-	// TODO: 实现用户退出逻辑
-	// 1. 清除token（如果需要）
-	c.JSON(http.StatusOK, gin.H{
-		"message": "退出成功",
-	})
+	value, _ := c.Get("claims")
+	claims, ok := value.(*utils.Claims)
+	if !ok {
+		utils.ErrorResponse(c, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := utils.RevokeToken(claims); err != nil {
+		log.Printf("注销 Token 失败: %v", err)
+		utils.ErrorResponse(c, "退出失败", http.StatusInternalServerError)
+		return
+	}
+
+	utils.SuccessResponse(c, "退出成功", nil)
 }
 
 // GetUserProfile 获取用户信息
@@ -150,6 +190,7 @@ func GetUserProfile(c *gin.Context) {
 type UpdateProfileRequest struct {
 	FirstName   string `json:"first_name" binding:"required,min=1,max=50"`
 	LastName    string `json:"last_name" binding:"required,min=1,max=50"`
+	Company     string `json:"company" binding:"max=100"`                        // 公司名称，会出现在下载的 PDF 水印中
 	OldPassword string `json:"old_password,omitempty"`                           // 可选，但如果要更新密码则必填
 	NewPassword string `json:"new_password,omitempty" binding:"omitempty,min=6"` // 可选，但如果填写则至少6位
 }
@@ -211,6 +252,7 @@ func UpdateProfile(c *gin.Context) {
 	updates := models.User{
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
+		Company:   req.Company,
 		// Email:     req.Email,
 		Password: currentUser.Password, // 如果密码已更新，使用新密码；否则保持原密码
 	}
@@ -233,6 +275,7 @@ func UpdateProfile(c *gin.Context) {
 			"first_name": updatedUser.FirstName,
 			"last_name":  updatedUser.LastName,
 			"email":      updatedUser.Email,
+			"company":    updatedUser.Company,
 		},
 	})
 }
@@ -0,0 +1,24 @@
+-- ----------------------------
+-- Table structure for revoked_tokens
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `revoked_tokens`  (
+  `jti` varchar(64) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT 'Token ID',
+  `user_type` varchar(20) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '用户类型 sys_user/user',
+  `user_id` bigint(20) NOT NULL COMMENT '用户ID',
+  `expires_at` timestamp NOT NULL COMMENT 'Token 原过期时间，过期后可清理',
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`jti`) USING BTREE,
+  INDEX `expires_at`(`expires_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '已注销的Token' ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for user_token_revocations
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `user_token_revocations`  (
+  `user_type` varchar(20) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '用户类型 sys_user/user',
+  `user_id` bigint(20) NOT NULL COMMENT '用户ID',
+  `revoked_before` timestamp NOT NULL COMMENT '签发时间不晚于该时间的Token全部失效',
+  `updated_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`user_type`, `user_id`) USING BTREE,
+  INDEX `revoked_before`(`revoked_before` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '用户级别的Token注销记录' ROW_FORMAT = Dynamic;
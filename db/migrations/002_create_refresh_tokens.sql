@@ -0,0 +1,17 @@
+-- ----------------------------
+-- Table structure for refresh_tokens
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `refresh_tokens`  (
+  `jti` varchar(64) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT 'Token ID',
+  `family_id` varchar(64) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '同一次登录签发的Token共享',
+  `user_type` varchar(20) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '用户类型 sys_user/user',
+  `user_id` bigint(20) NOT NULL COMMENT '用户ID',
+  `replaced_by` varchar(64) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '轮换后新Token的jti',
+  `used_at` timestamp NULL DEFAULT NULL COMMENT '使用（轮换）时间',
+  `revoked_at` timestamp NULL DEFAULT NULL COMMENT '注销时间',
+  `expires_at` timestamp NOT NULL COMMENT '过期时间',
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`jti`) USING BTREE,
+  INDEX `family_id`(`family_id` ASC) USING BTREE,
+  INDEX `expires_at`(`expires_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '刷新Token' ROW_FORMAT = Dynamic;
@@ -0,0 +1,14 @@
+-- ----------------------------
+-- Table structure for pdf_downloads
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `pdf_downloads`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `user_id` bigint(20) NOT NULL COMMENT '前台用户ID',
+  `series_id` bigint(20) NOT NULL COMMENT '系列ID',
+  `ip_address` varchar(45) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '下载IP',
+  `user_agent` varchar(512) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '浏览器UA',
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP COMMENT '下载时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `user_id`(`user_id` ASC, `created_at` ASC) USING BTREE,
+  INDEX `series_id`(`series_id` ASC, `created_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '系列PDF下载记录' ROW_FORMAT = Dynamic;
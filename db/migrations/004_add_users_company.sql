@@ -0,0 +1,5 @@
+-- ----------------------------
+-- 前台用户公司名称，用于 PDF 水印
+-- ----------------------------
+ALTER TABLE `users`
+  ADD COLUMN `company` varchar(100) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '公司名称' AFTER `email`;
@@ -0,0 +1,5 @@
+-- ----------------------------
+-- 系列目录PDF由商品数据自动生成
+-- ----------------------------
+ALTER TABLE `series`
+  ADD COLUMN `auto_catalog` tinyint(1) NOT NULL DEFAULT 0 COMMENT 'PDF是否由商品数据自动生成';
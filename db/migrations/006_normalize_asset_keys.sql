@@ -0,0 +1,22 @@
+-- ----------------------------
+-- 资源地址统一保存为对象路径（例如 images/xxx.jpg、pdfs/xxx.pdf），访问地址由 ASSET_BASE_URL 或存储配置生成
+-- 已有数据中混有完整 URL 和只有文件名两种写法
+-- ----------------------------
+
+-- 完整 URL 去掉协议、域名和查询参数
+UPDATE `product_images`
+SET `image_url` = SUBSTRING_INDEX(SUBSTRING(`image_url`, LOCATE('/', `image_url`, LOCATE('://', `image_url`) + 3) + 1), '?', 1)
+WHERE `image_url` LIKE 'http://%' OR `image_url` LIKE 'https://%';
+
+-- 只有文件名的图片放在 images 目录下
+UPDATE `product_images`
+SET `image_url` = CONCAT('images/', `image_url`)
+WHERE `image_url` <> '' AND `image_url` NOT LIKE '%/%';
+
+UPDATE `series`
+SET `pdf_url` = SUBSTRING_INDEX(SUBSTRING(`pdf_url`, LOCATE('/', `pdf_url`, LOCATE('://', `pdf_url`) + 3) + 1), '?', 1)
+WHERE `pdf_url` LIKE 'http://%' OR `pdf_url` LIKE 'https://%';
+
+UPDATE `series`
+SET `pdf_url` = CONCAT('pdfs/', `pdf_url`)
+WHERE `pdf_url` <> '' AND `pdf_url` NOT LIKE '%/%';
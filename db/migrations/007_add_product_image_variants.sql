@@ -0,0 +1,7 @@
+-- ----------------------------
+-- 商品图片尺寸及缩略图等处理结果
+-- ----------------------------
+ALTER TABLE `product_images`
+  ADD COLUMN `width` int(11) NOT NULL DEFAULT 0 COMMENT '原图宽度（像素）' AFTER `image_url`,
+  ADD COLUMN `height` int(11) NOT NULL DEFAULT 0 COMMENT '原图高度（像素）' AFTER `width`,
+  ADD COLUMN `variants` json NULL COMMENT '各尺寸及 WebP 版本的对象路径' AFTER `height`;
@@ -0,0 +1,19 @@
+-- ----------------------------
+-- Table structure for uploads
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `uploads`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `sys_user_id` bigint(20) NOT NULL COMMENT '签发上传签名的后台用户ID',
+  `object_key` varchar(255) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '存储中的对象路径',
+  `filename` varchar(255) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '原始文件名',
+  `content_type` varchar(100) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '允许的 Content-Type',
+  `max_size` bigint(20) NOT NULL COMMENT '允许的最大字节数',
+  `size` bigint(20) NOT NULL DEFAULT 0 COMMENT '实际字节数，上传完成后记录',
+  `status` varchar(20) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL DEFAULT 'pending' COMMENT 'pending/completed',
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `completed_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `object_key`(`object_key` ASC) USING BTREE,
+  INDEX `sys_user_id`(`sys_user_id` ASC) USING BTREE,
+  INDEX `status`(`status` ASC, `created_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '浏览器直传文件记录' ROW_FORMAT = Dynamic;
@@ -0,0 +1,6 @@
+-- ----------------------------
+-- 上传文件按内容去重，表单上传的文件也记录到 uploads
+-- ----------------------------
+ALTER TABLE `uploads`
+  ADD COLUMN `sha256` char(64) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL DEFAULT '' COMMENT '文件内容的 SHA-256，用于去重' AFTER `size`,
+  ADD INDEX `sha256`(`sha256` ASC) USING BTREE;
@@ -0,0 +1,19 @@
+-- ----------------------------
+-- 商品图片排序、主图、替代文本和颜色/角度标签
+-- ----------------------------
+ALTER TABLE `product_images`
+  ADD COLUMN `sort_order` int(11) NOT NULL DEFAULT 0 COMMENT '排序，从小到大' AFTER `variants`,
+  ADD COLUMN `is_primary` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否为主图，每个商品只有一张' AFTER `sort_order`,
+  ADD COLUMN `alt_text` varchar(255) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL DEFAULT '' COMMENT '替代文本' AFTER `is_primary`,
+  ADD COLUMN `color` varchar(50) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL DEFAULT '' COMMENT '颜色标签' AFTER `alt_text`,
+  ADD COLUMN `angle` varchar(50) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL DEFAULT '' COMMENT '角度标签，例如 front、side' AFTER `color`,
+  ADD INDEX `product_sort`(`product_id` ASC, `is_primary` DESC, `sort_order` ASC, `id` ASC) USING BTREE;
+
+-- 已有图片按上传顺序排序，每个商品的第一张作为主图
+UPDATE `product_images`
+JOIN (
+  SELECT `id`, ROW_NUMBER() OVER (PARTITION BY `product_id` ORDER BY `id`) AS `rn`
+  FROM `product_images`
+) AS `ranked` ON `ranked`.`id` = `product_images`.`id`
+SET `product_images`.`sort_order` = `ranked`.`rn` - 1,
+    `product_images`.`is_primary` = (`ranked`.`rn` = 1);
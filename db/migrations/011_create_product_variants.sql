@@ -0,0 +1,27 @@
+-- ----------------------------
+-- Table structure for product_variants
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `product_variants`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `product_id` bigint(20) NOT NULL COMMENT '商品ID',
+  `sku_code` varchar(100) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '款式货号，全局唯一',
+  `color_name` varchar(100) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '颜色名称',
+  `color_swatch` varchar(255) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL DEFAULT '' COMMENT '色卡图片的对象路径',
+  `stock_status` varchar(20) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL DEFAULT 'in_stock' COMMENT 'in_stock/low_stock/out_of_stock',
+  `sort_order` int(11) NOT NULL DEFAULT 0 COMMENT '排序，从小到大',
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `sku_code`(`sku_code` ASC) USING BTREE,
+  INDEX `product_id`(`product_id` ASC, `sort_order` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '商品颜色款式（SKU）' ROW_FORMAT = Dynamic;
+
+-- 款式图片
+ALTER TABLE `product_images`
+  ADD COLUMN `variant_id` bigint(20) NULL DEFAULT NULL COMMENT '所属款式，为空时是商品通用图片' AFTER `product_id`,
+  ADD INDEX `variant_id`(`variant_id` ASC) USING BTREE;
+
+-- sku_count 改为按款式数量自动计算，已有商品手工填写的值按款式数量重新计算
+UPDATE `products` SET `sku_count` = (
+  SELECT COUNT(*) FROM `product_variants` WHERE `product_variants`.`product_id` = `products`.`id`
+);
@@ -0,0 +1,13 @@
+-- ----------------------------
+-- 商品的全部框材质，products.frame_material_id 为主材质，也会记录在这里
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `product_material`  (
+  `product_id` bigint(20) NOT NULL COMMENT '商品ID',
+  `frame_material_id` bigint(20) NOT NULL COMMENT '框材质ID',
+  PRIMARY KEY (`product_id`, `frame_material_id`) USING BTREE,
+  INDEX `frame_material_id`(`frame_material_id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '商品框材质' ROW_FORMAT = Dynamic;
+
+-- 已有商品的主材质
+INSERT IGNORE INTO `product_material` (`product_id`, `frame_material_id`)
+SELECT `id`, `frame_material_id` FROM `products` WHERE `frame_material_id` > 0;
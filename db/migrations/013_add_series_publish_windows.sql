@@ -0,0 +1,31 @@
+-- ----------------------------
+-- 系列发布时间窗口，替代手动设置的 is_new_design
+-- ----------------------------
+ALTER TABLE `series`
+  ADD COLUMN `vip_from` datetime NULL DEFAULT NULL COMMENT 'VIP用户可见的时间，为空时立即可见' AFTER `pdf_url`,
+  ADD COLUMN `public_from` datetime NULL DEFAULT NULL COMMENT '所有用户可见的时间，为空时只有VIP用户可见' AFTER `vip_from`,
+  ADD COLUMN `unpublish_at` datetime NULL DEFAULT NULL COMMENT '下架时间，为空时不下架' AFTER `public_from`,
+  ADD COLUMN `graduated_at` datetime NULL DEFAULT NULL COMMENT '已发出转为公开事件的时间' AFTER `unpublish_at`,
+  ADD INDEX `public_from`(`public_from` ASC, `graduated_at` ASC) USING BTREE;
+
+-- 原来不是最新设计的系列已经公开，不再发出事件
+UPDATE `series`
+SET `public_from` = COALESCE(`created_at`, NOW()),
+    `graduated_at` = COALESCE(`created_at`, NOW())
+WHERE `is_new_design` = 0 OR `is_new_design` IS NULL;
+
+ALTER TABLE `series` DROP COLUMN `is_new_design`;
+
+-- ----------------------------
+-- Table structure for series_publish_events
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `series_publish_events`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `series_id` bigint(20) NOT NULL COMMENT '系列ID',
+  `event` varchar(20) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT 'graduated：由VIP专属转为公开',
+  `occurred_at` datetime NOT NULL COMMENT '事件发生的时间，即 public_from',
+  `created_at` timestamp NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `series_id`(`series_id` ASC) USING BTREE,
+  INDEX `created_at`(`created_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '系列发布事件' ROW_FORMAT = Dynamic;
@@ -0,0 +1,29 @@
+-- ----------------------------
+-- 商品、系列的修改草稿，审核发布后才写入 products / series
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `content_drafts`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `entity_type` varchar(20) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '对象类型：product、series',
+  `entity_id` bigint(20) NOT NULL COMMENT '商品或系列ID',
+  `payload` json NOT NULL COMMENT '修改内容，与更新接口的请求体相同',
+  `status` varchar(20) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL DEFAULT 'draft' COMMENT '状态：draft 草稿、pending 待审核、rejected 已驳回、published 已发布',
+  `created_by` bigint(20) NULL DEFAULT NULL COMMENT '创建草稿的后台用户ID',
+  `updated_by` bigint(20) NULL DEFAULT NULL COMMENT '最后修改草稿的后台用户ID',
+  `submitted_by` bigint(20) NULL DEFAULT NULL COMMENT '提交审核的后台用户ID',
+  `submitted_at` datetime NULL DEFAULT NULL COMMENT '提交审核时间',
+  `reviewed_by` bigint(20) NULL DEFAULT NULL COMMENT '审核的后台用户ID',
+  `reviewed_at` datetime NULL DEFAULT NULL COMMENT '审核时间',
+  `review_comment` varchar(500) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '审核意见',
+  `published_at` datetime NULL DEFAULT NULL COMMENT '发布时间',
+  `created_at` datetime NULL DEFAULT NULL,
+  `updated_at` datetime NULL DEFAULT NULL,
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `entity`(`entity_type` ASC, `entity_id` ASC, `status` ASC) USING BTREE,
+  INDEX `status`(`status` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '内容草稿' ROW_FORMAT = Dynamic;
+
+-- 审核发布权限，拥有该权限的角色可以发布或驳回草稿
+INSERT INTO `sys_permissions` (`name`, `description`, `route`, `method`, `parent_id`, `created_at`, `updated_at`, `deleted_at`)
+SELECT 'content_publish', '审核并发布商品、系列草稿', '/drafts/review/*', 'POST', 0, NOW(), NOW(), NULL
+FROM DUAL
+WHERE NOT EXISTS (SELECT 1 FROM `sys_permissions` WHERE `name` = 'content_publish');
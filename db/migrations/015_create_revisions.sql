@@ -0,0 +1,18 @@
+-- ----------------------------
+-- 商品、系列、品牌、分类、框材质的修订记录，每次新建、修改、删除、恢复保存一个版本
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `revisions`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `entity_type` varchar(20) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '对象类型：product、series、brand、category、frame_material',
+  `entity_id` bigint(20) NOT NULL COMMENT '对象ID',
+  `version` int(11) NOT NULL COMMENT '版本号，每个对象从 1 开始递增',
+  `action` varchar(20) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '操作：create、update、delete、restore',
+  `snapshot` json NOT NULL COMMENT '变更后的完整数据，删除时为删除前的数据',
+  `diff` json NULL COMMENT '变化的字段，格式为 {"字段": {"from": 旧值, "to": 新值}}',
+  `sys_user_id` bigint(20) NULL DEFAULT NULL COMMENT '操作的后台用户ID',
+  `draft_id` bigint(20) NULL DEFAULT NULL COMMENT '通过草稿发布时的草稿ID',
+  `restored_version` int(11) NULL DEFAULT NULL COMMENT '恢复操作对应的历史版本号',
+  `created_at` datetime NULL DEFAULT NULL,
+  PRIMARY KEY (`id`) USING BTREE,
+  UNIQUE INDEX `entity_version`(`entity_type` ASC, `entity_id` ASC, `version` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '修订记录' ROW_FORMAT = Dynamic;
@@ -0,0 +1,32 @@
+-- ----------------------------
+-- 后台写操作的审计日志，保留天数由 AUDIT_LOG_RETENTION_DAYS 配置
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `audit_logs`  (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `sys_user_id` bigint(20) NULL DEFAULT NULL COMMENT '操作的后台用户ID，登录等未认证的请求为空',
+  `personal_access_token_id` bigint(20) NULL DEFAULT NULL COMMENT '使用个人访问令牌调用时的令牌ID',
+  `method` varchar(10) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '请求方法',
+  `route` varchar(255) CHARACTER SET utf8 COLLATE utf8_general_ci NOT NULL COMMENT '路由，例如 /api/V1/admin/products/delete-batch',
+  `entity_type` varchar(50) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '对象类型',
+  `entity_ids` json NULL COMMENT '涉及的对象ID列表',
+  `before_values` json NULL COMMENT '操作前的数据',
+  `after_values` json NULL COMMENT '操作后的数据',
+  `request_body` json NULL COMMENT '请求内容，密码、令牌等字段已脱敏',
+  `ip` varchar(64) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '客户端IP',
+  `user_agent` varchar(255) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '客户端 User-Agent',
+  `status_code` int(11) NOT NULL COMMENT 'HTTP 状态码',
+  `success` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否成功',
+  `message` varchar(500) CHARACTER SET utf8 COLLATE utf8_general_ci NULL DEFAULT NULL COMMENT '接口返回的提示信息',
+  `duration_ms` int(11) NOT NULL DEFAULT 0 COMMENT '耗时（毫秒）',
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `sys_user_id`(`sys_user_id` ASC, `created_at` ASC) USING BTREE,
+  INDEX `entity_type`(`entity_type` ASC, `created_at` ASC) USING BTREE,
+  INDEX `created_at`(`created_at` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8 COLLATE = utf8_general_ci COMMENT = '后台审计日志' ROW_FORMAT = Dynamic;
+
+-- 查看审计日志权限，拥有该权限的角色可以查询审计日志
+INSERT INTO `sys_permissions` (`name`, `description`, `route`, `method`, `parent_id`, `created_at`, `updated_at`, `deleted_at`)
+SELECT 'audit_log_view', '查看后台审计日志', '/audit-logs/*', 'GET', 0, NOW(), NOW(), NULL
+FROM DUAL
+WHERE NOT EXISTS (SELECT 1 FROM `sys_permissions` WHERE `name` = 'audit_log_view');
@@ -0,0 +1,8 @@
+-- ----------------------------
+-- 用户级别的 Token 注销改为按版本号判断，Token 签发时间只精确到秒，无法区分同一秒内注销前后签发的 Token
+-- ----------------------------
+ALTER TABLE `user_token_revocations`
+  ADD COLUMN `token_version` bigint(20) NOT NULL DEFAULT 0 COMMENT 'Token 版本，注销全部会话时加一，版本更小的Token失效' AFTER `user_id`;
+
+-- 之前签发的 Token 没有版本（视为 0），已有注销记录的用户需要重新登录
+UPDATE `user_token_revocations` SET `token_version` = 1;
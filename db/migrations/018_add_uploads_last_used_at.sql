@@ -0,0 +1,6 @@
+-- ----------------------------
+-- 去重命中时记录文件最近一次被使用的时间，孤立文件回收跳过最近被使用的文件
+-- ----------------------------
+ALTER TABLE `uploads`
+  ADD COLUMN `last_used_at` datetime NULL DEFAULT NULL COMMENT '最近一次去重命中的时间' AFTER `completed_at`,
+  ADD INDEX `last_used_at`(`last_used_at` ASC) USING BTREE;
@@ -3,6 +3,9 @@ package main
 import (
 	"exam_server/config"
 	"exam_server/routes"
+	"exam_server/services"
+	"exam_server/utils"
+	"flag"
 	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/joho/godotenv"
@@ -26,6 +29,9 @@ func init() {
 }
 
 func main() {
+	// -reindex 从数据库重建商品搜索索引后退出，需要在服务停止时运行；服务运行中请调用后台的重建接口
+	reindex := flag.Bool("reindex", false, "rebuild the product search index and exit")
+	flag.Parse()
 
 	fmt.Println("Application started...")
 
@@ -38,9 +44,52 @@ func main() {
 	config.InitDB()
 	log.Println("初始化数据库成功")
 
-	// 初始化 OSS
-	config.InitOSS()
-	log.Println("初始化 OSS 成功")
+	// 没有超级管理员角色也没有分配任何权限时，所有后台接口都会返回 403
+	if err := services.CheckSysPermissionSetup(); err != nil {
+		log.Printf("警告：后台权限未配置: %v", err)
+		fmt.Printf("WARNING: admin permissions are not configured: %v\n", err)
+	}
+
+	// 定时清理已过期的 Token 注销记录
+	utils.StartRevokedTokenCleanup(time.Hour)
+
+	// 初始化文件存储，STORAGE_DRIVER 为 local 时使用本地磁盘，默认使用 OSS
+	if err := services.InitStorage(); err != nil {
+		log.Fatalf("初始化文件存储失败: %v", err)
+	}
+	log.Println("初始化文件存储成功")
+
+	// 初始化商品搜索索引，失败时搜索退回数据库的前缀匹配
+	created, searchErr := services.InitSearch()
+	if searchErr != nil {
+		log.Printf("初始化搜索索引失败: %v", searchErr)
+	}
+	defer services.CloseSearch()
+
+	if *reindex {
+		count, err := services.RebuildSearchIndex()
+		if err != nil {
+			log.Fatalf("重建搜索索引失败: %v", err)
+		}
+		fmt.Printf("Search index rebuilt: %d products\n", count)
+		return
+	}
+	if created {
+		go func() {
+			if _, err := services.RebuildSearchIndex(); err != nil {
+				log.Printf("重建搜索索引失败: %v", err)
+			}
+		}()
+	}
+
+	// 每天回收没有被商品或系列引用的文件
+	services.StartAssetGC(24 * time.Hour)
+
+	// 每分钟检查到达公开时间的系列，发出转为公开的事件
+	services.StartSeriesPublishJob(time.Minute)
+
+	// 每天删除超过保留期的审计日志
+	services.StartAuditLogCleanup(24 * time.Hour)
 
 	// 初始化 Gin 路由
 	r := gin.Default()
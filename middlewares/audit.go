@@ -0,0 +1,178 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"exam_server/models"
+	"exam_server/services"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 审计日志记录的请求体和解析的响应体的最大长度，超过时不记录请求体、不解析响应
+const maxAuditBodySize = 64 << 10
+
+// auditResponseWriter 在写出响应的同时保留一份响应体，用于读取接口返回的 code 和 message
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if w.body.Len()+len(data) <= maxAuditBodySize {
+		w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditResponseWriter) WriteString(s string) (int, error) {
+	if w.body.Len()+len(s) <= maxAuditBodySize {
+		w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// auditContextKey 审计中间件在 gin.Context 中保存本次请求审计信息的 key
+const auditContextKey = "auditRequest"
+
+// auditRequest 一次写操作请求的审计信息，认证通过后由 AdminAuditSnapshotMiddleware 读取操作前的数据
+type auditRequest struct {
+	route    string
+	body     map[string]interface{}
+	resource *services.AuditResource
+	ids      []int64
+	before   []map[string]interface{}
+	loaded   bool
+}
+
+// AdminAuditMiddleware 记录后台所有写操作的审计日志：操作人、路由、对象及操作前后的数据、IP 和结果
+// 放在认证中间件之前，认证失败的请求也会记录，但只记录路由、IP 和结果
+func AdminAuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		// 读取 JSON 请求体后放回，不影响接口绑定参数；上传文件的请求不记录请求体
+		request := &auditRequest{route: route}
+		if c.Request.Body != nil && !strings.HasPrefix(c.ContentType(), "multipart/") {
+			data, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				log.Printf("审计日志读取请求体失败: %v", err)
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(data))
+			if len(data) > 0 && len(data) <= maxAuditBodySize {
+				if err := json.Unmarshal(data, &request.body); err == nil {
+					services.RedactAuditValues(request.body)
+				}
+			}
+		}
+		c.Set(auditContextKey, request)
+
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		// 响应格式见 utils.SuccessResponse / utils.ErrorResponse
+		var response struct {
+			Code    int             `json:"code"`
+			Message string          `json:"message"`
+			Result  json.RawMessage `json:"result"`
+		}
+		_ = json.Unmarshal(writer.body.Bytes(), &response)
+
+		statusCode := writer.Status()
+		success := statusCode < http.StatusBadRequest && response.Code == 1
+
+		entry := models.AuditLog{
+			Method:     method,
+			Route:      route,
+			IP:         c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			StatusCode: statusCode,
+			Success:    success,
+			Message:    response.Message,
+			CreatedAt:  start,
+		}
+
+		// 认证中间件在 c.Next() 中设置当前用户，未认证的请求不记录请求体和对象数据
+		if value, exists := c.Get("userID"); exists {
+			if sysUserID, ok := toInt64(value); ok {
+				entry.SysUserID = &sysUserID
+			}
+		}
+		if value, exists := c.Get("personalAccessTokenID"); exists {
+			if tokenID, ok := toInt64(value); ok {
+				entry.PersonalAccessTokenID = &tokenID
+			}
+		}
+
+		if entry.SysUserID != nil {
+			entry.RequestBody = services.AuditJSON(request.body)
+		}
+
+		// 只有通过认证和权限校验、已读取操作前数据的请求才记录对象数据
+		if request.loaded {
+			ids := request.ids
+			// 新建对象时请求中没有 ID，从返回的对象中读取
+			if len(ids) == 0 && success {
+				var created struct {
+					ID int64 `json:"id"`
+				}
+				if json.Unmarshal(response.Result, &created) == nil && created.ID != 0 {
+					ids = []int64{created.ID}
+				}
+			}
+			after, err := services.LoadAuditRows(request.resource.Table, ids)
+			if err != nil {
+				log.Printf("审计日志读取 %s 操作后的数据失败: %v", request.resource.Table, err)
+			}
+			entry.EntityType = request.resource.EntityType
+			entry.EntityIDs = services.AuditJSON(ids)
+			entry.BeforeValues = services.AuditJSON(request.before)
+			entry.AfterValues = services.AuditJSON(after)
+		}
+
+		entry.DurationMs = time.Since(start).Milliseconds()
+		if err := services.SaveAuditLog(&entry); err != nil {
+			log.Printf("保存审计日志失败: %v", err)
+		}
+	}
+}
+
+// AdminAuditSnapshotMiddleware 读取写操作涉及对象在操作前的数据，放在认证和权限校验中间件之后
+func AdminAuditSnapshotMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get(auditContextKey)
+		request, ok := value.(*auditRequest)
+		if !exists || !ok {
+			c.Next()
+			return
+		}
+
+		if request.resource = services.FindAuditResource(request.route); request.resource != nil {
+			request.ids = services.AuditEntityIDs(request.route, request.body)
+			var err error
+			if request.before, err = services.LoadAuditRows(request.resource.Table, request.ids); err != nil {
+				log.Printf("审计日志读取 %s 操作前的数据失败: %v", request.resource.Table, err)
+			}
+			request.loaded = true
+		}
+
+		c.Next()
+	}
+}
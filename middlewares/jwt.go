@@ -10,7 +10,8 @@ import (
 )
 
 // JWTAuthMiddleware 验证 JWT 并从中提取用户信息（必需的认证）
-func JWTAuthMiddleware() gin.HandlerFunc {
+// userType 指定该路由组接受的用户类型，后台和前台的 Token 不能混用
+func JWTAuthMiddleware(userType string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从请求头获取 Authorization 信息
 		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
@@ -20,8 +21,8 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 验证和解析 JWT
-		claims, err := utils.ValidateJWT(tokenString)
+		// 验证和解析 JWT，只接受访问 Token
+		claims, err := utils.ValidateJWT(tokenString, userType, utils.TokenTypeAccess)
 		if err != nil {
 			utils.ErrorResponse(c, "Invalid or expired token", http.StatusUnauthorized)
 			c.Abort() // 中止请求
@@ -29,7 +30,7 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 		}
 
 		// 将 userID 存入 context
-		c.Set("userID", claims.UserID)
+		setUserContext(c, claims)
 		log.Printf("jwt里解析出来的用户id为%d", claims.UserID)
 
 		c.Next() // 继续处理请求
@@ -37,16 +38,16 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 }
 
 // OptionalJWTAuth 可选的 JWT 认证中间件
-func OptionalJWTAuth() gin.HandlerFunc {
+func OptionalJWTAuth(userType string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从请求头获取 Authorization 信息
 		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
 		if tokenString != "" {
 			// 验证和解析 JWT
-			claims, err := utils.ValidateJWT(tokenString)
+			claims, err := utils.ValidateJWT(tokenString, userType, utils.TokenTypeAccess)
 			if err == nil {
 				// 将 userID 存入 context
-				c.Set("userID", claims.UserID)
+				setUserContext(c, claims)
 				log.Printf("可选jwt里解析出来的用户id为%d", claims.UserID)
 			}
 		}
@@ -54,3 +55,14 @@ func OptionalJWTAuth() gin.HandlerFunc {
 		c.Next() // 无论是否有 token，都继续处理请求
 	}
 }
+
+// setUserContext 将用户 ID 和声明存入 context
+// 用户 ID 的类型与对应模型保持一致：SysUser.ID 为 int64，User.ID 为 uint
+func setUserContext(c *gin.Context, claims *utils.Claims) {
+	if claims.UserType == utils.UserTypeSysUser {
+		c.Set("userID", int64(claims.UserID))
+	} else {
+		c.Set("userID", claims.UserID)
+	}
+	c.Set("claims", claims) // 退出登录时用于注销当前 Token
+}
@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"exam_server/services"
+	"exam_server/utils"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SysPermissionMiddleware 根据后台用户角色绑定的 sys_permissions 校验路由访问权限
+// 必须放在 JWTAuthMiddleware 之后使用
+func SysPermissionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 未匹配到路由时交给 Gin 返回 404
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			utils.ErrorResponse(c, "Unauthorized", http.StatusUnauthorized)
+			c.Abort()
+			return
+		}
+
+		sysUserID, ok := toInt64(userID)
+		if !ok {
+			log.Printf("userID 转换失败: %v", userID)
+			utils.ErrorResponse(c, "Unauthorized", http.StatusUnauthorized)
+			c.Abort()
+			return
+		}
+
+		allowed, err := services.HasSysPermission(sysUserID, c.Request.Method, route)
+		if err != nil {
+			log.Printf("加载后台用户 %d 的权限失败: %v", sysUserID, err)
+			utils.ErrorResponse(c, "权限校验失败", http.StatusInternalServerError)
+			c.Abort()
+			return
+		}
+
+		// 使用个人访问令牌时，还需要令牌的权限范围覆盖当前路由
+		if value, isToken := c.Get("tokenAbilities"); allowed && isToken {
+			abilities, _ := value.([]string)
+			allowed, err = services.AbilitiesAllow(abilities, c.Request.Method, route)
+			if err != nil {
+				log.Printf("校验个人访问令牌权限范围失败: %v", err)
+				utils.ErrorResponse(c, "权限校验失败", http.StatusInternalServerError)
+				c.Abort()
+				return
+			}
+		}
+
+		if !allowed {
+			log.Printf("后台用户 %d 无权访问 %s %s", sysUserID, c.Request.Method, route)
+			utils.ErrorResponse(c, "没有访问该接口的权限", http.StatusForbidden)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// toInt64 将 context 中的用户 ID 统一转换为 int64
+func toInt64(v interface{}) (int64, bool) {
+	switch id := v.(type) {
+	case int64:
+		return id, true
+	case uint:
+		return int64(id), true
+	case int:
+		return int64(id), true
+	default:
+		return 0, false
+	}
+}
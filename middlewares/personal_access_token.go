@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"exam_server/services"
+	"exam_server/utils"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuthMiddleware 后台认证中间件，同时接受后台用户的 JWT 和个人访问令牌
+// 个人访问令牌格式为 "{id}|{secret}"，JWT 中不会出现 "|"
+func AdminAuthMiddleware() gin.HandlerFunc {
+	jwtAuth := JWTAuthMiddleware(utils.UserTypeSysUser)
+
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !strings.Contains(tokenString, "|") {
+			jwtAuth(c)
+			return
+		}
+
+		token, err := services.ValidatePersonalAccessToken(tokenString)
+		if err != nil {
+			log.Printf("个人访问令牌校验失败: %v", err)
+			utils.ErrorResponse(c, "Invalid or expired token", http.StatusUnauthorized)
+			c.Abort()
+			return
+		}
+
+		// 与 JWT 保持一致，后台用户 ID 为 int64
+		c.Set("userID", token.TokenableID)
+		c.Set("personalAccessTokenID", token.ID)
+		c.Set("tokenAbilities", services.PersonalAccessTokenAbilities(token))
+
+		c.Next()
+	}
+}
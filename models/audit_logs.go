@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const TableNameAuditLog = "audit_logs"
+
+// AuditLog 后台写操作的审计日志，由审计中间件记录
+type AuditLog struct {
+	ID                    int64           `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	SysUserID             *int64          `gorm:"column:sys_user_id" json:"sys_user_id"`                           // 登录等未认证的请求为空
+	PersonalAccessTokenID *int64          `gorm:"column:personal_access_token_id" json:"personal_access_token_id"` // 使用个人访问令牌调用时的令牌 ID
+	Method                string          `gorm:"column:method;not null" json:"method"`
+	Route                 string          `gorm:"column:route;not null" json:"route"`
+	EntityType            string          `gorm:"column:entity_type" json:"entity_type"`
+	EntityIDs             json.RawMessage `gorm:"column:entity_ids;type:json" json:"entity_ids"`
+	BeforeValues          json.RawMessage `gorm:"column:before_values;type:json" json:"before_values"`
+	AfterValues           json.RawMessage `gorm:"column:after_values;type:json" json:"after_values"`
+	RequestBody           json.RawMessage `gorm:"column:request_body;type:json" json:"request_body"` // 密码、令牌等字段已脱敏
+	IP                    string          `gorm:"column:ip" json:"ip"`
+	UserAgent             string          `gorm:"column:user_agent" json:"user_agent"`
+	StatusCode            int             `gorm:"column:status_code;not null" json:"status_code"`
+	Success               bool            `gorm:"column:success;not null" json:"success"`
+	Message               string          `gorm:"column:message" json:"message"`
+	DurationMs            int64           `gorm:"column:duration_ms;not null" json:"duration_ms"`
+	CreatedAt             time.Time       `gorm:"column:created_at;not null" json:"created_at"`
+}
+
+// TableName AuditLog's table name
+func (*AuditLog) TableName() string {
+	return TableNameAuditLog
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const TableNameContentDraft = "content_drafts"
+
+// 草稿状态
+const (
+	DraftStatusDraft     = "draft"     // 编辑中
+	DraftStatusPending   = "pending"   // 已提交，等待审核
+	DraftStatusRejected  = "rejected"  // 审核驳回，可以继续修改后重新提交
+	DraftStatusPublished = "published" // 已发布到线上数据
+)
+
+// ContentDraft 商品、系列的修改草稿，每个对象同时最多有一份未发布的草稿
+type ContentDraft struct {
+	ID            int64           `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	EntityType    string          `gorm:"column:entity_type;not null" json:"entity_type"` // EntityProduct 或 EntitySeries
+	EntityID      int64           `gorm:"column:entity_id;not null" json:"entity_id"`
+	Payload       json.RawMessage `gorm:"column:payload;type:json;not null" json:"payload"`
+	Status        string          `gorm:"column:status;not null;default:draft" json:"status"`
+	CreatedBy     *int64          `gorm:"column:created_by" json:"created_by"`
+	UpdatedBy     *int64          `gorm:"column:updated_by" json:"updated_by"`
+	SubmittedBy   *int64          `gorm:"column:submitted_by" json:"submitted_by"`
+	SubmittedAt   *time.Time      `gorm:"column:submitted_at" json:"submitted_at"`
+	ReviewedBy    *int64          `gorm:"column:reviewed_by" json:"reviewed_by"`
+	ReviewedAt    *time.Time      `gorm:"column:reviewed_at" json:"reviewed_at"`
+	ReviewComment string          `gorm:"column:review_comment" json:"review_comment"`
+	PublishedAt   *time.Time      `gorm:"column:published_at" json:"published_at"`
+	CreatedAt     time.Time       `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time       `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName ContentDraft's table name
+func (*ContentDraft) TableName() string {
+	return TableNameContentDraft
+}
@@ -0,0 +1,10 @@
+package models
+
+// 后台维护的内容对象类型，用于草稿和修订记录
+const (
+	EntityProduct       = "product"
+	EntitySeries        = "series"
+	EntityBrand         = "brand"
+	EntityCategory      = "category"
+	EntityFrameMaterial = "frame_material"
+)
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// ImageVariant 图片的一个尺寸，Key、WebPKey 为存储中的对象路径
+type ImageVariant struct {
+	Key     string `json:"key"`
+	WebPKey string `json:"webp_key"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+// ImageVariants 尺寸名（thumb、medium、large）到图片尺寸的映射，以 JSON 保存
+type ImageVariants map[string]ImageVariant
+
+// Value 实现 driver.Valuer 接口
+func (v ImageVariants) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner 接口
+func (v *ImageVariants) Scan(value interface{}) error {
+	var data []byte
+	switch value := value.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case []byte:
+		data = value
+	case string:
+		data = []byte(value)
+	default:
+		return fmt.Errorf("can not convert %v to ImageVariants", value)
+	}
+	if len(data) == 0 {
+		*v = nil
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
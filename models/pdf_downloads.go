@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+const TableNamePdfDownload = "pdf_downloads"
+
+// PdfDownload 前台用户下载系列 PDF 的记录
+type PdfDownload struct {
+	ID        int64     `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	UserID    int64     `gorm:"column:user_id;not null;index" json:"user_id"`
+	SeriesID  int64     `gorm:"column:series_id;not null;index" json:"series_id"`
+	IpAddress string    `gorm:"column:ip_address" json:"ip_address"`
+	UserAgent string    `gorm:"column:user_agent" json:"user_agent"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// TableName PdfDownload's table name
+func (*PdfDownload) TableName() string {
+	return TableNamePdfDownload
+}
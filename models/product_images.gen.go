@@ -6,11 +6,20 @@ const TableNameProductImage = "product_images"
 
 // ProductImage mapped from table <product_skus>
 type ProductImage struct {
-	ID        int64      `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
-	ProductID null.Int64 `gorm:"column:product_id" json:"product_id"`
-	ImageURL  string     `gorm:"column:image_url" json:"image_url"`
-	CreatedAt *LocalTime `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt *LocalTime `gorm:"column:updated_at;default:CURRENT_TIMESTAMP" json:"updated_at"`
+	ID        int64         `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	ProductID null.Int64    `gorm:"column:product_id" json:"product_id"`
+	VariantID null.Int64    `gorm:"column:variant_id" json:"variant_id"` // 所属款式，为空时是商品通用图片
+	ImageURL  string        `gorm:"column:image_url" json:"image_url"`
+	Width     int           `gorm:"column:width;default:0" json:"width"`           // 原图宽度（像素）
+	Height    int           `gorm:"column:height;default:0" json:"height"`         // 原图高度（像素）
+	Variants  ImageVariants `gorm:"column:variants" json:"variants"`               // 缩略图等尺寸，旧图片为空
+	SortOrder int           `gorm:"column:sort_order;default:0" json:"sort_order"` // 排序，从小到大
+	IsPrimary bool          `gorm:"column:is_primary;default:0" json:"is_primary"` // 是否为主图，每个商品只有一张
+	AltText   string        `gorm:"column:alt_text" json:"alt_text"`               // 替代文本
+	Color     string        `gorm:"column:color" json:"color"`                     // 颜色标签
+	Angle     string        `gorm:"column:angle" json:"angle"`                     // 角度标签，例如 front、side
+	CreatedAt *LocalTime    `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt *LocalTime    `gorm:"column:updated_at;default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
 
 // TableName ProductSku's table name
@@ -0,0 +1,28 @@
+package models
+
+const TableNameProductVariant = "product_variants"
+
+// 款式的库存状态
+const (
+	StockStatusInStock    = "in_stock"     // 有货
+	StockStatusLowStock   = "low_stock"    // 库存紧张
+	StockStatusOutOfStock = "out_of_stock" // 缺货
+)
+
+// ProductVariant 商品的颜色款式（SKU），每个款式有独立的货号
+type ProductVariant struct {
+	ID          int64      `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	ProductID   int64      `gorm:"column:product_id;not null;index" json:"product_id"`
+	SkuCode     string     `gorm:"column:sku_code;not null;uniqueIndex" json:"sku_code"`
+	ColorName   string     `gorm:"column:color_name;not null" json:"color_name"`
+	ColorSwatch string     `gorm:"column:color_swatch" json:"color_swatch"` // 色卡图片的对象路径
+	StockStatus string     `gorm:"column:stock_status;not null;default:in_stock" json:"stock_status"`
+	SortOrder   int        `gorm:"column:sort_order;default:0" json:"sort_order"`
+	CreatedAt   *LocalTime `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt   *LocalTime `gorm:"column:updated_at;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// TableName ProductVariant's table name
+func (*ProductVariant) TableName() string {
+	return TableNameProductVariant
+}
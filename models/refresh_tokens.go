@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+const TableNameRefreshToken = "refresh_tokens"
+
+// RefreshToken 已签发的刷新 Token，每次刷新后旧 Token 标记为已使用
+type RefreshToken struct {
+	Jti        string     `gorm:"column:jti;primaryKey" json:"jti"`
+	FamilyID   string     `gorm:"column:family_id;not null;index" json:"family_id"`
+	UserType   string     `gorm:"column:user_type;not null" json:"user_type"`
+	UserID     int64      `gorm:"column:user_id;not null" json:"user_id"`
+	ReplacedBy string     `gorm:"column:replaced_by" json:"replaced_by"` // 轮换后新 Token 的 jti
+	UsedAt     *time.Time `gorm:"column:used_at" json:"used_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+// TableName RefreshToken's table name
+func (*RefreshToken) TableName() string {
+	return TableNameRefreshToken
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const TableNameRevision = "revisions"
+
+// 修订记录的操作类型
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore" // 恢复到历史版本
+)
+
+// Revision 后台内容对象的一个版本，对象类型见 entity_types.go
+type Revision struct {
+	ID              int64           `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	EntityType      string          `gorm:"column:entity_type;not null" json:"entity_type"`
+	EntityID        int64           `gorm:"column:entity_id;not null" json:"entity_id"`
+	Version         int             `gorm:"column:version;not null" json:"version"`
+	Action          string          `gorm:"column:action;not null" json:"action"`
+	Snapshot        json.RawMessage `gorm:"column:snapshot;type:json;not null" json:"snapshot"` // 变更后的完整数据，删除时为删除前的数据
+	Diff            json.RawMessage `gorm:"column:diff;type:json" json:"diff"`                  // {"字段": {"from": 旧值, "to": 新值}}
+	SysUserID       *int64          `gorm:"column:sys_user_id" json:"sys_user_id"`
+	DraftID         *int64          `gorm:"column:draft_id" json:"draft_id"`
+	RestoredVersion *int            `gorm:"column:restored_version" json:"restored_version"`
+	CreatedAt       time.Time       `gorm:"column:created_at" json:"created_at"`
+}
+
+// TableName Revision's table name
+func (*Revision) TableName() string {
+	return TableNameRevision
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+const TableNameRevokedToken = "revoked_tokens"
+
+// RevokedToken 已注销的 Token，按 jti 记录，过期后可清理
+type RevokedToken struct {
+	Jti       string    `gorm:"column:jti;primaryKey" json:"jti"`
+	UserType  string    `gorm:"column:user_type;not null" json:"user_type"`
+	UserID    int64     `gorm:"column:user_id;not null" json:"user_id"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null" json:"expires_at"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// TableName RevokedToken's table name
+func (*RevokedToken) TableName() string {
+	return TableNameRevokedToken
+}
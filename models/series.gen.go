@@ -18,7 +18,12 @@ type Series struct {
 	FrameMaterialID int64          `gorm:"column:frame_material_id;not null" json:"frame_material_id" binding:"required"`
 	FrameMaterial   FrameMaterial  `json:"frame_material" gorm:"foreignKey:FrameMaterialID"`
 	PdfURL          string         `gorm:"column:pdf_url;not null" json:"pdf_url" binding:"required"`
-	IsNewDesign     bool           `json:"is_new_design" gorm:"default:false"`
+	VipFrom         *LocalTime     `gorm:"column:vip_from" json:"vip_from"`         // VIP 用户可见的时间，为空时立即可见
+	PublicFrom      *LocalTime     `gorm:"column:public_from" json:"public_from"`   // 所有用户可见的时间，为空时只有 VIP 用户可见
+	UnpublishAt     *LocalTime     `gorm:"column:unpublish_at" json:"unpublish_at"` // 下架时间，为空时不下架
+	GraduatedAt     *LocalTime     `gorm:"column:graduated_at" json:"-"`            // 已发出转为公开事件的时间
+	IsNewDesign     bool           `json:"is_new_design" gorm:"-"`                  // 尚未公开（只有 VIP 可见），查询后根据 public_from 计算
+	AutoCatalog     bool           `json:"auto_catalog" gorm:"default:false"` // PDF 由商品数据自动生成，商品变化后重新生成
 	CreatedAt       *LocalTime      `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt       *LocalTime     `gorm:"column:updated_at;default:CURRENT_TIMESTAMP" json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"` // 软删除标志
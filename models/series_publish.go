@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AfterFind 根据发布时间计算 IsNewDesign
+func (s *Series) AfterFind(tx *gorm.DB) error {
+	s.IsNewDesign = !s.IsPublicAt(time.Now())
+	return nil
+}
+
+// IsPublicAt 在 now 时是否已对所有用户公开，不考虑下架时间
+func (s *Series) IsPublicAt(now time.Time) bool {
+	return s.PublicFrom != nil && !time.Time(*s.PublicFrom).After(now)
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+const TableNameSeriesPublishEvent = "series_publish_events"
+
+// 系列发布事件类型
+const (
+	SeriesEventGraduated = "graduated" // 由 VIP 专属转为公开
+)
+
+// SeriesPublishEvent 系列发布状态变化的事件，由后台任务按发布时间生成
+type SeriesPublishEvent struct {
+	ID         int64     `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	SeriesID   int64     `gorm:"column:series_id;not null;index" json:"series_id"`
+	Event      string    `gorm:"column:event;not null" json:"event"`
+	OccurredAt time.Time `gorm:"column:occurred_at;not null" json:"occurred_at"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
+
+	Series *Series `gorm:"foreignKey:SeriesID" json:"series,omitempty"`
+}
+
+// TableName SeriesPublishEvent's table name
+func (*SeriesPublishEvent) TableName() string {
+	return TableNameSeriesPublishEvent
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+const TableNameUpload = "uploads"
+
+// 直传文件的状态
+const (
+	UploadStatusPending   = "pending"   // 已签发上传签名，等待浏览器上传
+	UploadStatusCompleted = "completed" // 已确认上传完成
+)
+
+// Upload 上传的文件记录，表单上传时直接记为已完成；浏览器直传的在签发签名时创建，上传完成后回调确认
+type Upload struct {
+	ID          int64      `gorm:"column:id;primaryKey;autoIncrement:true" json:"id"`
+	SysUserID   int64      `gorm:"column:sys_user_id;not null;index" json:"sys_user_id"`
+	ObjectKey   string     `gorm:"column:object_key;not null;uniqueIndex" json:"object_key"`
+	Filename    string     `gorm:"column:filename;not null" json:"filename"`
+	ContentType string     `gorm:"column:content_type;not null" json:"content_type"`
+	MaxSize     int64      `gorm:"column:max_size;not null" json:"max_size"`
+	Size        int64      `gorm:"column:size;not null;default:0" json:"size"`
+	SHA256      string     `gorm:"column:sha256;not null;default:'';index" json:"sha256"` // 文件内容的 SHA-256，用于去重
+	Status      string     `gorm:"column:status;not null;default:pending" json:"status"`
+	CreatedAt   time.Time  `gorm:"column:created_at" json:"created_at"`
+	CompletedAt *time.Time `gorm:"column:completed_at" json:"completed_at"`
+	LastUsedAt  *time.Time `gorm:"column:last_used_at;index" json:"last_used_at"` // 最近一次去重命中的时间
+}
+
+// TableName Upload's table name
+func (*Upload) TableName() string {
+	return TableNameUpload
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+const TableNameUserTokenRevocation = "user_token_revocations"
+
+// UserTokenRevocation 用户级别的 Token 注销记录，版本小于 TokenVersion 的 Token 全部失效
+type UserTokenRevocation struct {
+	UserType      string    `gorm:"column:user_type;primaryKey" json:"user_type"`
+	UserID        int64     `gorm:"column:user_id;primaryKey" json:"user_id"`
+	TokenVersion  int64     `gorm:"column:token_version;not null" json:"token_version"`
+	RevokedBefore time.Time `gorm:"column:revoked_before;not null" json:"revoked_before"` // 最近一次注销全部会话的时间
+	UpdatedAt     time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName UserTokenRevocation's table name
+func (*UserTokenRevocation) TableName() string {
+	return TableNameUserTokenRevocation
+}
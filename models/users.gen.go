@@ -23,6 +23,7 @@ type User struct {
 	Password  string         `gorm:"column:password;not null" json:"password" binding:"required,min=6"`
 	Status    int64          `gorm:"column:status;not null;default:0" json:"status" `
 	Avatar    string         `gorm:"column:avatar;" json:"avatar"`
+	Company   string         `gorm:"column:company;" json:"company"` // 公司名称，用于 PDF 水印
 	IpAddress string         `gorm:"column:ip_address;" json:"ip_address"`
 	CreatedAt *LocalTime     `gorm:"column:created_at;default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt *LocalTime     `gorm:"column:updated_at;default:CURRENT_TIMESTAMP" json:"updated_at"`
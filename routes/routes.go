@@ -4,6 +4,9 @@ import (
 	"exam_server/controllers/admin"
 	"exam_server/controllers/front"
 	"exam_server/middlewares"
+	"exam_server/models"
+	"exam_server/services"
+	"exam_server/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -16,34 +19,50 @@ func RegisterRoutes(r *gin.Engine) {
 		c.String(http.StatusOK, "Hello World")
 	})
 
+	// 使用本地存储时由服务本身提供上传文件的访问
+	if _, ok := services.GetStorage().(*services.LocalStorage); ok {
+		r.GET(services.LocalStorageRoute+"/*key", front.ServeStorageFile)
+		r.PUT(services.LocalStorageRoute+"/*key", front.UploadStorageFile) // 浏览器直传
+	}
+
 	// 后台
-	//不需要 JWT 中间件保护的公共路由
-	publicRoutes := r.Group("/api/V1/admin")
+	//不需要 JWT 中间件保护的公共路由，写操作记录审计日志
+	publicRoutes := r.Group("/api/V1/admin", middlewares.AdminAuditMiddleware())
 	{
 		publicRoutes.POST("/login", admin.SysUserLogin)                          // 后台用户登录
+		publicRoutes.POST("/refresh", admin.RefreshToken)                        // 刷新 Token
 		publicRoutes.GET("/captcha/refresh", admin.RefreshCaptcha)               // 刷新验证码
 		publicRoutes.POST("/request-password-reset", admin.RequestPasswordReset) // 请求重置密码
 		publicRoutes.POST("/reset-password", admin.ResetPassword)                // 重置密码
 	}
 
 	// 后台
-	//需要 JWT 中间件保护的私有路由
-	apiRoutes := r.Group("/api/V1/admin", middlewares.JWTAuthMiddleware())
+	//需要 JWT 或个人访问令牌保护的私有路由，审计中间件在认证之前，认证失败的写操作只记录路由、IP 和结果
+	apiRoutes := r.Group("/api/V1/admin", middlewares.AdminAuditMiddleware(), middlewares.AdminAuthMiddleware())
 	{
 		//获取管理员菜单-仅自己权限内的
 		apiRoutes.GET("/sys-menus", admin.GetSysUserMenus)
 
+		apiRoutes.POST("/logout", admin.Logout) // 后台用户退出登录
+	}
+
+	// 后台
+	//需要 JWT 和角色权限校验的私有路由，权限校验通过后才读取写操作对象在操作前的数据
+	authRoutes := apiRoutes.Group("", middlewares.SysPermissionMiddleware(), middlewares.AdminAuditSnapshotMiddleware())
+	{
+
 		//后台用户路由组
-		sysUserRoutes := apiRoutes.Group("/sys-users")
+		sysUserRoutes := authRoutes.Group("/sys-users")
 		{
-			sysUserRoutes.POST("/create", admin.SysUserStore)  // 创建后台用户
-			sysUserRoutes.POST("/update", admin.SysUserUpdate) // 更新后台用户
-			sysUserRoutes.POST("/delete", admin.SysUserDelete) // 删除后台用户
-			sysUserRoutes.GET("", admin.GetAllSysUsers)        //所有后台用户 带分页
+			sysUserRoutes.POST("/create", admin.SysUserStore)                   // 创建后台用户
+			sysUserRoutes.POST("/update", admin.SysUserUpdate)                  // 更新后台用户
+			sysUserRoutes.POST("/delete", admin.SysUserDelete)                  // 删除后台用户
+			sysUserRoutes.POST("/revoke-sessions", admin.SysUserRevokeSessions) // 注销后台用户所有会话
+			sysUserRoutes.GET("", admin.GetAllSysUsers)                         //所有后台用户 带分页
 		}
 
 		//后台角色路由组
-		sysRoleRoutes := apiRoutes.Group("/sys-roles")
+		sysRoleRoutes := authRoutes.Group("/sys-roles")
 		{
 			sysRoleRoutes.POST("/create", admin.SysRoleCreate)                  // 创建管理员角色
 			sysRoleRoutes.POST("/delete", admin.SysRoleDelete)                  // 删除管理员角色
@@ -55,7 +74,7 @@ func RegisterRoutes(r *gin.Engine) {
 		}
 
 		//后台权限
-		sysPermissionRoutes := apiRoutes.Group("/sys-permissions")
+		sysPermissionRoutes := authRoutes.Group("/sys-permissions")
 		{
 			sysPermissionRoutes.GET("/tree", admin.GetSysPermissionsTree)              // 查询所有权限（分页）
 			sysPermissionRoutes.POST("/create", admin.SysPermissionCreate)             // 创建权限
@@ -67,8 +86,16 @@ func RegisterRoutes(r *gin.Engine) {
 
 		}
 
+		// 个人访问令牌（供脚本调用后台接口）
+		tokenRoutes := authRoutes.Group("/personal-access-tokens")
+		{
+			tokenRoutes.POST("/create", admin.CreatePersonalAccessToken) // 创建令牌
+			tokenRoutes.POST("/revoke", admin.RevokePersonalAccessToken) // 注销令牌
+			tokenRoutes.GET("", admin.GetPersonalAccessTokens)           // 查询令牌（分页）
+		}
+
 		// 商品分类
-		categoryRoutes := apiRoutes.Group("/categories")
+		categoryRoutes := authRoutes.Group("/categories")
 		{
 			categoryRoutes.GET("/fetch-cascade", admin.GetCategoriesForCascader) //分类级联 （下拉绑定用）
 			categoryRoutes.GET("/tree", admin.GetCategoriesTree)                 //分类列表 （后台管理用）
@@ -77,10 +104,14 @@ func RegisterRoutes(r *gin.Engine) {
 			categoryRoutes.POST("/delete", admin.DeleteCategory)                 // 删除分类
 			categoryRoutes.GET("", admin.GetCategories)                          // 获取所有分类
 			categoryRoutes.GET("/:id", admin.GetCategory)                        // 查询单个分类
+
+			// 修订记录
+			categoryRoutes.GET("/:id/revisions", admin.GetRevisions(models.EntityCategory))
+			categoryRoutes.POST("/revisions/restore", admin.RestoreRevision(models.EntityCategory))
 		}
 
 		// 商品材质
-		materialRoutes := apiRoutes.Group("/frame-materials")
+		materialRoutes := authRoutes.Group("/frame-materials")
 		{
 			materialRoutes.POST("/create", admin.CreateFrameMaterial)            // 创建新框材质
 			materialRoutes.POST("/update", admin.UpdateFrameMaterial)            // 更新框材质
@@ -88,22 +119,32 @@ func RegisterRoutes(r *gin.Engine) {
 			materialRoutes.GET("", admin.GetAllFrameMaterials)                   // 获取所有框材质
 			materialRoutes.GET("paginated", admin.GetAllFrameMaterialsPaginated) // 获取所有框材质
 			materialRoutes.GET("/:id", admin.GetFrameMaterial)                   // 查询单个框材质
+
+			// 修订记录
+			materialRoutes.GET("/:id/revisions", admin.GetRevisions(models.EntityFrameMaterial))
+			materialRoutes.POST("/revisions/restore", admin.RestoreRevision(models.EntityFrameMaterial))
 		}
 
 		// 商品系列
-		seriesRoutes := apiRoutes.Group("/series")
+		seriesRoutes := authRoutes.Group("/series")
 		{
 			seriesRoutes.POST("/create", admin.CreateSeries)
 			seriesRoutes.POST("/update", admin.UpdateSeries)
 			seriesRoutes.POST("/delete", admin.DeleteSeries)
 			seriesRoutes.POST("/delete-batch", admin.DeleteSeriesBatch)
+			seriesRoutes.POST("/generate-catalog", admin.GenerateSeriesCatalog) // 根据商品生成目录PDF
 			seriesRoutes.GET("", admin.GetAllSeries)
 			seriesRoutes.GET("/paginated", admin.GetAllSeriesPaginated)
 			seriesRoutes.GET("/:id", admin.GetSeries)
+			seriesRoutes.GET("/pdf-downloads/report", admin.GetPdfDownloadReport) // 系列PDF下载统计
+
+			// 修订记录
+			seriesRoutes.GET("/:id/revisions", admin.GetRevisions(models.EntitySeries))
+			seriesRoutes.POST("/revisions/restore", admin.RestoreRevision(models.EntitySeries))
 		}
 
 		// 商品品牌
-		brandRoutes := apiRoutes.Group("/brands")
+		brandRoutes := authRoutes.Group("/brands")
 		{
 			brandRoutes.POST("/create", admin.CreateBrand)
 			brandRoutes.POST("/update", admin.UpdateBrand)
@@ -112,39 +153,92 @@ func RegisterRoutes(r *gin.Engine) {
 			brandRoutes.GET("", admin.GetAllBrands)
 			brandRoutes.GET("/paginated", admin.GetAllBrandsPaginated)
 			brandRoutes.GET("/:id", admin.GetBrand)
+
+			// 修订记录
+			brandRoutes.GET("/:id/revisions", admin.GetRevisions(models.EntityBrand))
+			brandRoutes.POST("/revisions/restore", admin.RestoreRevision(models.EntityBrand))
 		}
 
 		// 产品
-		productRoutes := apiRoutes.Group("/products")
+		productRoutes := authRoutes.Group("/products")
 		{
 			productRoutes.POST("/create", admin.CreateProduct)
 			productRoutes.POST("/update", admin.UpdateProduct)
 			productRoutes.POST("/delete", admin.DeleteProduct)
 			productRoutes.POST("/delete-batch", admin.DeleteProductBatch)
+			productRoutes.POST("/import", admin.ImportProducts) // 从 Excel/CSV 批量导入商品
 			productRoutes.GET("/paginated", admin.GetAllProductsPaginated)
+			productRoutes.GET("/export", admin.ExportProducts)   // 按列表筛选条件导出 xlsx/csv
 			productRoutes.GET("", admin.GetAllProductsPaginated) //获取全部products数据
 			productRoutes.GET("/:id", admin.GetProduct)          //获取单个商品数据
+
+			// 商品图片，修改立即生效，还需要审核发布权限
+			productRoutes.POST("/images/reorder", admin.ReorderProductImages)   // 调整图片顺序
+			productRoutes.POST("/images/primary", admin.SetPrimaryProductImage) // 设置主图
+			productRoutes.POST("/images/update", admin.UpdateProductImage)      // 修改替代文本和颜色/角度标签
+
+			// 商品款式（SKU），修改立即生效，还需要审核发布权限
+			productRoutes.GET("/:id/variants", admin.GetProductVariants)
+			productRoutes.POST("/variants/create", admin.CreateProductVariant)
+			productRoutes.POST("/variants/update", admin.UpdateProductVariant)
+			productRoutes.POST("/variants/delete", admin.DeleteProductVariant)
+
+			// 修订记录
+			productRoutes.GET("/:id/revisions", admin.GetRevisions(models.EntityProduct))
+			productRoutes.POST("/revisions/restore", admin.RestoreRevision(models.EntityProduct))
+		}
+
+		// 商品、系列的修改草稿，更新接口只保存草稿，审核发布后才生效
+		draftRoutes := authRoutes.Group("/drafts")
+		{
+			draftRoutes.GET("/paginated", admin.GetDraftsPaginated)
+			draftRoutes.GET("/:id", admin.GetDraft)
+			draftRoutes.POST("/submit", admin.SubmitDraft) // 提交审核
+			draftRoutes.POST("/delete", admin.DeleteDraft) // 放弃草稿
+		}
+
+		// 审核草稿，对应 content_publish 权限（/drafts/review/*）
+		draftReviewRoutes := authRoutes.Group("/drafts/review")
+		{
+			draftReviewRoutes.POST("/publish", admin.PublishDraft) // 审核通过并发布
+			draftReviewRoutes.POST("/reject", admin.RejectDraft)   // 驳回
+		}
+
+		// 审计日志
+		auditLogRoutes := authRoutes.Group("/audit-logs")
+		{
+			auditLogRoutes.GET("/paginated", admin.GetAuditLogsPaginated)
+		}
+
+		// 商品搜索索引
+		searchRoutes := authRoutes.Group("/search")
+		{
+			searchRoutes.POST("/reindex", admin.RebuildSearchIndex) // 从数据库重建索引
 		}
 
 		// 上传文件到OSS
-		ossRoutes := apiRoutes.Group("/oss")
+		ossRoutes := authRoutes.Group("/oss")
 		{
 			ossRoutes.POST("/upload/multiple", admin.UploadFiles)
+			ossRoutes.POST("/upload/presign", admin.PresignUpload)   // 签发浏览器直传签名
+			ossRoutes.POST("/upload/complete", admin.CompleteUpload) // 直传完成回调
+			ossRoutes.POST("/gc", admin.CollectOrphanedAssets)       // 回收孤立文件，dry_run 默认 true
 		}
 
 		//获取所有前台用户列表
-		userRoutes := apiRoutes.Group("/users")
+		userRoutes := authRoutes.Group("/users")
 		{
-			userRoutes.POST("/create", admin.CreateUser) // 创建用户
-			userRoutes.POST("/update", admin.UpdateUser) // 更新用户
-			userRoutes.POST("/delete", admin.DeleteUser) // 删除用户
+			userRoutes.POST("/create", admin.CreateUser)                  // 创建用户
+			userRoutes.POST("/update", admin.UpdateUser)                  // 更新用户
+			userRoutes.POST("/delete", admin.DeleteUser)                  // 删除用户
+			userRoutes.POST("/revoke-sessions", admin.RevokeUserSessions) // 注销用户所有会话
 			//userRoutes.POST("/delete-batch", admin.DeleteUserBatch)
 			userRoutes.GET("", admin.GetUsersPaginated) // 获取所有前台用户列表
 			//userRoutes.GET("/:id", admin.GetUser)          // 获取所有前台用户列表
 		}
 
 		//获取所有前台角色列表
-		roleRoutes := apiRoutes.Group("/roles")
+		roleRoutes := authRoutes.Group("/roles")
 		{
 			roleRoutes.POST("/create", admin.CreateRole) // 创建前台角色
 			roleRoutes.POST("/update", admin.UpdateRole) // 更新前台角色
@@ -162,19 +256,20 @@ func RegisterRoutes(r *gin.Engine) {
 	frontPublicRoutes := r.Group("/api/V1")
 	{
 		// 首页相关
-		frontPublicRoutes.GET("/home", middlewares.OptionalJWTAuth(), front.GetHomeData)
+		frontPublicRoutes.GET("/home", middlewares.OptionalJWTAuth(utils.UserTypeUser), front.GetHomeData)
 
 		// 用户认证相关
 		frontPublicRoutes.POST("/register", front.UserRegister) // 用户注册
 		frontPublicRoutes.POST("/login", front.UserLogin)       // 用户登录
+		frontPublicRoutes.POST("/refresh", front.RefreshToken)  // 刷新 Token
 
 		// 商品相关
-		// frontPublicRoutes.GET("/products", front.GetProducts)          // 获取商品列表
-		// frontPublicRoutes.GET("/products/:id", front.GetProductDetail) // 获取商品详情
+		frontPublicRoutes.GET("/products", middlewares.OptionalJWTAuth(utils.UserTypeUser), front.GetProducts)          // 获取商品列表
+		frontPublicRoutes.GET("/products/:id", middlewares.OptionalJWTAuth(utils.UserTypeUser), front.GetProductDetail) // 获取商品详情
 	}
 
 	// 需要 JWT 中间件保护的私有路由
-	frontPrivateRoutes := r.Group("/api/V1", middlewares.JWTAuthMiddleware())
+	frontPrivateRoutes := r.Group("/api/V1", middlewares.JWTAuthMiddleware(utils.UserTypeUser))
 	{
 		// 用户相关
 		userRoutes := frontPrivateRoutes.Group("/user")
@@ -183,14 +278,14 @@ func RegisterRoutes(r *gin.Engine) {
 			userRoutes.POST("/profile/update", front.UpdateProfile) // 更新用户信息
 		}
 
-		// frontPublicRoutes.POST("/logout", front.UserLogout) // 用户退出
+		frontPrivateRoutes.POST("/logout", front.UserLogout) // 用户退出
 
 		// PDF下载相关
-		// pdfRoutes := frontPrivateRoutes.Group("/pdf")
-		// {
-		// 	pdfRoutes.GET("/download/:id", front.DownloadPDF)   // 下载PDF
-		// 	pdfRoutes.GET("/history", front.GetDownloadHistory) // 获取下载历史
-		// }
+		pdfRoutes := frontPrivateRoutes.Group("/pdf")
+		{
+			pdfRoutes.GET("/download/:id", front.DownloadPDF)   // 下载系列PDF，:id 为系列ID
+			pdfRoutes.GET("/history", front.GetDownloadHistory) // 获取下载历史
+		}
 	}
 
 }
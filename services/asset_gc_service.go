@@ -0,0 +1,270 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 回收孤立文件时扫描的目录
+var assetGCPrefixes = []string{AssetFolderImages + "/", AssetFolderPDFs + "/"}
+
+const (
+	// 最近上传的文件可能还没有保存到商品或系列，不回收
+	assetGCMinAge = 24 * time.Hour
+	// 软删除的商品和系列在该期限内仍可恢复，期间保留其文件
+	assetGCGracePeriod = 7 * 24 * time.Hour
+	// 该期限内的修订记录引用的文件保留，恢复历史版本时仍然可用
+	assetGCRevisionRetention = 90 * 24 * time.Hour
+)
+
+// ErrAssetGCRunning 已有回收任务在运行
+var ErrAssetGCRunning = errors.New("asset garbage collection is already running")
+
+var assetGCMu sync.Mutex
+
+// OrphanedAsset 没有被任何商品图片、款式色卡或系列引用的文件
+type OrphanedAsset struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// AssetGCResult 回收结果
+type AssetGCResult struct {
+	DryRun       bool            `json:"dry_run"`
+	Scanned      int             `json:"scanned"`       // 扫描的文件数
+	Skipped      int             `json:"skipped"`       // 上传或去重命中时间过短而跳过的孤立文件数
+	Orphans      []OrphanedAsset `json:"orphans"`       // 孤立文件
+	OrphanSize   int64           `json:"orphan_size"`   // 孤立文件总字节数
+	DeletedCount int             `json:"deleted_count"` // 实际删除的文件数，dry run 时为 0
+}
+
+// CollectOrphanedAssets 找出 images/、pdfs/ 下没有被商品图片、款式色卡、系列 PDF 引用的文件，dryRun 为 false 时删除
+// 软删除时间在宽限期内的商品和系列、未发布的草稿、保留期限内的修订记录仍视为引用
+func CollectOrphanedAssets(dryRun bool) (*AssetGCResult, error) {
+	if !assetGCMu.TryLock() {
+		return nil, ErrAssetGCRunning
+	}
+	defer assetGCMu.Unlock()
+
+	referenced, liveSeries, err := referencedAssetKeys()
+	if err != nil {
+		return nil, err
+	}
+	recentlyUsed, err := recentlyUsedUploadKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AssetGCResult{DryRun: dryRun, Orphans: make([]OrphanedAsset, 0)}
+	minModified := time.Now().Add(-assetGCMinAge)
+	for _, prefix := range assetGCPrefixes {
+		objects, err := storage.List(prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range objects {
+			result.Scanned++
+			if referenced[object.Key] || isLiveWatermarkedPDF(object.Key, liveSeries) {
+				continue
+			}
+			if object.LastModified.After(minModified) || recentlyUsed[object.Key] {
+				result.Skipped++
+				continue
+			}
+			result.Orphans = append(result.Orphans, OrphanedAsset{
+				Key:          object.Key,
+				Size:         object.Size,
+				LastModified: object.LastModified,
+			})
+			result.OrphanSize += object.Size
+		}
+	}
+
+	if dryRun || len(result.Orphans) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, 0, len(result.Orphans))
+	for _, orphan := range result.Orphans {
+		keys = append(keys, orphan.Key)
+	}
+	if err := storage.DeleteBatch(keys); err != nil {
+		return nil, fmt.Errorf("failed to delete orphaned assets: %v", err)
+	}
+	result.DeletedCount = len(keys)
+
+	log.Printf("已回收 %d 个孤立文件，共 %d 字节", result.DeletedCount, result.OrphanSize)
+	return result, nil
+}
+
+// StartAssetGC 定时回收孤立文件
+func StartAssetGC(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := CollectOrphanedAssets(false); err != nil {
+				log.Printf("回收孤立文件失败: %v", err)
+			}
+		}
+	}()
+}
+
+// recentlyUsedUploadKeys 最近去重命中过的文件（含图片各尺寸），可能已经返回给客户端但还没有保存到商品或系列
+func recentlyUsedUploadKeys() (map[string]bool, error) {
+	var keys []string
+	if err := config.DB.Model(&models.Upload{}).
+		Where("last_used_at > ?", time.Now().Add(-assetGCMinAge)).
+		Pluck("object_key", &keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to load recently used uploads: %v", err)
+	}
+
+	recentlyUsed := make(map[string]bool, len(keys))
+	markReferencedAssets(recentlyUsed, keys, "")
+	return recentlyUsed, nil
+}
+
+// referencedAssetKeys 商品图片（含各尺寸）、款式色卡、系列 PDF、未发布草稿和近期修订记录引用的对象路径，以及仍然有效的系列 ID
+func referencedAssetKeys() (map[string]bool, map[int64]bool, error) {
+	cutoff := time.Now().Add(-assetGCGracePeriod)
+	referenced := make(map[string]bool)
+
+	var productImages []models.ProductImage
+	if err := config.DB.Model(&models.ProductImage{}).
+		Select("product_images.image_url, product_images.variants").
+		Joins("JOIN products ON products.id = product_images.product_id").
+		Where("products.deleted_at IS NULL OR products.deleted_at > ?", cutoff).
+		Find(&productImages).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load product images: %v", err)
+	}
+	for _, productImage := range productImages {
+		productImage.ImageURL = AssetKey(productImage.ImageURL, AssetFolderImages)
+		for _, key := range ProductImageKeys([]models.ProductImage{productImage}) {
+			referenced[key] = true
+		}
+	}
+
+	// 款式色卡
+	var swatches []string
+	if err := config.DB.Model(&models.ProductVariant{}).
+		Joins("JOIN products ON products.id = product_variants.product_id").
+		Where("product_variants.color_swatch <> ''").
+		Where("products.deleted_at IS NULL OR products.deleted_at > ?", cutoff).
+		Pluck("product_variants.color_swatch", &swatches).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load variant swatches: %v", err)
+	}
+	for _, swatch := range swatches {
+		referenced[AssetKey(swatch, AssetFolderImages)] = true
+	}
+
+	var series []models.Series
+	if err := config.DB.Unscoped().Model(&models.Series{}).
+		Select("id, pdf_url").
+		Where("deleted_at IS NULL OR deleted_at > ?", cutoff).
+		Find(&series).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load series: %v", err)
+	}
+	liveSeries := make(map[int64]bool, len(series))
+	for _, s := range series {
+		liveSeries[s.ID] = true
+		if s.PdfURL != "" {
+			referenced[AssetKey(s.PdfURL, AssetFolderPDFs)] = true
+		}
+	}
+
+	// 草稿中新上传的图片和 PDF 在发布前没有其他引用，审核时间可能超过 assetGCMinAge
+	var payloads []string
+	if err := config.DB.Model(&models.ContentDraft{}).
+		Where("status IN ?", openDraftStatuses).
+		Pluck("payload", &payloads).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load drafts: %v", err)
+	}
+	for _, payload := range payloads {
+		var draft struct {
+			ImageURLs []string `json:"image_urls"`
+			PdfURL    string   `json:"pdf_url"`
+		}
+		if err := json.Unmarshal([]byte(payload), &draft); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode draft: %v", err)
+		}
+		markReferencedAssets(referenced, draft.ImageURLs, draft.PdfURL)
+	}
+
+	// 修订记录的快照是变更后的数据，被替换掉的旧文件在 diff 的 from 中
+	var revisions []models.Revision
+	if err := config.DB.Model(&models.Revision{}).
+		Select("snapshot, diff").
+		Where("entity_type IN ? AND created_at > ?", []string{models.EntityProduct, models.EntitySeries},
+			time.Now().Add(-assetGCRevisionRetention)).
+		Find(&revisions).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load revisions: %v", err)
+	}
+	for _, revision := range revisions {
+		var snapshot struct {
+			ImageURLs []string `json:"image_urls"`
+			PdfURL    string   `json:"pdf_url"`
+		}
+		if err := json.Unmarshal(revision.Snapshot, &snapshot); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode revision snapshot: %v", err)
+		}
+		markReferencedAssets(referenced, snapshot.ImageURLs, snapshot.PdfURL)
+
+		if len(revision.Diff) == 0 {
+			continue
+		}
+		var diff struct {
+			ImageURLs struct {
+				From []string `json:"from"`
+			} `json:"image_urls"`
+			PdfURL struct {
+				From string `json:"from"`
+			} `json:"pdf_url"`
+		}
+		if err := json.Unmarshal(revision.Diff, &diff); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode revision diff: %v", err)
+		}
+		markReferencedAssets(referenced, diff.ImageURLs.From, diff.PdfURL.From)
+	}
+
+	return referenced, liveSeries, nil
+}
+
+// markReferencedAssets 把图片（含各尺寸）和 PDF 标记为被引用
+func markReferencedAssets(referenced map[string]bool, imageURLs []string, pdfURL string) {
+	for _, imageURL := range imageURLs {
+		key := AssetKey(imageURL, AssetFolderImages)
+		if key == "" {
+			continue
+		}
+		referenced[key] = true
+		for _, size := range imageVariantSizes {
+			variant := imageVariantKeys(key, size.Name)
+			referenced[variant.Key] = true
+			referenced[variant.WebPKey] = true
+		}
+	}
+	if pdfURL != "" {
+		referenced[AssetKey(pdfURL, AssetFolderPDFs)] = true
+	}
+}
+
+// isLiveWatermarkedPDF 带水印的 PDF 按系列缓存，系列仍然有效时保留
+func isLiveWatermarkedPDF(key string, liveSeries map[int64]bool) bool {
+	rest, found := strings.CutPrefix(key, watermarkedPDFFolder+"/")
+	if !found {
+		return false
+	}
+	seriesID, err := strconv.ParseInt(strings.SplitN(rest, "/", 2)[0], 10, 64)
+	return err == nil && liveSeries[seriesID]
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// 资源在存储中的目录
+const (
+	AssetFolderImages = "images" // 商品图片
+	AssetFolderPDFs   = "pdfs"   // 系列 PDF
+)
+
+// publicAssetFolders 不需要签名即可访问的目录，系列 PDF 等其他文件只能通过签名地址下载
+var publicAssetFolders = []string{AssetFolderImages}
+
+// 后台预览私有文件时签名地址的有效期
+const assetPreviewExpires = time.Hour
+
+// AssetKey 将请求或数据库中的资源地址转换为规范的对象路径，例如 images/xxx.jpg
+// 兼容完整 URL（存储或 CDN 地址）、对象路径和只有文件名三种写法，只有文件名时放在 folder 目录下
+func AssetKey(value, folder string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+
+	if isAbsoluteURL(value) {
+		// 优先去掉已知的访问地址前缀，兼容带路径前缀的地址
+		for _, base := range assetBaseURLs() {
+			if strings.HasPrefix(value, base) {
+				return strings.TrimPrefix(strings.SplitN(value, "?", 2)[0], base)
+			}
+		}
+
+		parsedURL, err := url.Parse(value)
+		if err != nil {
+			return value
+		}
+		value = parsedURL.Path
+	}
+
+	value = strings.TrimPrefix(value, "/")
+	if folder != "" && !strings.Contains(value, "/") {
+		value = folder + "/" + value
+	}
+	return value
+}
+
+// AssetURL 将对象路径转换为访问地址，配置了 ASSET_BASE_URL（CDN）时使用 CDN 地址，否则使用存储的公开地址
+func AssetURL(key string) string {
+	if key == "" || isAbsoluteURL(key) {
+		return key
+	}
+
+	key = strings.TrimPrefix(key, "/")
+	if cdnBaseURL := strings.TrimSuffix(os.Getenv("ASSET_BASE_URL"), "/"); cdnBaseURL != "" {
+		return cdnBaseURL + "/" + key
+	}
+	return storage.PublicURL(key)
+}
+
+// IsPublicAssetKey 判断对象是否在公开目录下
+func IsPublicAssetKey(key string) bool {
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	for _, folder := range publicAssetFolders {
+		if strings.HasPrefix(cleaned, folder+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// PreviewAssetURL 后台预览文件的地址，公开目录下的文件与 AssetURL 相同，其他文件使用限时签名地址
+func PreviewAssetURL(key string) string {
+	if key == "" || isAbsoluteURL(key) || IsPublicAssetKey(key) {
+		return AssetURL(key)
+	}
+
+	signedURL, err := storage.SignedURL(strings.TrimPrefix(key, "/"), assetPreviewExpires, "")
+	if err != nil {
+		log.Printf("生成文件 %s 的预览地址失败: %v", key, err)
+		return AssetURL(key)
+	}
+	return signedURL
+}
+
+// assetBaseURLs 当前配置下资源可能使用的访问地址前缀，均以 / 结尾
+func assetBaseURLs() []string {
+	var bases []string
+	if cdnBaseURL := strings.TrimSuffix(os.Getenv("ASSET_BASE_URL"), "/"); cdnBaseURL != "" {
+		bases = append(bases, cdnBaseURL+"/")
+	}
+	if storage != nil {
+		bases = append(bases, storage.PublicURL(""))
+	}
+	return bases
+}
+
+// isAbsoluteURL 是否为 http(s) 完整地址
+func isAbsoluteURL(value string) bool {
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
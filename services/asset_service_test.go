@@ -0,0 +1,33 @@
+package services
+
+import "testing"
+
+func TestAssetKey(t *testing.T) {
+	// 测试中不初始化存储，只使用 ASSET_BASE_URL 作为已知的访问地址前缀
+	t.Setenv("ASSET_BASE_URL", "https://cdn.example.com/assets/")
+
+	tests := []struct {
+		name   string
+		value  string
+		folder string
+		want   string
+	}{
+		{"空值", "  ", "products", ""},
+		{"只有文件名时加上目录", "a.png", "products", "products/a.png"},
+		{"没有目录参数", "a.png", "", "a.png"},
+		{"已有目录时保持不变", "series/a.pdf", "products", "series/a.pdf"},
+		{"去掉开头的 /", "/products/a.png", "products", "products/a.png"},
+		{"去掉已知前缀", "https://cdn.example.com/assets/products/a.png", "products", "products/a.png"},
+		{"去掉已知前缀和查询参数", "https://cdn.example.com/assets/products/a.png?expires=1&sign=x", "", "products/a.png"},
+		{"未知地址使用路径", "https://other.example.com/products/a.png?x=1", "", "products/a.png"},
+		{"未知地址只有文件名", "https://other.example.com/a.png", "products", "products/a.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AssetKey(tt.value, tt.folder); got != tt.want {
+				t.Errorf("AssetKey(%q, %q) = %q, want %q", tt.value, tt.folder, got, tt.want)
+			}
+		})
+	}
+}
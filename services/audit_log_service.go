@@ -0,0 +1,267 @@
+package services
+
+import (
+	"encoding/json"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 审计日志默认保留天数，可以通过 AUDIT_LOG_RETENTION_DAYS 修改，0 表示永久保留
+const defaultAuditLogRetentionDays = 180
+
+// 每次清理删除的行数，避免长时间锁表
+const auditLogCleanupBatchSize = 5000
+
+// 审计日志中被替换的敏感字段值
+const auditRedacted = "***"
+
+// AuditResource 后台路由对应的对象类型和数据表，用于记录操作前后的数据
+type AuditResource struct {
+	Prefix     string // 后台路由前缀，不含 /api/V1/admin
+	EntityType string
+	Table      string
+}
+
+// auditResources 按前缀从长到短排列，先匹配更具体的路由
+var auditResources = []AuditResource{
+	{"/products/variants", "product_variant", "product_variants"},
+	{"/products/images", "product_image", "product_images"},
+	{"/products", models.EntityProduct, "products"},
+	{"/series", models.EntitySeries, "series"},
+	{"/brands", models.EntityBrand, "brands"},
+	{"/categories", models.EntityCategory, "categories"},
+	{"/frame-materials", models.EntityFrameMaterial, "frame_materials"},
+	{"/drafts", "content_draft", "content_drafts"},
+	{"/sys-users", "sys_user", "sys_users"},
+	{"/sys-roles", "sys_role", "sys_roles"},
+	{"/sys-permissions", "sys_permission", "sys_permissions"},
+	{"/personal-access-tokens", "personal_access_token", "personal_access_tokens"},
+	{"/users", "user", "users"},
+	{"/roles", "role", "roles"},
+}
+
+// auditIDFields 请求体中对象 ID 不是 id / ids 的路由
+var auditIDFields = map[string]string{
+	"/sys-roles/set-permissions": "sys_role_id",
+	"/products/images/reorder":   "image_ids",
+	"/products/images/primary":   "image_id",
+}
+
+// auditRelation 随对象一起记录的关联 ID，例如角色的权限
+type auditRelation struct {
+	Field      string // 记录在审计数据中的字段名
+	JoinTable  string
+	ForeignKey string
+	Column     string
+}
+
+// auditRelations 角色和用户的权限、角色保存在关联表中，需要一起记录才能看出变化
+var auditRelations = map[string]auditRelation{
+	"sys_roles": {"sys_permission_ids", "sys_role_permission", "sys_role_id", "sys_permission_id"},
+	"sys_users": {"sys_role_ids", "sys_user_role", "sys_user_id", "sys_role_id"},
+}
+
+// auditSensitiveKeys 字段名中以下划线分隔的某一段为这些词时在审计日志中脱敏，例如 password、refresh_token
+var auditSensitiveKeys = []string{"password", "token", "secret"}
+
+// FindAuditResource 查询路由对应的对象，没有对应的数据表时返回 nil
+func FindAuditResource(route string) *AuditResource {
+	route = normalizeSysRoute(route)
+	for i := range auditResources {
+		resource := &auditResources[i]
+		if route == resource.Prefix || strings.HasPrefix(route, resource.Prefix+"/") {
+			return resource
+		}
+	}
+	return nil
+}
+
+// AuditEntityIDs 从请求体中取出对象 ID，支持单个 ID 和 ID 列表
+func AuditEntityIDs(route string, body map[string]interface{}) []int64 {
+	field, ok := auditIDFields[normalizeSysRoute(route)]
+	if !ok {
+		field = "id"
+		if _, hasIDs := body["ids"]; hasIDs {
+			field = "ids"
+		}
+	}
+
+	var ids []int64
+	switch value := body[field].(type) {
+	case float64:
+		ids = append(ids, int64(value))
+	case string:
+		if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	case []interface{}:
+		for _, item := range value {
+			if id, ok := item.(float64); ok {
+				ids = append(ids, int64(id))
+			}
+		}
+	}
+	return ids
+}
+
+// LoadAuditRows 读取对象在数据表中的原始数据，包括已软删除的记录，敏感字段已脱敏
+func LoadAuditRows(table string, ids []int64) ([]map[string]interface{}, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var rows []map[string]interface{}
+	if err := config.DB.Table(table).Where("id IN ?", ids).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		RedactAuditValues(row)
+	}
+
+	if relation, ok := auditRelations[table]; ok {
+		var links []struct {
+			OwnerID int64
+			RelID   int64
+		}
+		if err := config.DB.Table(relation.JoinTable).
+			Select(relation.ForeignKey+" AS owner_id, "+relation.Column+" AS rel_id").
+			Where(relation.ForeignKey+" IN ?", ids).Order(relation.Column).Scan(&links).Error; err != nil {
+			return nil, err
+		}
+		related := make(map[int64][]int64)
+		for _, link := range links {
+			related[link.OwnerID] = append(related[link.OwnerID], link.RelID)
+		}
+		for _, row := range rows {
+			id, _ := strconv.ParseInt(fmt.Sprint(row["id"]), 10, 64)
+			row[relation.Field] = append([]int64{}, related[id]...)
+		}
+	}
+	return rows, nil
+}
+
+// RedactAuditValues 递归替换密码、令牌等敏感字段的值
+func RedactAuditValues(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isAuditSensitiveKey(key) {
+				v[key] = auditRedacted
+				continue
+			}
+			RedactAuditValues(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			RedactAuditValues(item)
+		}
+	}
+}
+
+// isAuditSensitiveKey 判断字段名是否为敏感字段
+func isAuditSensitiveKey(key string) bool {
+	for _, part := range strings.Split(strings.ToLower(key), "_") {
+		for _, sensitive := range auditSensitiveKeys {
+			if part == sensitive {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AuditJSON 转换为审计日志的 JSON 字段，空值保存为 NULL
+func AuditJSON(value interface{}) json.RawMessage {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []int64:
+		if len(v) == 0 {
+			return nil
+		}
+	case []map[string]interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("审计日志数据序列化失败: %v", err)
+		return nil
+	}
+	return data
+}
+
+// SaveAuditLog 保存一条审计日志
+func SaveAuditLog(entry *models.AuditLog) error {
+	if len(entry.Message) > 500 {
+		entry.Message = truncateUTF8(entry.Message, 500)
+	}
+	if len(entry.UserAgent) > 255 {
+		entry.UserAgent = truncateUTF8(entry.UserAgent, 255)
+	}
+	return config.DB.Create(entry).Error
+}
+
+// truncateUTF8 按字符截断，保证不超过 max 个字符
+func truncateUTF8(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
+
+// auditLogRetentionDays 审计日志保留天数
+func auditLogRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("AUDIT_LOG_RETENTION_DAYS"))
+	if err != nil || days < 0 {
+		return defaultAuditLogRetentionDays
+	}
+	return days
+}
+
+// CleanupAuditLogs 删除超过保留期的审计日志，返回删除的条数
+func CleanupAuditLogs() (int64, error) {
+	days := auditLogRetentionDays()
+	if days == 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	var deleted int64
+	for {
+		result := config.DB.Where("created_at < ?", cutoff).Limit(auditLogCleanupBatchSize).Delete(&models.AuditLog{})
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+		if result.RowsAffected < auditLogCleanupBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// StartAuditLogCleanup 定时清理过期的审计日志
+func StartAuditLogCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			deleted, err := CleanupAuditLogs()
+			if err != nil {
+				log.Printf("清理审计日志失败: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("已清理 %d 条过期的审计日志", deleted)
+			}
+		}
+	}()
+}
@@ -62,6 +62,11 @@ func buildCategoryTree(category models.Category, db *gorm.DB) map[string]interfa
 
 // GetCategoryID 根据主分类和子分类名称获取对应的 category_id，如果没有找到则插入新记录
 func GetCategoryID(mainCategoryName, subCategoryName string) (int64, error) {
+	return GetCategoryIDWithDB(config.DB, mainCategoryName, subCategoryName)
+}
+
+// GetCategoryIDWithDB 与 GetCategoryID 相同，但使用传入的 db，便于在事务中调用
+func GetCategoryIDWithDB(db *gorm.DB, mainCategoryName, subCategoryName string) (int64, error) {
 
 	// 去除输入名称两边的空白
 	mainCategoryName = strings.TrimSpace(mainCategoryName)
@@ -71,10 +76,10 @@ func GetCategoryID(mainCategoryName, subCategoryName string) (int64, error) {
 	var subCategory models.Category
 
 	// 先查询主分类，如果不存在则创建
-	if err := config.DB.Where("name = ? AND pid = 0", mainCategoryName).First(&mainCategory).Error; err != nil {
+	if err := db.Where("name = ? AND pid = 0", mainCategoryName).First(&mainCategory).Error; err != nil {
 		// 如果没有找到主分类，创建新主分类
 		mainCategory = models.Category{Name: mainCategoryName, Pid: 0} // PID 为 0
-		if createErr := config.DB.Create(&mainCategory).Error; createErr != nil {
+		if createErr := db.Create(&mainCategory).Error; createErr != nil {
 			log.Printf("failed to create main category '%s': %v", mainCategoryName, createErr)
 			return 0, fmt.Errorf("failed to create main category '%s': %v", mainCategoryName, createErr)
 		}
@@ -82,10 +87,10 @@ func GetCategoryID(mainCategoryName, subCategoryName string) (int64, error) {
 	}
 
 	// 查询子分类，如果不存在则创建
-	if err := config.DB.Where("name = ? AND pid = ?", subCategoryName, mainCategory.ID).First(&subCategory).Error; err != nil {
+	if err := db.Where("name = ? AND pid = ?", subCategoryName, mainCategory.ID).First(&subCategory).Error; err != nil {
 		// 如果没有找到子分类，创建新子分类
 		subCategory = models.Category{Name: subCategoryName, Pid: mainCategory.ID} // 子分类的 PID 为主分类的 ID
-		if createErr := config.DB.Create(&subCategory).Error; createErr != nil {
+		if createErr := db.Create(&subCategory).Error; createErr != nil {
 			log.Printf("failed to create sub category '%s' under main category '%s': %v", subCategoryName, mainCategoryName, createErr)
 			return 0, fmt.Errorf("failed to create sub category '%s' under main category '%s': %v", subCategoryName, mainCategoryName, createErr)
 		}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrDraftStatus 草稿当前状态不允许该操作，例如发布未提交审核的草稿
+var ErrDraftStatus = errors.New("draft status does not allow this operation")
+
+// DraftPublisher 在发布事务中把草稿内容写入线上数据，返回事务提交后需要执行的操作（删除带水印的副本、更新索引等）
+type DraftPublisher func(tx *gorm.DB, draft *models.ContentDraft) (afterCommit func(), err error)
+
+// openDraftStatuses 尚未发布的草稿状态
+var openDraftStatuses = []string{models.DraftStatusDraft, models.DraftStatusPending, models.DraftStatusRejected}
+
+// FindOpenDraft 查询对象未发布的草稿，没有时返回 nil
+func FindOpenDraft(entityType string, entityID int64) (*models.ContentDraft, error) {
+	var draft models.ContentDraft
+	err := config.DB.Where("entity_type = ? AND entity_id = ? AND status IN ?", entityType, entityID, openDraftStatuses).
+		Order("id DESC").First(&draft).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// SaveDraft 保存对象的修改草稿。已有未发布的草稿时覆盖其内容，并退回编辑状态需要重新提交审核
+func SaveDraft(entityType string, entityID int64, payload interface{}, sysUserID int64) (*models.ContentDraft, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode draft: %v", err)
+	}
+
+	var draft models.ContentDraft
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("entity_type = ? AND entity_id = ? AND status IN ?", entityType, entityID, openDraftStatuses).
+			Order("id DESC").First(&draft).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			draft = models.ContentDraft{
+				EntityType: entityType,
+				EntityID:   entityID,
+				Payload:    data,
+				Status:     models.DraftStatusDraft,
+				CreatedBy:  &sysUserID,
+				UpdatedBy:  &sysUserID,
+			}
+			return tx.Create(&draft).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		draft.Payload = data
+		draft.Status = models.DraftStatusDraft
+		draft.UpdatedBy = &sysUserID
+		draft.SubmittedBy = nil
+		draft.SubmittedAt = nil
+		return tx.Save(&draft).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// SubmitDraft 提交草稿等待审核，被驳回的草稿也可以直接重新提交
+func SubmitDraft(id, sysUserID int64) (*models.ContentDraft, error) {
+	return transitionDraft(id, []string{models.DraftStatusDraft, models.DraftStatusRejected}, func(draft *models.ContentDraft) {
+		now := time.Now()
+		draft.Status = models.DraftStatusPending
+		draft.SubmittedBy = &sysUserID
+		draft.SubmittedAt = &now
+	})
+}
+
+// RejectDraft 驳回待审核的草稿
+func RejectDraft(id, reviewerID int64, comment string) (*models.ContentDraft, error) {
+	return transitionDraft(id, []string{models.DraftStatusPending}, func(draft *models.ContentDraft) {
+		now := time.Now()
+		draft.Status = models.DraftStatusRejected
+		draft.ReviewedBy = &reviewerID
+		draft.ReviewedAt = &now
+		draft.ReviewComment = comment
+	})
+}
+
+// DiscardDraft 删除未发布的草稿，已发布的草稿作为记录保留
+func DiscardDraft(id int64) error {
+	_, err := transitionDraft(id, openDraftStatuses, nil)
+	return err
+}
+
+// transitionDraft 草稿处于 from 中的状态时执行 update 并保存，update 为 nil 时删除草稿
+func transitionDraft(id int64, from []string, update func(draft *models.ContentDraft)) (*models.ContentDraft, error) {
+	var draft models.ContentDraft
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&draft, id).Error; err != nil {
+			return err
+		}
+		if !draftStatusIn(draft.Status, from) {
+			return ErrDraftStatus
+		}
+		if update == nil {
+			return tx.Delete(&draft).Error
+		}
+		update(&draft)
+		return tx.Save(&draft).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// PublishDraft 审核通过并发布草稿，草稿内容和状态在同一个事务中写入，失败时都不会生效
+func PublishDraft(id, reviewerID int64, comment string, publish DraftPublisher) (*models.ContentDraft, error) {
+	var (
+		draft       models.ContentDraft
+		afterCommit func()
+	)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定草稿，避免同一份草稿被并发发布两次
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&draft, id).Error; err != nil {
+			return err
+		}
+		if draft.Status != models.DraftStatusPending {
+			return ErrDraftStatus
+		}
+
+		var err error
+		if afterCommit, err = publish(tx, &draft); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		draft.Status = models.DraftStatusPublished
+		draft.ReviewedBy = &reviewerID
+		draft.ReviewedAt = &now
+		draft.ReviewComment = comment
+		draft.PublishedAt = &now
+		return tx.Save(&draft).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if afterCommit != nil {
+		afterCommit()
+	}
+	return &draft, nil
+}
+
+// draftStatusIn 判断草稿状态是否在列表中
+func draftStatusIn(status string, statuses []string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
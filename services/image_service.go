@@ -0,0 +1,419 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"exam_server/models"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/guregu/null/v5"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+	"golang.org/x/sync/errgroup"
+)
+
+// imageVariantSizes 生成的图片尺寸及最长边（像素），原图小于该尺寸时不放大
+var imageVariantSizes = []struct {
+	Name    string
+	MaxSize int
+}{
+	{"thumb", 200},
+	{"medium", 600},
+	{"large", 1200},
+}
+
+// JPEG 重新编码的质量
+const imageJPEGQuality = 88
+
+// ImageVariantURL 返回给前端的图片尺寸
+type ImageVariantURL struct {
+	URL     string `json:"url"`
+	WebPURL string `json:"webp_url,omitempty"`
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+}
+
+// ProcessedImage 处理后的图片，Key 为去掉元数据后的原图
+type ProcessedImage struct {
+	Key      string
+	Width    int
+	Height   int
+	Variants models.ImageVariants
+}
+
+// ProcessStoredImage 处理直传到存储中的图片：去掉元数据后覆盖原图，并生成各尺寸及 WebP 版本
+func ProcessStoredImage(key string) (*ProcessedImage, error) {
+	body, err := storage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			log.Printf("关闭文件错误: %v", err)
+		}
+	}()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %v", err)
+	}
+
+	return processImage(data, key)
+}
+
+// processImage 处理图片并将原图和各尺寸上传到 key 及其派生路径
+func processImage(data []byte, key string) (*ProcessedImage, error) {
+	ext := strings.ToLower(path.Ext(key))
+	var err error
+
+	// GIF 可能是动图，重新编码会丢失动画，原图保持不变（GIF 不含 EXIF）
+	var original []byte
+	var img image.Image
+	if ext == ".gif" {
+		img, err = gif.Decode(bytes.NewReader(data))
+		original = data
+	} else {
+		img, err = decodeImage(data, ext)
+		if err == nil {
+			img = applyOrientation(img, jpegOrientation(data))
+			original, err = encodeImage(img, ext)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to process image %s: %v", key, err)
+	}
+
+	bounds := img.Bounds()
+	processed := &ProcessedImage{
+		Key:      key,
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		Variants: make(models.ImageVariants, len(imageVariantSizes)),
+	}
+
+	uploads := map[string][]byte{key: original}
+	for _, size := range imageVariantSizes {
+		resized := resizeImage(img, size.MaxSize)
+		variant, err := encodeImage(resized, variantExt(ext))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s variant: %v", size.Name, err)
+		}
+		var webpData bytes.Buffer
+		if err := nativewebp.Encode(&webpData, resized, nil); err != nil {
+			return nil, fmt.Errorf("failed to encode %s WebP variant: %v", size.Name, err)
+		}
+
+		v := imageVariantKeys(key, size.Name)
+		v.Width, v.Height = resized.Bounds().Dx(), resized.Bounds().Dy()
+		processed.Variants[size.Name] = v
+		uploads[v.Key] = variant
+		uploads[v.WebPKey] = webpData.Bytes()
+	}
+
+	var g errgroup.Group
+	for objectKey, content := range uploads {
+		objectKey, content := objectKey, content
+		g.Go(func() error {
+			return storage.Put(objectKey, bytes.NewReader(content), imageContentType(path.Ext(objectKey)))
+		})
+	}
+	if err := g.Wait(); err != nil {
+		// 删除已上传的部分文件
+		keys := make([]string, 0, len(uploads))
+		for objectKey := range uploads {
+			keys = append(keys, objectKey)
+		}
+		if err := storage.DeleteBatch(keys); err != nil {
+			log.Printf("删除未完成上传的图片失败: %v", err)
+		}
+		return nil, fmt.Errorf("failed to upload image: %v", err)
+	}
+
+	return processed, nil
+}
+
+// DescribeImage 根据已上传图片的对象路径读取尺寸，并找出处理流程生成的各尺寸图片
+// 旧图片没有生成过其他尺寸，此时 Variants 为空
+func DescribeImage(key string) (*ProcessedImage, error) {
+	body, err := storage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			log.Printf("关闭文件错误: %v", err)
+		}
+	}()
+
+	config, _, err := image.DecodeConfig(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %v", key, err)
+	}
+
+	processed := &ProcessedImage{Key: key, Width: config.Width, Height: config.Height}
+
+	// 缩略图不存在说明不是通过处理流程上传的
+	if _, err := storage.Stat(imageVariantKeys(key, imageVariantSizes[0].Name).Key); err != nil {
+		return processed, nil
+	}
+
+	processed.Variants = make(models.ImageVariants, len(imageVariantSizes))
+	for _, size := range imageVariantSizes {
+		v := imageVariantKeys(key, size.Name)
+		v.Width, v.Height = scaledSize(config.Width, config.Height, size.MaxSize)
+		processed.Variants[size.Name] = v
+	}
+	return processed, nil
+}
+
+// ProductImage 转换为商品图片记录
+func (p *ProcessedImage) ProductImage(productID int64) models.ProductImage {
+	return models.ProductImage{
+		ProductID: null.IntFrom(productID),
+		ImageURL:  p.Key,
+		Width:     p.Width,
+		Height:    p.Height,
+		Variants:  p.Variants,
+	}
+}
+
+// NewProductImage 创建商品图片记录，尺寸信息读取失败时只保存对象路径
+func NewProductImage(productID int64, key string) models.ProductImage {
+	processed, err := DescribeImage(key)
+	if err != nil {
+		log.Printf("读取图片 %s 的尺寸失败: %v", key, err)
+		return models.ProductImage{ProductID: null.IntFrom(productID), ImageURL: key}
+	}
+	return processed.ProductImage(productID)
+}
+
+// ProductImageKeys 商品图片的原图及所有尺寸的对象路径，删除图片时使用
+func ProductImageKeys(productImages []models.ProductImage) []string {
+	keys := make([]string, 0, len(productImages))
+	for _, productImage := range productImages {
+		keys = append(keys, productImage.ImageURL)
+		for _, v := range productImage.Variants {
+			keys = append(keys, v.Key, v.WebPKey)
+		}
+	}
+	return keys
+}
+
+// ImageVariantURLs 返回图片各尺寸的访问地址，original 为原图，旧图片的各尺寸都使用原图
+func ImageVariantURLs(productImage models.ProductImage) map[string]ImageVariantURL {
+	originalURL := AssetURL(productImage.ImageURL)
+	urls := map[string]ImageVariantURL{
+		"original": {URL: originalURL, Width: productImage.Width, Height: productImage.Height},
+	}
+	for _, size := range imageVariantSizes {
+		v, exists := productImage.Variants[size.Name]
+		if !exists {
+			urls[size.Name] = ImageVariantURL{URL: originalURL, Width: productImage.Width, Height: productImage.Height}
+			continue
+		}
+		urls[size.Name] = ImageVariantURL{
+			URL:     AssetURL(v.Key),
+			WebPURL: AssetURL(v.WebPKey),
+			Width:   v.Width,
+			Height:  v.Height,
+		}
+	}
+	return urls
+}
+
+// imageVariantKeys 各尺寸的对象路径：{原图路径去掉扩展名}_{尺寸名}.{jpg|png|webp}
+func imageVariantKeys(key, name string) models.ImageVariant {
+	ext := path.Ext(key)
+	base := strings.TrimSuffix(key, ext)
+	return models.ImageVariant{
+		Key:     fmt.Sprintf("%s_%s%s", base, name, variantExt(strings.ToLower(ext))),
+		WebPKey: fmt.Sprintf("%s_%s.webp", base, name),
+	}
+}
+
+// variantExt PNG、GIF 可能有透明背景，缩略图保存为 PNG，其余保存为 JPEG
+func variantExt(ext string) string {
+	if ext == ".png" || ext == ".gif" {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// decodeImage 按扩展名解码图片
+func decodeImage(data []byte, ext string) (image.Image, error) {
+	switch ext {
+	case ".webp":
+		return webp.Decode(bytes.NewReader(data))
+	case ".png":
+		return png.Decode(bytes.NewReader(data))
+	default:
+		return jpeg.Decode(bytes.NewReader(data))
+	}
+}
+
+// encodeImage 按扩展名编码图片，编码结果不包含任何元数据
+func encodeImage(img image.Image, ext string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch ext {
+	case ".png":
+		err = png.Encode(&buf, img)
+	case ".webp":
+		err = nativewebp.Encode(&buf, img, nil)
+	default:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: imageJPEGQuality})
+	}
+	return buf.Bytes(), err
+}
+
+// imageContentType 上传时使用的 Content-Type
+func imageContentType(ext string) string {
+	switch ext {
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	default:
+		return "image/jpeg"
+	}
+}
+
+// resizeImage 等比缩放到最长边不超过 maxSize
+func resizeImage(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := scaledSize(bounds.Dx(), bounds.Dy(), maxSize)
+	if width == bounds.Dx() && height == bounds.Dy() {
+		return img
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// scaledSize 等比缩放后的尺寸，不放大
+func scaledSize(width, height, maxSize int) (int, int) {
+	if width <= maxSize && height <= maxSize {
+		return width, height
+	}
+	if width >= height {
+		return maxSize, max(1, height*maxSize/width)
+	}
+	return max(1, width*maxSize/height), maxSize
+}
+
+// jpegOrientation 读取 JPEG 中 EXIF 的方向，没有时返回 1
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// 图像数据开始后不会再有 EXIF
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation 从 TIFF 结构的 IFD0 中读取 Orientation（0x0112）
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// applyOrientation 按 EXIF 方向旋转或翻转图片，去掉 EXIF 后图片方向仍然正确
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = w-1-x, y
+			case 3: // 旋转 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针旋转 90°
+				sx, sy = y, h-1-x
+			case 7: // 沿右上-左下对角线翻转
+				sx, sy = w-1-y, h-1-x
+			case 8: // 逆时针旋转 90°
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// testEXIFJPEG 构造只包含 APP1 EXIF 段的 JPEG，IFD0 中只有一个 Orientation 标签
+func testEXIFJPEG(order binary.AppendByteOrder, orientation uint16) []byte {
+	tiff := []byte("II")
+	if order == binary.AppendByteOrder(binary.BigEndian) {
+		tiff = []byte("MM")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, 0x0112)
+	tiff = order.AppendUint16(tiff, 3) // SHORT
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0)
+	tiff = order.AppendUint32(tiff, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+	return append(data, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	// APP0 段在 APP1 之前，需要跳过
+	withAPP0 := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00}
+	withAPP0 = append(withAPP0, testEXIFJPEG(binary.BigEndian, 3)[2:]...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"Intel 字节序", testEXIFJPEG(binary.LittleEndian, 6), 6},
+		{"Motorola 字节序", testEXIFJPEG(binary.BigEndian, 8), 8},
+		{"先跳过其它段", withAPP0, 3},
+		{"方向值超出范围", testEXIFJPEG(binary.LittleEndian, 9), 1},
+		{"没有 EXIF", []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9}, 1},
+		{"EXIF 段被截断", testEXIFJPEG(binary.LittleEndian, 6)[:20], 1},
+		{"不是 JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"空数据", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStorageRoute 本地存储的文件通过 Gin 在该路径下提供访问
+const LocalStorageRoute = "/storage"
+
+// 本地存储签名地址校验失败
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrSignatureExpired = errors.New("signature expired")
+)
+
+// LocalUploadPolicy 本地存储直传签名中的限制
+type LocalUploadPolicy struct {
+	ContentType string
+	MaxSize     int64
+}
+
+// LocalStorage 本地磁盘存储，用于开发和 CI 环境
+// 只有公开目录（见 IsPublicAssetKey）下的文件可以通过公开地址访问，其他文件需要带有效期的签名地址；
+// 签名地址还可以指定下载文件名
+type LocalStorage struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+// newLocalStorage 使用 STORAGE_LOCAL_DIR、STORAGE_BASE_URL 初始化本地存储
+// 签名密钥使用 STORAGE_SIGN_SECRET，未配置时使用 JWT_SECRET，都没有配置时返回错误
+func newLocalStorage() (*LocalStorage, error) {
+	root := os.Getenv("STORAGE_LOCAL_DIR")
+	if root == "" {
+		root = "storage"
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORAGE_LOCAL_DIR: %v", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %v", err)
+	}
+
+	// 访问地址需要与 Gin 的监听地址一致
+	baseURL := strings.TrimSuffix(os.Getenv("STORAGE_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8082" + LocalStorageRoute
+	}
+
+	secret := os.Getenv("STORAGE_SIGN_SECRET")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET")
+	}
+	if secret == "" {
+		return nil, errors.New("STORAGE_SIGN_SECRET or JWT_SECRET must be set for local storage signed URLs")
+	}
+
+	return &LocalStorage{root: root, baseURL: baseURL, secret: []byte(secret)}, nil
+}
+
+// FilePath 返回对象在磁盘上的路径，路径先按根目录清理，不会跳出存储目录
+func (s *LocalStorage) FilePath(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid object key: %s", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *LocalStorage) Put(key string, src io.Reader, contentType string) error {
+	filePath, err := s.FilePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(tmp.Name()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("删除临时文件错误: %v", err)
+		}
+	}()
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	filePath, err := s.FilePath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	filePath, err := s.FilePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %v", err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) DeleteBatch(keys []string) error {
+	for _, key := range keys {
+		if err := s.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *LocalStorage) Stat(key string) (*ObjectInfo, error) {
+	filePath, err := s.FilePath(key)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, ErrObjectNotFound
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         stat.Size(),
+		ContentType:  mime.TypeByExtension(filepath.Ext(key)),
+		LastModified: stat.ModTime(),
+	}, nil
+}
+
+func (s *LocalStorage) List(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := filepath.WalkDir(s.root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		stat, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:          key,
+			Size:         stat.Size(),
+			ContentType:  mime.TypeByExtension(filepath.Ext(key)),
+			LastModified: stat.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %v", err)
+	}
+	return objects, nil
+}
+
+func (s *LocalStorage) SignedURL(key string, expires time.Duration, filename string) (string, error) {
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expiresAt)
+	if filename != "" {
+		query.Set("filename", filename)
+	}
+	query.Set("signature", s.sign(http.MethodGet, key, expiresAt, filename))
+
+	return s.PublicURL(key) + "?" + query.Encode(), nil
+}
+
+// PresignUpload 生成直传地址，浏览器使用 PUT 上传到 Gin 提供的本地存储路由
+func (s *LocalStorage) PresignUpload(key, contentType string, maxSize int64, expires time.Duration) (*PresignedUpload, error) {
+	expiresAt := time.Now().Add(expires)
+	expiresUnix := strconv.FormatInt(expiresAt.Unix(), 10)
+	size := strconv.FormatInt(maxSize, 10)
+
+	query := url.Values{}
+	query.Set("expires", expiresUnix)
+	query.Set("max_size", size)
+	query.Set("content_type", contentType)
+	query.Set("signature", s.sign(http.MethodPut, key, expiresUnix, size, contentType))
+
+	return &PresignedUpload{
+		Method:    http.MethodPut,
+		URL:       s.PublicURL(key) + "?" + query.Encode(),
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// VerifyUploadURL 校验直传地址的签名，返回签名中的限制
+func (s *LocalStorage) VerifyUploadURL(key string, query url.Values) (*LocalUploadPolicy, error) {
+	expiresAt := query.Get("expires")
+	size := query.Get("max_size")
+	contentType := query.Get("content_type")
+
+	expected := s.sign(http.MethodPut, key, expiresAt, size, contentType)
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(expected)) {
+		return nil, ErrInvalidSignature
+	}
+
+	expiresUnix, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if time.Now().Unix() > expiresUnix {
+		return nil, ErrSignatureExpired
+	}
+
+	maxSize, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	return &LocalUploadPolicy{ContentType: contentType, MaxSize: maxSize}, nil
+}
+
+func (s *LocalStorage) PublicURL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// VerifySignedURL 校验签名地址的参数，只有公开目录（商品图片）下的文件可以不带签名访问
+func (s *LocalStorage) VerifySignedURL(key string, query url.Values) error {
+	signature := query.Get("signature")
+	if signature == "" && query.Get("expires") == "" && IsPublicAssetKey(key) {
+		return nil
+	}
+
+	expiresAt := query.Get("expires")
+	expected := s.sign(http.MethodGet, key, expiresAt, query.Get("filename"))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+
+	expiresUnix, err := strconv.ParseInt(expiresAt, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expiresUnix {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+// sign 对请求方法、对象路径、过期时间等参数签名，下载和上传的签名不能混用
+func (s *LocalStorage) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
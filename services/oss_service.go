@@ -1,106 +1,199 @@
 package services
 
 import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"exam_server/config"
 	"fmt"
+	"io"
 	"log"
-	"mime/multipart"
+	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 )
 
-// getBucket 获取OSS bucket的公共方法
-func getBucket() (*oss.Bucket, error) {
-	bucket, err := config.OssClient.Bucket(os.Getenv("OSS_BUCKET_NAME"))
+// 阿里云 OSS 每次最多删除 1000 个文件
+const ossDeleteBatchSize = 1000
+
+// ossStorage 阿里云 OSS 存储
+type ossStorage struct {
+	bucket  *oss.Bucket
+	baseURL string
+}
+
+// newOSSStorage 使用 OSS_* 配置初始化 OSS 存储
+func newOSSStorage() (*ossStorage, error) {
+	config.InitOSS()
+
+	bucketName := os.Getenv("OSS_BUCKET_NAME")
+	bucket, err := config.OssClient.Bucket(bucketName)
 	if err != nil {
 		log.Printf("Failed to get bucket: %v", err)
 		return nil, fmt.Errorf("failed to get OSS bucket: %v", err)
 	}
-	return bucket, nil
+
+	// 未配置 OSS_BASE_URL 时使用 bucket 的默认域名
+	baseURL := strings.TrimSuffix(os.Getenv("OSS_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s.%s", bucketName, os.Getenv("OSS_ENDPOINT"))
+	}
+
+	return &ossStorage{bucket: bucket, baseURL: baseURL}, nil
 }
 
-// UploadFilesToOSS 上传多个文件到阿里云OSS
-func UploadFilesToOSS(files []*multipart.FileHeader, folderName string) ([]string, error) {
-	bucket, err := getBucket()
-	if err != nil {
-		return nil, err
+func (s *ossStorage) Put(key string, src io.Reader, contentType string) error {
+	var options []oss.Option
+	if contentType != "" {
+		options = append(options, oss.ContentType(contentType))
 	}
+	if err := s.bucket.PutObject(key, src, options...); err != nil {
+		return fmt.Errorf("failed to upload file to OSS: %v", err)
+	}
+	return nil
+}
 
-	var fileURLs []string
-	for _, file := range files {
-		src, err := file.Open()
-		if err != nil {
-			return nil, fmt.Errorf("failed to open file: %v", err)
+func (s *ossStorage) Get(key string) (io.ReadCloser, error) {
+	body, err := s.bucket.GetObject(key)
+	if err != nil {
+		if isOSSNotFound(err) {
+			return nil, ErrObjectNotFound
 		}
+		return nil, fmt.Errorf("failed to download file from OSS: %v", err)
+	}
+	return body, nil
+}
 
-		defer func() {
-			if err := src.Close(); err != nil {
-				log.Printf("关闭文件错误: %v", err)
-			}
-		}()
+func (s *ossStorage) Delete(key string) error {
+	if err := s.bucket.DeleteObject(key); err != nil {
+		return fmt.Errorf("failed to delete file from OSS: %v", err)
+	}
+	return nil
+}
 
-		fileName := fmt.Sprintf("%s/%d_%s", folderName, time.Now().UnixNano(), file.Filename)
-		if err = bucket.PutObject(fileName, src); err != nil {
-			return nil, fmt.Errorf("failed to upload file to OSS: %v", err)
+func (s *ossStorage) DeleteBatch(keys []string) error {
+	for i := 0; i < len(keys); i += ossDeleteBatchSize {
+		end := i + ossDeleteBatchSize
+		if end > len(keys) {
+			end = len(keys)
 		}
 
-		fileURL := fmt.Sprintf("https://%s.%s/%s",
-			os.Getenv("OSS_BUCKET_NAME"),
-			os.Getenv("OSS_ENDPOINT"),
-			fileName)
-		fileURLs = append(fileURLs, fileURL)
+		batch := keys[i:end]
+		if _, err := s.bucket.DeleteObjects(batch, oss.DeleteObjectsQuiet(true)); err != nil {
+			log.Printf("Failed to delete batch files from OSS: %v", err)
+			return err
+		}
 	}
-
-	return fileURLs, nil
+	return nil
 }
 
-// DeleteFilesFromOSS 批量删除OSS文件
-func DeleteFilesFromOSS(urls []string) error {
-	if len(urls) == 0 {
-		return nil
+func (s *ossStorage) Stat(key string) (*ObjectInfo, error) {
+	header, err := s.bucket.GetObjectMeta(key)
+	if err != nil {
+		if isOSSNotFound(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to get file meta from OSS: %v", err)
 	}
 
-	bucket, err := getBucket()
-	if err != nil {
-		return err
+	info := &ObjectInfo{
+		Key:         key,
+		ContentType: header.Get("Content-Type"),
 	}
+	info.Size, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	info.LastModified, _ = http.ParseTime(header.Get("Last-Modified"))
+	return info, nil
+}
 
-	objectKeys := make([]string, 0, len(urls))
-	for _, fileURL := range urls {
-		if strings.Contains(fileURL, "http") {
-			parsedURL, err := url.Parse(fileURL)
-			if err != nil {
-				log.Printf("Invalid URL format: %s", fileURL)
-				continue
-			}
-			objectKeys = append(objectKeys, strings.TrimPrefix(parsedURL.Path, "/"))
-		} else {
-			objectKeys = append(objectKeys, fileURL)
+func (s *ossStorage) List(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	continuationToken := ""
+	for {
+		result, err := s.bucket.ListObjectsV2(oss.Prefix(prefix), oss.ContinuationToken(continuationToken))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files from OSS: %v", err)
+		}
+		for _, object := range result.Objects {
+			objects = append(objects, ObjectInfo{
+				Key:          object.Key,
+				Size:         object.Size,
+				ContentType:  object.Type,
+				LastModified: object.LastModified,
+			})
 		}
+		if !result.IsTruncated {
+			return objects, nil
+		}
+		continuationToken = result.NextContinuationToken
 	}
+}
 
-	if len(objectKeys) == 0 {
-		return nil
+func (s *ossStorage) SignedURL(key string, expires time.Duration, filename string) (string, error) {
+	options := []oss.Option{}
+	if filename != "" {
+		disposition := fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(filename))
+		options = append(options, oss.ResponseContentDisposition(disposition))
 	}
 
-	// 分批处理，阿里云 OSS 每次最多删除 1000 个文件
-	const batchSize = 1000
-	for i := 0; i < len(objectKeys); i += batchSize {
-		end := i + batchSize
-		if end > len(objectKeys) {
-			end = len(objectKeys)
-		}
+	signedURL, err := s.bucket.SignURL(key, oss.HTTPGet, int64(expires.Seconds()), options...)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign OSS URL: %v", err)
+	}
+	return signedURL, nil
+}
 
-		batch := objectKeys[i:end]
-		if _, err := bucket.DeleteObjects(batch, oss.DeleteObjectsQuiet(true)); err != nil {
-			log.Printf("Failed to delete batch files from OSS: %v", err)
-			return err
-		}
+func (s *ossStorage) PublicURL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// PresignUpload 生成 OSS PostObject 的表单签名，bucket 需要配置允许前端域名跨域 POST
+func (s *ossStorage) PresignUpload(key, contentType string, maxSize int64, expires time.Duration) (*PresignedUpload, error) {
+	expiresAt := time.Now().Add(expires)
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": expiresAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"conditions": []interface{}{
+			map[string]string{"bucket": s.bucket.BucketName},
+			[]interface{}{"eq", "$key", key},
+			[]interface{}{"eq", "$Content-Type", contentType},
+			[]interface{}{"content-length-range", 1, maxSize},
+		},
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	conf := s.bucket.GetConfig()
+	encodedPolicy := base64.StdEncoding.EncodeToString(policy)
+	mac := hmac.New(sha1.New, []byte(conf.AccessKeySecret))
+	mac.Write([]byte(encodedPolicy))
+
+	return &PresignedUpload{
+		Method: http.MethodPost,
+		URL:    fmt.Sprintf("https://%s.%s", s.bucket.BucketName, os.Getenv("OSS_ENDPOINT")),
+		Fields: map[string]string{
+			"key":                   key,
+			"policy":                encodedPolicy,
+			"OSSAccessKeyId":        conf.AccessKeyID,
+			"Signature":             base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+			"Content-Type":          contentType,
+			"success_action_status": "200",
+		},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// isOSSNotFound 判断 OSS 返回的是否为文件不存在
+func isOSSNotFound(err error) bool {
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) {
+		return serviceErr.StatusCode == http.StatusNotFound
+	}
+	return false
 }
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"exam_server/models"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/sync/singleflight"
+)
+
+// 带水印 PDF 在存储中的目录，按系列分子目录：pdfs/watermarked/{seriesID}/{userID}_{hash}.pdf
+const watermarkedPDFFolder = "pdfs/watermarked"
+
+// 水印内容的版本，修改水印内容后递增，已缓存的旧副本会重新生成
+const watermarkVersion = "2"
+
+// 默认水印字体只支持拉丁字符，公司名为中文时需要通过 PDF_FONT_FILE 指定 TTF 字体
+const defaultWatermarkFont = "Helvetica"
+
+var (
+	watermarkFontOnce sync.Once
+	watermarkFontName = defaultWatermarkFont
+
+	// 同一用户同一系列并发下载时只生成一次
+	watermarkGroup singleflight.Group
+)
+
+// WatermarkedSeriesPDF 返回带有用户水印的系列 PDF 在存储中的路径
+// 每页都会盖上用户邮箱和公司；结果按用户和系列缓存，源文件或用户信息变化后重新生成
+// 缓存的副本会被多次下载，因此水印中不包含时间，每次下载的时间记录在 pdf_downloads 中
+func WatermarkedSeriesPDF(series *models.Series, user *models.User) (string, error) {
+	if series.PdfURL == "" {
+		return "", fmt.Errorf("series %d has no PDF", series.ID)
+	}
+
+	// 源文件、邮箱、公司或水印内容变化时缓存自动失效
+	sum := sha256.Sum256([]byte(strings.Join([]string{watermarkVersion, series.PdfURL, user.Email, user.Company}, "|")))
+	objectKey := fmt.Sprintf("%s/%d/%d_%s.pdf", watermarkedPDFFolder, series.ID, user.ID, hex.EncodeToString(sum[:8]))
+
+	_, err, _ := watermarkGroup.Do(objectKey, func() (interface{}, error) {
+		_, err := storage.Stat(objectKey)
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, ErrObjectNotFound) {
+			return nil, fmt.Errorf("failed to check watermarked PDF: %v", err)
+		}
+
+		return nil, generateWatermarkedPDF(series.PdfURL, objectKey, watermarkText(user))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return objectKey, nil
+}
+
+// DeleteWatermarkedSeriesPDFs 删除系列的所有带水印 PDF，系列 PDF 被替换或系列被删除时调用
+func DeleteWatermarkedSeriesPDFs(seriesID int64) error {
+	prefix := fmt.Sprintf("%s/%d/", watermarkedPDFFolder, seriesID)
+	objects, err := storage.List(prefix)
+	if err != nil {
+		return fmt.Errorf("failed to list watermarked PDFs: %v", err)
+	}
+
+	objectKeys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		objectKeys = append(objectKeys, object.Key)
+	}
+	return DeleteFiles(objectKeys)
+}
+
+// generateWatermarkedPDF 下载源 PDF，为每页添加水印后上传到 objectKey
+func generateWatermarkedPDF(pdfURL, objectKey, text string) error {
+	body, err := storage.Get(AssetKey(pdfURL, AssetFolderPDFs))
+	if err != nil {
+		return fmt.Errorf("failed to download PDF: %v", err)
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			log.Printf("关闭文件错误: %v", err)
+		}
+	}()
+
+	source, err := io.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read PDF: %v", err)
+	}
+
+	watermarkFontOnce.Do(initWatermarkFont)
+
+	// 斜向铺在页面中间，半透明盖在内容之上，避免被页面背景遮住
+	description := fmt.Sprintf("fontname:%s, points:36, scalefactor:0.8 rel, rotation:45, opacity:0.2, fillcolor:#808080", watermarkFontName)
+	watermark, err := api.TextWatermark(text, description, true, false, types.POINTS)
+	if err != nil {
+		return fmt.Errorf("failed to create watermark: %v", err)
+	}
+
+	var output bytes.Buffer
+	if err := api.AddWatermarks(bytes.NewReader(source), &output, nil, watermark, nil); err != nil {
+		return fmt.Errorf("failed to watermark PDF: %v", err)
+	}
+
+	if err := storage.Put(objectKey, &output, "application/pdf"); err != nil {
+		return fmt.Errorf("failed to upload watermarked PDF: %v", err)
+	}
+
+	log.Printf("已生成带水印的PDF: %s", objectKey)
+	return nil
+}
+
+// watermarkText 水印内容：邮箱和公司，每项一行
+func watermarkText(user *models.User) string {
+	lines := []string{user.Email}
+	if user.Company != "" {
+		lines = append(lines, user.Company)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pdfFontFile 水印和目录使用的 TTF 字体，兼容最初的 PDF_WATERMARK_FONT_FILE 配置
+func pdfFontFile() string {
+	if fontFile := os.Getenv("PDF_FONT_FILE"); fontFile != "" {
+		return fontFile
+	}
+	return os.Getenv("PDF_WATERMARK_FONT_FILE")
+}
+
+// initWatermarkFont 安装 PDF_FONT_FILE 指定的字体，未配置时使用内置字体
+func initWatermarkFont() {
+	fontFile := pdfFontFile()
+	if fontFile == "" {
+		// 不使用自定义字体时不需要 pdfcpu 的配置目录
+		api.DisableConfigDir()
+		return
+	}
+
+	// 每次启动使用新的配置目录，便于找出本次安装的字体名
+	configDir, err := os.MkdirTemp("", "exam_server_pdfcpu")
+	if err == nil {
+		err = api.EnsureDefaultConfigAt(configDir)
+	}
+	if err != nil {
+		log.Printf("初始化PDF配置目录失败，使用默认水印字体: %v", err)
+		api.DisableConfigDir()
+		return
+	}
+
+	installed := make(map[string]bool)
+	for _, name := range font.UserFontNames() {
+		installed[name] = true
+	}
+
+	if err := api.InstallFonts([]string{fontFile}); err != nil {
+		log.Printf("安装水印字体 %s 失败，使用默认水印字体: %v", fontFile, err)
+		return
+	}
+
+	// 字体名以 TTF 中的名称为准，取本次新安装的字体
+	for _, name := range font.UserFontNames() {
+		if !installed[name] {
+			watermarkFontName = name
+			return
+		}
+	}
+	log.Printf("未找到水印字体 %s，使用默认水印字体", fontFile)
+}
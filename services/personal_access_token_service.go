@@ -0,0 +1,234 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 个人访问令牌所属的用户类型，与 JWT 中的后台用户类型保持一致
+const PersonalAccessTokenTypeSysUser = "sys_user"
+
+// PersonalAccessTokenAbilityAll 拥有令牌所属用户的全部权限
+const PersonalAccessTokenAbilityAll = "*"
+
+// 最后使用时间的更新间隔，避免每个请求都写库
+const personalAccessTokenTouchInterval = time.Minute
+
+var (
+	ErrPersonalAccessTokenInvalid = errors.New("invalid personal access token")
+	ErrPersonalAccessTokenExpired = errors.New("personal access token expired")
+	ErrInvalidTokenAbilities      = errors.New("abilities must be * or existing sys_permissions names")
+	ErrTokenAbilitiesNotGranted   = errors.New("abilities are not granted to the token owner")
+)
+
+// CreatePersonalAccessToken 为后台用户创建个人访问令牌，返回记录和仅展示一次的明文令牌
+// 权限范围必须是令牌所属用户通过角色拥有的权限
+func CreatePersonalAccessToken(sysUserID int64, name string, abilities []string, expiresAt *time.Time) (*models.PersonalAccessToken, string, error) {
+	abilities, err := normalizeAbilities(abilities)
+	if err != nil {
+		return nil, "", err
+	}
+	if abilities[0] != PersonalAccessTokenAbilityAll {
+		granted, err := sysUserHoldsPermissions(sysUserID, abilities)
+		if err != nil {
+			return nil, "", err
+		}
+		if !granted {
+			return nil, "", ErrTokenAbilitiesNotGranted
+		}
+	}
+
+	abilitiesJSON, err := json.Marshal(abilities)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := randomTokenSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := models.PersonalAccessToken{
+		TokenableType: PersonalAccessTokenTypeSysUser,
+		TokenableID:   sysUserID,
+		Name:          name,
+		Token:         hashTokenSecret(secret),
+		Abilities:     string(abilitiesJSON),
+		ExpiresAt:     expiresAt,
+	}
+	if err := config.DB.Create(&token).Error; err != nil {
+		return nil, "", err
+	}
+
+	// 明文格式为 "{id}|{secret}"，便于按 ID 直接定位记录
+	return &token, fmt.Sprintf("%d|%s", token.ID, secret), nil
+}
+
+// ValidatePersonalAccessToken 校验明文令牌，返回令牌记录
+func ValidatePersonalAccessToken(plain string) (*models.PersonalAccessToken, error) {
+	idPart, secret, found := strings.Cut(plain, "|")
+	if !found || secret == "" {
+		return nil, ErrPersonalAccessTokenInvalid
+	}
+
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return nil, ErrPersonalAccessTokenInvalid
+	}
+
+	var token models.PersonalAccessToken
+	if err := config.DB.Where("id = ? AND tokenable_type = ?", id, PersonalAccessTokenTypeSysUser).
+		First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPersonalAccessTokenInvalid
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token.Token), []byte(hashTokenSecret(secret))) != 1 {
+		return nil, ErrPersonalAccessTokenInvalid
+	}
+
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return nil, ErrPersonalAccessTokenExpired
+	}
+
+	// 令牌所属用户必须存在且处于启用状态
+	var count int64
+	if err := config.DB.Model(&models.SysUser{}).
+		Where("id = ? AND status = ?", token.TokenableID, 1).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrPersonalAccessTokenInvalid
+	}
+
+	touchPersonalAccessToken(&token)
+
+	return &token, nil
+}
+
+// touchPersonalAccessToken 更新最后使用时间
+func touchPersonalAccessToken(token *models.PersonalAccessToken) {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < personalAccessTokenTouchInterval {
+		return
+	}
+
+	if err := config.DB.Model(token).UpdateColumn("last_used_at", now).Error; err != nil {
+		log.Printf("更新个人访问令牌 %d 的最后使用时间失败: %v", token.ID, err)
+		return
+	}
+	token.LastUsedAt = &now
+}
+
+// PersonalAccessTokenAbilities 解析令牌的权限范围
+func PersonalAccessTokenAbilities(token *models.PersonalAccessToken) []string {
+	var abilities []string
+	if token.Abilities == "" {
+		return abilities
+	}
+	if err := json.Unmarshal([]byte(token.Abilities), &abilities); err != nil {
+		log.Printf("解析个人访问令牌 %d 的权限范围失败: %v", token.ID, err)
+	}
+	return abilities
+}
+
+// AbilitiesAllow 判断令牌的权限范围是否覆盖当前路由
+// 权限范围以 sys_permissions.name 表示，实际能否访问还需要令牌所属用户本身拥有该权限
+func AbilitiesAllow(abilities []string, method, route string) (bool, error) {
+	names := make([]string, 0, len(abilities))
+	for _, ability := range abilities {
+		if ability == PersonalAccessTokenAbilityAll {
+			return true, nil
+		}
+		names = append(names, ability)
+	}
+
+	if len(names) == 0 {
+		return false, nil
+	}
+
+	var rules []sysPermissionRule
+	if err := config.DB.Model(&models.SysPermission{}).
+		Select("route, method").
+		Where("name IN ?", names).
+		Scan(&rules).Error; err != nil {
+		return false, err
+	}
+
+	route = normalizeSysRoute(route)
+	for _, rule := range rules {
+		if matchSysPermission(rule, method, route) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// normalizeAbilities 去重并校验权限范围都对应已存在的 sys_permissions
+func normalizeAbilities(abilities []string) ([]string, error) {
+	unique := make(map[string]struct{})
+	result := make([]string, 0, len(abilities))
+	for _, ability := range abilities {
+		ability = strings.TrimSpace(ability)
+		if ability == "" {
+			continue
+		}
+		if _, exists := unique[ability]; exists {
+			continue
+		}
+		unique[ability] = struct{}{}
+		result = append(result, ability)
+	}
+
+	if len(result) == 0 {
+		return nil, ErrInvalidTokenAbilities
+	}
+
+	if _, all := unique[PersonalAccessTokenAbilityAll]; all {
+		return []string{PersonalAccessTokenAbilityAll}, nil
+	}
+
+	// 不同的权限记录可能同名，按去重后的名称比较
+	var count int64
+	if err := config.DB.Model(&models.SysPermission{}).Where("name IN ?", result).
+		Distinct("name").Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if int(count) != len(result) {
+		return nil, ErrInvalidTokenAbilities
+	}
+
+	return result, nil
+}
+
+// randomTokenSecret 生成 40 位随机字符串
+func randomTokenSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// hashTokenSecret 对令牌明文做 SHA-256 哈希，数据库只保存哈希值
+func hashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
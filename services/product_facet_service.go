@@ -0,0 +1,390 @@
+package services
+
+import (
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+
+	"github.com/guregu/null/v5"
+	"gorm.io/gorm"
+)
+
+// 筛选维度，统计某一维度的数量时不应用该维度自身的筛选条件，用户可以在同一维度内切换选项
+const (
+	facetCategory     = "category"
+	facetMaterial     = "material"
+	facetBrand        = "brand"
+	facetGender       = "gender"
+	facetLensWidth    = "lens_width"
+	facetNoseBridge   = "nose_bridge"
+	facetTempleLength = "temple_length"
+)
+
+// productRangeFacets 尺寸按固定步长分段统计，单位 mm
+var productRangeFacets = []struct {
+	facet  string
+	column string
+	step   float32
+}{
+	{facetLensWidth, "products.lens_width", 4},
+	{facetNoseBridge, "products.nose_bridge", 2},
+	{facetTempleLength, "products.temple_length", 5},
+}
+
+// FloatRange 数值范围，Min、Max 为 nil 表示不限
+type FloatRange struct {
+	Min *float32
+	Max *float32
+}
+
+// ProductFilter 商品列表和首页共用的筛选条件，零值表示不筛选
+type ProductFilter struct {
+	CategoryID   int64 // 包含所有子分类
+	MaterialID   int64 // 商品的任一材质
+	BrandID      int64
+	SeriesID     int64
+	Gender       string
+	LensWidth    FloatRange
+	NoseBridge   FloatRange
+	TempleLength FloatRange
+	Keyword      string               // 搜索词，Search 为 nil（索引不可用）时按型号、货号前缀匹配
+	Search       *ProductSearchResult // Keyword 的全文搜索结果
+
+	categoryIDs []int64 // CategoryID 及其子孙分类，第一次使用时查询
+}
+
+// CategoryFacet 分类及其子孙分类下符合条件的商品和系列数量
+type CategoryFacet struct {
+	ID           int64  `json:"id"`
+	Pid          int64  `json:"pid"`
+	Name         string `json:"name"`
+	ProductCount int64  `json:"product_count"`
+	SeriesCount  int64  `json:"series_count"`
+}
+
+// FacetValue 材质或品牌下符合条件的商品和系列数量
+type FacetValue struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	ProductCount int64  `json:"product_count"`
+	SeriesCount  int64  `json:"series_count"`
+}
+
+// GenderFacet 性别选项下符合条件的商品数量
+type GenderFacet struct {
+	Value        string `json:"value"`
+	ProductCount int64  `json:"product_count"`
+}
+
+// RangeFacet 尺寸区间 [Min, Max) 内符合条件的商品数量
+type RangeFacet struct {
+	Min          float32 `json:"min"`
+	Max          float32 `json:"max"`
+	ProductCount int64   `json:"product_count"`
+}
+
+// ProductFacets 各筛选维度的选项及数量
+type ProductFacets struct {
+	Categories     []CategoryFacet `json:"categories"`
+	FrameMaterials []FacetValue    `json:"frame_materials"`
+	Brands         []FacetValue    `json:"brands"`
+	Genders        []GenderFacet   `json:"genders"`
+	LensWidths     []RangeFacet    `json:"lens_widths"`
+	NoseBridges    []RangeFacet    `json:"nose_bridges"`
+	TempleLengths  []RangeFacet    `json:"temple_lengths"`
+}
+
+// Apply 添加全部筛选条件，query 需要包含 products 表
+func (f *ProductFilter) Apply(query *gorm.DB) (*gorm.DB, error) {
+	return f.apply(query, "")
+}
+
+// apply 添加除 except 维度以外的筛选条件
+func (f *ProductFilter) apply(query *gorm.DB, except string) (*gorm.DB, error) {
+	if f.Search != nil {
+		query = query.Where("products.id IN ?", f.Search.IDs())
+	} else if f.Keyword != "" {
+		searchPattern := f.Keyword + "%"
+		query = query.Where("products.model_no LIKE ? OR products.item_code LIKE ?", searchPattern, searchPattern)
+	}
+	if f.CategoryID != 0 && except != facetCategory {
+		if f.categoryIDs == nil {
+			categoryIDs, err := CategorySubtreeIDs(f.CategoryID)
+			if err != nil {
+				return nil, err
+			}
+			f.categoryIDs = categoryIDs
+		}
+		query = query.Where("products.category_id IN ?", f.categoryIDs)
+	}
+	if f.MaterialID != 0 && except != facetMaterial {
+		query = query.Where(ProductMaterialCondition, f.MaterialID)
+	}
+	if f.BrandID != 0 && except != facetBrand {
+		query = query.Where("products.brand_id = ?", f.BrandID)
+	}
+	if f.SeriesID != 0 {
+		query = query.Where("products.series_id = ?", f.SeriesID)
+	}
+	if f.Gender != "" && except != facetGender {
+		query = query.Where("products.gender = ?", f.Gender)
+	}
+	if except != facetLensWidth {
+		query = whereRange(query, "products.lens_width", f.LensWidth)
+	}
+	if except != facetNoseBridge {
+		query = whereRange(query, "products.nose_bridge", f.NoseBridge)
+	}
+	if except != facetTempleLength {
+		query = whereRange(query, "products.temple_length", f.TempleLength)
+	}
+	return query, nil
+}
+
+// whereRange 添加数值范围筛选条件
+func whereRange(query *gorm.DB, column string, r FloatRange) *gorm.DB {
+	if r.Min != nil {
+		query = query.Where(column+" >= ?", *r.Min)
+	}
+	if r.Max != nil {
+		query = query.Where(column+" <= ?", *r.Max)
+	}
+	return query
+}
+
+// CategorySubtreeIDs 获取分类及其所有子孙分类的 ID
+func CategorySubtreeIDs(rootID int64) ([]int64, error) {
+	var categories []models.Category
+	if err := config.DB.Select("id, pid").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[int64][]int64)
+	for _, category := range categories {
+		children[category.Pid] = append(children[category.Pid], category.ID)
+	}
+
+	ids := []int64{rootID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids, nil
+}
+
+// LoadProductFacets 统计各筛选维度的选项数量，每个维度应用除自身以外的全部筛选条件
+// products 返回当前用户可见的商品查询，每次调用需返回新的查询
+func LoadProductFacets(products func() *gorm.DB, filter *ProductFilter) (*ProductFacets, error) {
+	facets := &ProductFacets{}
+	var err error
+
+	if facets.Categories, err = categoryFacets(products, filter); err != nil {
+		return nil, fmt.Errorf("failed to count categories: %v", err)
+	}
+	if facets.FrameMaterials, err = materialFacets(products, filter); err != nil {
+		return nil, fmt.Errorf("failed to count materials: %v", err)
+	}
+	if facets.Brands, err = brandFacets(products, filter); err != nil {
+		return nil, fmt.Errorf("failed to count brands: %v", err)
+	}
+	if facets.Genders, err = genderFacets(products, filter); err != nil {
+		return nil, fmt.Errorf("failed to count genders: %v", err)
+	}
+
+	ranges := map[string]*[]RangeFacet{
+		facetLensWidth:    &facets.LensWidths,
+		facetNoseBridge:   &facets.NoseBridges,
+		facetTempleLength: &facets.TempleLengths,
+	}
+	for _, r := range productRangeFacets {
+		if *ranges[r.facet], err = rangeFacets(products, filter, r.facet, r.column, r.step); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %v", r.facet, err)
+		}
+	}
+
+	return facets, nil
+}
+
+// categoryFacets 商品计入其分类及所有上级分类，系列按分类子树去重
+func categoryFacets(products func() *gorm.DB, filter *ProductFilter) ([]CategoryFacet, error) {
+	query, err := filter.apply(products(), facetCategory)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		CategoryID   int64
+		SeriesID     null.Int64
+		ProductCount int64
+	}
+	if err := query.
+		Select("products.category_id, products.series_id, COUNT(DISTINCT products.id) AS product_count").
+		Group("products.category_id, products.series_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var categories []models.Category
+	if err := config.DB.Select("id, pid, name").Order("display_order, id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	parents := make(map[int64]int64, len(categories))
+	for _, category := range categories {
+		parents[category.ID] = category.Pid
+	}
+
+	productCounts := make(map[int64]int64)
+	seriesSets := make(map[int64]map[int64]bool)
+	for _, row := range rows {
+		// 沿上级分类向上累加，visited 防止分类数据成环
+		visited := make(map[int64]bool)
+		for id := row.CategoryID; id != 0 && !visited[id]; id = parents[id] {
+			visited[id] = true
+			productCounts[id] += row.ProductCount
+			if row.SeriesID.Valid {
+				if seriesSets[id] == nil {
+					seriesSets[id] = make(map[int64]bool)
+				}
+				seriesSets[id][row.SeriesID.Int64] = true
+			}
+		}
+	}
+
+	facets := make([]CategoryFacet, 0, len(categories))
+	for _, category := range categories {
+		facets = append(facets, CategoryFacet{
+			ID:           category.ID,
+			Pid:          category.Pid,
+			Name:         category.Name,
+			ProductCount: productCounts[category.ID],
+			SeriesCount:  int64(len(seriesSets[category.ID])),
+		})
+	}
+	return facets, nil
+}
+
+// materialFacets 混合材质的商品计入其每一种材质
+func materialFacets(products func() *gorm.DB, filter *ProductFilter) ([]FacetValue, error) {
+	query, err := filter.apply(products(), facetMaterial)
+	if err != nil {
+		return nil, err
+	}
+	query = query.Joins("JOIN product_material ON product_material.product_id = products.id")
+
+	var materials []models.FrameMaterial
+	if err := config.DB.Select("id, name").Order("id").Find(&materials).Error; err != nil {
+		return nil, err
+	}
+
+	names := make([]idName, 0, len(materials))
+	for _, material := range materials {
+		names = append(names, idName{ID: material.ID, Name: material.Name})
+	}
+	return countFacetValues(query, "product_material.frame_material_id", names)
+}
+
+func brandFacets(products func() *gorm.DB, filter *ProductFilter) ([]FacetValue, error) {
+	query, err := filter.apply(products(), facetBrand)
+	if err != nil {
+		return nil, err
+	}
+
+	var brands []models.Brand
+	if err := config.DB.Select("id, name").Order("id").Find(&brands).Error; err != nil {
+		return nil, err
+	}
+
+	names := make([]idName, 0, len(brands))
+	for _, brand := range brands {
+		names = append(names, idName{ID: brand.ID, Name: brand.Name})
+	}
+	return countFacetValues(query, "products.brand_id", names)
+}
+
+// idName 筛选选项的 ID 和名称
+type idName struct {
+	ID   int64
+	Name string
+}
+
+// countFacetValues 按 column 分组统计商品和系列数量，返回 values 中的全部选项，没有商品的选项数量为 0
+func countFacetValues(query *gorm.DB, column string, values []idName) ([]FacetValue, error) {
+	var rows []struct {
+		ID           int64
+		ProductCount int64
+		SeriesCount  int64
+	}
+	if err := query.
+		Select(column + " AS id, COUNT(DISTINCT products.id) AS product_count, COUNT(DISTINCT products.series_id) AS series_count").
+		Where(column + " IS NOT NULL").
+		Group(column).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]int, len(rows))
+	for i, row := range rows {
+		counts[row.ID] = i
+	}
+
+	facets := make([]FacetValue, 0, len(values))
+	for _, value := range values {
+		facet := FacetValue{ID: value.ID, Name: value.Name}
+		if i, exists := counts[value.ID]; exists {
+			facet.ProductCount = rows[i].ProductCount
+			facet.SeriesCount = rows[i].SeriesCount
+		}
+		facets = append(facets, facet)
+	}
+	return facets, nil
+}
+
+// genderFacets 只返回有商品的性别选项
+func genderFacets(products func() *gorm.DB, filter *ProductFilter) ([]GenderFacet, error) {
+	query, err := filter.apply(products(), facetGender)
+	if err != nil {
+		return nil, err
+	}
+
+	facets := make([]GenderFacet, 0)
+	if err := query.
+		Select("products.gender AS value, COUNT(DISTINCT products.id) AS product_count").
+		Where("products.gender IS NOT NULL AND products.gender <> ''").
+		Group("products.gender").
+		Order("products.gender").
+		Scan(&facets).Error; err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+// rangeFacets 按 step 分段统计尺寸，未填写尺寸（为 0）的商品不计入
+func rangeFacets(products func() *gorm.DB, filter *ProductFilter, facet, column string, step float32) ([]RangeFacet, error) {
+	query, err := filter.apply(products(), facet)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Bucket       float32
+		ProductCount int64
+	}
+	if err := query.
+		Select(fmt.Sprintf("FLOOR(%s / ?) * ? AS bucket, COUNT(DISTINCT products.id) AS product_count", column), step, step).
+		Where(column + " > 0").
+		Group("bucket").
+		Order("bucket").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	facets := make([]RangeFacet, 0, len(rows))
+	for _, row := range rows {
+		facets = append(facets, RangeFacet{
+			Min:          row.Bucket,
+			Max:          row.Bucket + step,
+			ProductCount: row.ProductCount,
+		})
+	}
+	return facets, nil
+}
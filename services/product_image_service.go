@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+
+	"github.com/guregu/null/v5"
+	"gorm.io/gorm"
+)
+
+// ProductImageOrder 商品图片的展示顺序
+const ProductImageOrder = "sort_order, id"
+
+// primaryImageOrder 主图排在最前，其余按展示顺序
+const primaryImageOrder = "is_primary DESC, sort_order, id"
+
+// ErrInvalidImageOrder 排序时传入的图片与商品现有图片不一致
+var ErrInvalidImageOrder = errors.New("image ids must match the product's images")
+
+// ProductImageInfo 返回给前端的商品图片
+type ProductImageInfo struct {
+	ID        int64                      `json:"id"`
+	VariantID null.Int64                 `json:"variant_id"` // 所属款式，为空时是商品通用图片
+	URL       string                     `json:"url"`
+	SortOrder int                        `json:"sort_order"`
+	IsPrimary bool                       `json:"is_primary"`
+	AltText   string                     `json:"alt_text"`
+	Color     string                     `json:"color"`
+	Angle     string                     `json:"angle"`
+	Variants  map[string]ImageVariantURL `json:"variants"`
+}
+
+// ProductImageInfos 转换为返回给前端的图片列表，保持传入的顺序
+func ProductImageInfos(productImages []models.ProductImage) []ProductImageInfo {
+	infos := make([]ProductImageInfo, 0, len(productImages))
+	for _, img := range productImages {
+		infos = append(infos, ProductImageInfo{
+			ID:        img.ID,
+			VariantID: img.VariantID,
+			URL:       AssetURL(img.ImageURL),
+			SortOrder: img.SortOrder,
+			IsPrimary: img.IsPrimary,
+			AltText:   img.AltText,
+			Color:     img.Color,
+			Angle:     img.Angle,
+			Variants:  ImageVariantURLs(img),
+		})
+	}
+	return infos
+}
+
+// PrimaryProductImage 从按 ProductImageOrder 排好序的图片中取主图，没有标记主图时取第一张
+func PrimaryProductImage(productImages []models.ProductImage) (models.ProductImage, bool) {
+	for _, img := range productImages {
+		if img.IsPrimary {
+			return img, true
+		}
+	}
+	if len(productImages) == 0 {
+		return models.ProductImage{}, false
+	}
+	return productImages[0], true
+}
+
+// PrimaryProductImages 批量查询商品主图，没有标记主图的商品取排在最前的图片
+func PrimaryProductImages(productIDs []int64) (map[int64]models.ProductImage, error) {
+	primaryImages := make(map[int64]models.ProductImage, len(productIDs))
+	if len(productIDs) == 0 {
+		return primaryImages, nil
+	}
+
+	var productImages []models.ProductImage
+	if err := config.DB.Where("product_id IN ?", productIDs).
+		Order("product_id, " + primaryImageOrder).
+		Find(&productImages).Error; err != nil {
+		return nil, err
+	}
+
+	for _, img := range productImages {
+		productID := img.ProductID.Int64
+		if _, exists := primaryImages[productID]; !exists {
+			primaryImages[productID] = img
+		}
+	}
+	return primaryImages, nil
+}
+
+// AddProductImages 按顺序把图片追加到商品图片末尾，已存在的图片跳过；商品没有主图时排在最前的图片作为主图
+func AddProductImages(tx *gorm.DB, productID int64, keys []string) error {
+	var existingImages []models.ProductImage
+	if err := tx.Select("image_url, sort_order").Where("product_id = ?", productID).
+		Find(&existingImages).Error; err != nil {
+		return fmt.Errorf("failed to fetch existing images: %v", err)
+	}
+
+	existingImageMap := make(map[string]bool, len(existingImages))
+	nextSortOrder := 0
+	for _, img := range existingImages {
+		existingImageMap[img.ImageURL] = true
+		if img.SortOrder >= nextSortOrder {
+			nextSortOrder = img.SortOrder + 1
+		}
+	}
+
+	for _, key := range keys {
+		if key == "" || existingImageMap[key] {
+			continue
+		}
+		existingImageMap[key] = true
+
+		productImage := NewProductImage(productID, key)
+		productImage.SortOrder = nextSortOrder
+		nextSortOrder++
+		if err := tx.Create(&productImage).Error; err != nil {
+			return fmt.Errorf("failed to create product image: %v", err)
+		}
+	}
+
+	return EnsurePrimaryProductImage(tx, productID)
+}
+
+// EnsurePrimaryProductImage 商品有图片但没有主图时（新商品或主图被删除），将排在最前的图片设为主图
+func EnsurePrimaryProductImage(tx *gorm.DB, productID int64) error {
+	var productImages []models.ProductImage
+	if err := tx.Select("id, is_primary").Where("product_id = ?", productID).
+		Order(primaryImageOrder).Limit(1).Find(&productImages).Error; err != nil {
+		return fmt.Errorf("failed to fetch primary image: %v", err)
+	}
+	if len(productImages) == 0 || productImages[0].IsPrimary {
+		return nil
+	}
+
+	return tx.Model(&models.ProductImage{}).Where("id = ?", productImages[0].ID).
+		Update("is_primary", true).Error
+}
+
+// ReorderProductImages 按 imageIDs 的顺序重新排列商品图片，imageIDs 必须包含商品的全部图片
+func ReorderProductImages(productID int64, imageIDs []int64) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var existingIDs []int64
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", productID).
+			Pluck("id", &existingIDs).Error; err != nil {
+			return err
+		}
+
+		existing := make(map[int64]bool, len(existingIDs))
+		for _, id := range existingIDs {
+			existing[id] = true
+		}
+		if len(imageIDs) != len(existingIDs) {
+			return ErrInvalidImageOrder
+		}
+		for _, id := range imageIDs {
+			if !existing[id] {
+				return ErrInvalidImageOrder
+			}
+			delete(existing, id) // 防止重复的 ID
+		}
+
+		for i, id := range imageIDs {
+			if err := tx.Model(&models.ProductImage{}).Where("id = ?", id).
+				Update("sort_order", i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetPrimaryProductImage 将商品的一张图片设为主图，图片不属于该商品时返回 gorm.ErrRecordNotFound
+func SetPrimaryProductImage(productID, imageID int64) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var productImage models.ProductImage
+		if err := tx.Select("id").Where("id = ? AND product_id = ?", imageID, productID).
+			First(&productImage).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ? AND id <> ?", productID, imageID).
+			Update("is_primary", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ProductImage{}).Where("id = ?", imageID).Update("is_primary", true).Error
+	})
+}
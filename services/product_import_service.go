@@ -0,0 +1,611 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/guregu/null/v5"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// 单次导入的最大行数
+const productImportMaxRows = 5000
+
+// 导入结果中每行的处理方式
+const (
+	ProductImportActionCreate = "create"
+	ProductImportActionUpdate = "update"
+)
+
+// ErrInvalidImportFile 导入文件格式或表头不正确
+var ErrInvalidImportFile = errors.New("invalid import file")
+
+// productImportColumns 表头到字段的映射，支持中英文列名（英文不区分大小写）
+var productImportColumns = map[string]string{
+	"model_no":       "model_no",
+	"型号":             "model_no",
+	"item_code":      "item_code",
+	"货号":             "item_code",
+	"title":          "title",
+	"标题":             "title",
+	"description":    "description",
+	"描述":             "description",
+	"series":         "series",
+	"系列":             "series",
+	"brand":          "brand",
+	"品牌":             "brand",
+	"frame_material": "frame_material",
+	"框材质":            "frame_material",
+	"category":       "category",
+	"分类":             "category",
+	"lens_width":     "lens_width",
+	"镜片宽度":           "lens_width",
+	"nose_bridge":    "nose_bridge",
+	"鼻梁宽度":           "nose_bridge",
+	"temple_length":  "temple_length",
+	"镜腿长度":           "temple_length",
+	"gender":         "gender",
+	"性别":             "gender",
+	"images":         "images",
+	"图片":             "images",
+}
+
+// productImportGenders 性别取值，与 products.gender 枚举保持一致
+var productImportGenders = map[string]string{
+	"male":   "male",
+	"男":      "male",
+	"female": "female",
+	"女":      "female",
+	"unisex": "unisex",
+	"中性":     "unisex",
+}
+
+// ProductImportRow 导入文件中的一行商品数据
+// 可选的数值字段为 nil 表示单元格为空，更新已有商品时不会覆盖原值
+type ProductImportRow struct {
+	Row            int      `json:"row"` // 在表格中的行号，表头为第 1 行
+	ModelNO        string   `json:"model_no"`
+	ItemCode       string   `json:"item_code"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	Series         string   `json:"series"`
+	Brand          string   `json:"brand"`
+	FrameMaterials []string `json:"frame_materials"` // 多种材质用逗号或顿号分隔，第一种为主材质
+	MainCategory   string   `json:"main_category"`
+	SubCategory    string   `json:"sub_category"`
+	LensWidth      *float32 `json:"lens_width"`
+	NoseBridge     *float32 `json:"nose_bridge"`
+	TempleLength   *float32 `json:"temple_length"`
+	Gender         string   `json:"gender"`
+	Images         []string `json:"images"`
+}
+
+// ProductImportError 某一行某个字段的错误
+type ProductImportError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ProductImportRowResult 某一行的处理结果
+type ProductImportRowResult struct {
+	Row       int    `json:"row"`
+	ModelNO   string `json:"model_no"`
+	Action    string `json:"action"`               // create 或 update
+	ProductID int64  `json:"product_id,omitempty"` // 预检查时为已存在商品的 ID，新建商品在提交后才有 ID
+}
+
+// ProductImportResult 导入报告
+type ProductImportResult struct {
+	DryRun  bool                     `json:"dry_run"`
+	Total   int                      `json:"total"`
+	Created int                      `json:"created"`
+	Updated int                      `json:"updated"`
+	Failed  int                      `json:"failed"` // 存在错误的行数
+	Rows    []ProductImportRowResult `json:"rows"`
+	Errors  []ProductImportError     `json:"errors"`
+}
+
+// productImportRef 校验通过后的行及其关联的记录 ID
+type productImportRef struct {
+	row         ProductImportRow
+	existingID  int64
+	oldSeriesID int64   // 已存在商品原来的系列
+	materialIDs []int64 // 第一个为主材质
+	seriesID    null.Int64
+	brandID     null.Int64
+}
+
+// ImportProducts 校验并导入商品表格，按 model_no 新建或更新商品
+// dryRun 为 true 时只返回每行的校验结果和处理方式；否则在所有行都校验通过后，在同一个事务中写入
+// sysUserID 为导入的后台用户，记录在商品的修订记录中
+// allowUpdates 为 false 时已存在的商品作为错误行返回：更新商品需要审核发布，只有拥有审核发布权限的用户可以通过导入直接更新
+func ImportProducts(filename string, src io.Reader, dryRun, allowUpdates bool, sysUserID int64) (*ProductImportResult, error) {
+	rows, parseErrors, err := parseProductImportFile(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProductImportResult{
+		DryRun: dryRun,
+		Total:  len(rows),
+		Rows:   make([]ProductImportRowResult, 0, len(rows)),
+		Errors: parseErrors,
+	}
+
+	refs, validateErrors, err := validateProductImportRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	result.Errors = append(result.Errors, validateErrors...)
+
+	if !allowUpdates {
+		for _, ref := range refs {
+			if ref.existingID != 0 {
+				result.Errors = append(result.Errors, ProductImportError{
+					Row:     ref.row.Row,
+					Field:   "model_no",
+					Message: "商品已存在，更新已有商品需要审核发布权限，请在商品编辑中提交修改",
+				})
+			}
+		}
+	}
+
+	failedRows := make(map[int]bool)
+	for _, e := range result.Errors {
+		failedRows[e.Row] = true
+	}
+	result.Failed = len(failedRows)
+
+	// 有错误时不写入任何数据
+	if dryRun || result.Failed > 0 {
+		for _, ref := range refs {
+			if failedRows[ref.row.Row] {
+				continue
+			}
+			result.addRow(ref, ref.existingID)
+		}
+		return result, nil
+	}
+
+	tx := config.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	productIDs := make([]int64, 0, len(refs))
+	for _, ref := range refs {
+		productID, err := saveImportedProduct(tx, ref, sysUserID)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("row %d (%s): %w", ref.row.Row, ref.row.ModelNO, err)
+		}
+		result.addRow(ref, productID)
+		productIDs = append(productIDs, productID)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	// 更新涉及到的系列目录
+	seriesIDs := make([]int64, 0)
+	for _, ref := range refs {
+		seriesIDs = append(seriesIDs, ref.oldSeriesID, ref.seriesID.Int64)
+	}
+	ScheduleSeriesCatalog(seriesIDs...)
+	ScheduleSearchIndex(productIDs...)
+
+	log.Printf("商品导入完成: 新建 %d 个，更新 %d 个", result.Created, result.Updated)
+	return result, nil
+}
+
+// addRow 记录一行的处理结果
+func (result *ProductImportResult) addRow(ref productImportRef, productID int64) {
+	action := ProductImportActionCreate
+	if ref.existingID != 0 {
+		action = ProductImportActionUpdate
+		result.Updated++
+	} else {
+		result.Created++
+	}
+
+	result.Rows = append(result.Rows, ProductImportRowResult{
+		Row:       ref.row.Row,
+		ModelNO:   ref.row.ModelNO,
+		Action:    action,
+		ProductID: productID,
+	})
+}
+
+// saveImportedProduct 新建或更新一行商品，补充缺少的图片记录，并保存修订记录
+func saveImportedProduct(tx *gorm.DB, ref productImportRef, sysUserID int64) (int64, error) {
+	row := ref.row
+
+	categoryID, err := GetCategoryIDWithDB(tx, row.MainCategory, row.SubCategory)
+	if err != nil {
+		return 0, err
+	}
+
+	productID := ref.existingID
+	action := models.RevisionCreate
+	var before RevisionSnapshot
+	if productID != 0 {
+		action = models.RevisionUpdate
+		if before, err = LoadRevisionSnapshot(tx, models.EntityProduct, productID); err != nil {
+			return 0, err
+		}
+	}
+	if productID == 0 {
+		product := models.Product{
+			ModelNO:         row.ModelNO,
+			FrameMaterialID: ref.materialIDs[0],
+			Title:           row.Title,
+			Description:     row.Description,
+			CategoryID:      categoryID,
+			SeriesID:        ref.seriesID,
+			BrandID:         ref.brandID,
+			ItemCode:        null.NewString(row.ItemCode, row.ItemCode != ""),
+			Gender:          null.NewString(row.Gender, row.Gender != ""),
+		}
+		if row.LensWidth != nil {
+			product.LensWidth = *row.LensWidth
+		}
+		if row.NoseBridge != nil {
+			product.NoseBridge = *row.NoseBridge
+		}
+		if row.TempleLength != nil {
+			product.TempleLength = *row.TempleLength
+		}
+
+		if err := tx.Create(&product).Error; err != nil {
+			return 0, err
+		}
+		productID = product.ID
+	} else {
+		// 只更新表格中填写了的字段
+		updates := map[string]interface{}{
+			"frame_material_id": ref.materialIDs[0],
+			"category_id":       categoryID,
+		}
+		if row.ItemCode != "" {
+			updates["item_code"] = row.ItemCode
+		}
+		if row.Title != "" {
+			updates["title"] = row.Title
+		}
+		if row.Description != "" {
+			updates["description"] = row.Description
+		}
+		if ref.seriesID.Valid {
+			updates["series_id"] = ref.seriesID
+		}
+		if ref.brandID.Valid {
+			updates["brand_id"] = ref.brandID
+		}
+		if row.Gender != "" {
+			updates["gender"] = row.Gender
+		}
+		if row.LensWidth != nil {
+			updates["lens_width"] = *row.LensWidth
+		}
+		if row.NoseBridge != nil {
+			updates["nose_bridge"] = *row.NoseBridge
+		}
+		if row.TempleLength != nil {
+			updates["temple_length"] = *row.TempleLength
+		}
+
+		if err := tx.Model(&models.Product{}).Where("id = ?", productID).Updates(updates).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	if err := SetProductMaterials(tx, productID, ref.materialIDs); err != nil {
+		return 0, err
+	}
+
+	// 与更新商品时一致，只追加不存在的图片记录
+	if len(row.Images) > 0 {
+		if err := AddProductImages(tx, productID, row.Images); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := RecordRevision(tx, models.EntityProduct, productID, action, before, RevisionMeta{SysUserID: sysUserID}); err != nil {
+		return 0, err
+	}
+
+	return productID, nil
+}
+
+// validateProductImportRows 校验每一行的必填项和关联数据，返回校验通过的行
+func validateProductImportRows(rows []ProductImportRow) ([]productImportRef, []ProductImportError, error) {
+	frameMaterialIDs := make(map[string]int64)
+	seriesByName := make(map[string]models.Series)
+	brandIDs := make(map[string]int64)
+	existingProducts := make(map[string]models.Product)
+
+	var frameMaterials []models.FrameMaterial
+	if err := config.DB.Find(&frameMaterials).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, m := range frameMaterials {
+		frameMaterialIDs[m.Name] = m.ID
+	}
+
+	var seriesList []models.Series
+	if err := config.DB.Find(&seriesList).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, s := range seriesList {
+		seriesByName[s.Name] = s
+	}
+
+	var brands []models.Brand
+	if err := config.DB.Find(&brands).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, b := range brands {
+		brandIDs[b.Name] = b.ID
+	}
+
+	modelNOs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.ModelNO != "" {
+			modelNOs = append(modelNOs, row.ModelNO)
+		}
+	}
+	if len(modelNOs) > 0 {
+		var products []models.Product
+		if err := config.DB.Select("id, model_no, series_id").Where("model_no IN ?", modelNOs).Find(&products).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, p := range products {
+			existingProducts[p.ModelNO] = p
+		}
+	}
+
+	var refs []productImportRef
+	var importErrors []ProductImportError
+	seenModelNOs := make(map[string]int)
+
+	for _, row := range rows {
+		var rowErrors []ProductImportError
+		addError := func(field, message string) {
+			rowErrors = append(rowErrors, ProductImportError{Row: row.Row, Field: field, Message: message})
+		}
+
+		ref := productImportRef{row: row}
+
+		if row.ModelNO == "" {
+			addError("model_no", "型号不能为空")
+		} else if firstRow, exists := seenModelNOs[row.ModelNO]; exists {
+			addError("model_no", fmt.Sprintf("型号与第 %d 行重复", firstRow))
+		} else {
+			seenModelNOs[row.ModelNO] = row.Row
+			if existing, exists := existingProducts[row.ModelNO]; exists {
+				ref.existingID = existing.ID
+				ref.oldSeriesID = existing.SeriesID.Int64
+			}
+		}
+
+		if len(row.FrameMaterials) == 0 {
+			addError("frame_material", "框材质不能为空")
+		}
+		for _, name := range row.FrameMaterials {
+			if id, exists := frameMaterialIDs[name]; exists {
+				ref.materialIDs = append(ref.materialIDs, id)
+			} else {
+				addError("frame_material", fmt.Sprintf("框材质 %s 不存在", name))
+			}
+		}
+		if len(ref.materialIDs) > 0 {
+			ref.materialIDs = NormalizeMaterialIDs(ref.materialIDs[0], ref.materialIDs)
+		}
+
+		if row.Series != "" {
+			if series, exists := seriesByName[row.Series]; !exists {
+				addError("series", fmt.Sprintf("系列 %s 不存在", row.Series))
+			} else {
+				ref.seriesID = null.IntFrom(series.ID)
+				if len(ref.materialIDs) > 0 && !SeriesMaterialMatches(series.FrameMaterialID, ref.materialIDs) {
+					addError("frame_material", "产品框材质必须包含系列框材质")
+				}
+			}
+		}
+
+		if row.Brand != "" {
+			if id, exists := brandIDs[row.Brand]; exists {
+				ref.brandID = null.IntFrom(id)
+			} else {
+				addError("brand", fmt.Sprintf("品牌 %s 不存在", row.Brand))
+			}
+		}
+
+		if row.MainCategory == "" || row.SubCategory == "" {
+			addError("category", "分类格式应为 主分类/子分类")
+		}
+
+		// 新建商品至少需要一张图片，与后台创建商品的要求一致
+		if ref.existingID == 0 && row.ModelNO != "" && len(row.Images) == 0 {
+			addError("images", "新商品至少需要一张图片")
+		}
+
+		if len(rowErrors) > 0 {
+			importErrors = append(importErrors, rowErrors...)
+		}
+		refs = append(refs, ref)
+	}
+
+	return refs, importErrors, nil
+}
+
+// parseProductImportFile 按扩展名解析 xlsx 或 csv 文件
+// 返回的错误列表为单元格格式错误，error 为文件本身无法解析
+func parseProductImportFile(filename string, r io.Reader) ([]ProductImportRow, []ProductImportError, error) {
+	var records [][]string
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xlsx":
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				log.Printf("关闭导入文件错误: %v", err)
+			}
+		}()
+
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, nil, fmt.Errorf("%w: 文件中没有工作表", ErrInvalidImportFile)
+		}
+		if records, err = f.GetRows(sheets[0]); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+	case ".csv":
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Excel 导出的 CSV 带有 UTF-8 BOM
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		if records, err = reader.ReadAll(); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+	case ".xls":
+		return nil, nil, fmt.Errorf("%w: 不支持 .xls 格式，请另存为 .xlsx", ErrInvalidImportFile)
+	default:
+		return nil, nil, fmt.Errorf("%w: 只支持 .xlsx 和 .csv 文件", ErrInvalidImportFile)
+	}
+
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%w: 文件为空", ErrInvalidImportFile)
+	}
+
+	// 解析表头
+	columns := make(map[string]int)
+	for i, header := range records[0] {
+		key := strings.ToLower(strings.TrimSpace(header))
+		if field, exists := productImportColumns[key]; exists {
+			columns[field] = i
+		}
+	}
+	for _, required := range []string{"model_no", "frame_material", "category"} {
+		if _, exists := columns[required]; !exists {
+			return nil, nil, fmt.Errorf("%w: 缺少 %s 列", ErrInvalidImportFile, required)
+		}
+	}
+
+	if len(records)-1 > productImportMaxRows {
+		return nil, nil, fmt.Errorf("%w: 单次最多导入 %d 行", ErrInvalidImportFile, productImportMaxRows)
+	}
+
+	var rows []ProductImportRow
+	var importErrors []ProductImportError
+
+	for i, record := range records[1:] {
+		rowNumber := i + 2
+		cell := func(field string) string {
+			index, exists := columns[field]
+			if !exists || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		// 跳过空行
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row := ProductImportRow{
+			Row:         rowNumber,
+			ModelNO:     cell("model_no"),
+			ItemCode:    cell("item_code"),
+			Title:       cell("title"),
+			Description: cell("description"),
+			Series:      cell("series"),
+			Brand:       cell("brand"),
+		}
+
+		for _, material := range strings.FieldsFunc(cell("frame_material"), func(r rune) bool {
+			return r == ',' || r == '，' || r == '、' || r == '+' || r == ';' || r == '；'
+		}) {
+			if material = strings.TrimSpace(material); material != "" {
+				row.FrameMaterials = append(row.FrameMaterials, material)
+			}
+		}
+
+		addError := func(field, message string) {
+			importErrors = append(importErrors, ProductImportError{Row: rowNumber, Field: field, Message: message})
+		}
+
+		if category := cell("category"); category != "" {
+			parts := strings.FieldsFunc(category, func(r rune) bool {
+				return r == '/' || r == '>'
+			})
+			if len(parts) == 2 {
+				row.MainCategory = strings.TrimSpace(parts[0])
+				row.SubCategory = strings.TrimSpace(parts[1])
+			}
+		}
+
+		for field, target := range map[string]**float32{
+			"lens_width":    &row.LensWidth,
+			"nose_bridge":   &row.NoseBridge,
+			"temple_length": &row.TempleLength,
+		} {
+			value := cell(field)
+			if value == "" {
+				continue
+			}
+			size, err := strconv.ParseFloat(value, 32)
+			if err != nil || size < 0 {
+				addError(field, fmt.Sprintf("%s 不是有效的尺寸", value))
+				continue
+			}
+			size32 := float32(size)
+			*target = &size32
+		}
+
+		if value := cell("gender"); value != "" {
+			if gender, exists := productImportGenders[strings.ToLower(value)]; exists {
+				row.Gender = gender
+			} else {
+				addError("gender", fmt.Sprintf("性别 %s 无效，可选 male/female/unisex", value))
+			}
+		}
+
+		// 多张图片用逗号、分号或换行分隔，保存为对象路径
+		for _, image := range strings.FieldsFunc(cell("images"), func(r rune) bool {
+			return r == ',' || r == '，' || r == ';' || r == '；' || r == '\n'
+		}) {
+			if image = strings.TrimSpace(image); image != "" {
+				row.Images = append(row.Images, AssetKey(image, AssetFolderImages))
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, importErrors, nil
+}
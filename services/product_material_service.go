@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ProductMaterialCondition 商品的任一材质（主材质或其他材质）为指定材质，用于 products 表的查询条件
+const ProductMaterialCondition = "EXISTS (SELECT 1 FROM product_material WHERE product_material.product_id = products.id AND product_material.frame_material_id = ?)"
+
+// ErrMaterialNotFound 框材质不存在
+var ErrMaterialNotFound = errors.New("frame material not found")
+
+// ProductMaterialInfo 商品的一种框材质，主材质即 products.frame_material_id
+type ProductMaterialInfo struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	IsPrimary bool   `json:"is_primary"`
+}
+
+// NormalizeMaterialIDs 去掉重复和无效的材质，主材质排在第一位
+func NormalizeMaterialIDs(primaryID int64, materialIDs []int64) []int64 {
+	ids := make([]int64, 0, len(materialIDs)+1)
+	seen := make(map[int64]bool, len(materialIDs)+1)
+	for _, id := range append([]int64{primaryID}, materialIDs...) {
+		if id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// ValidateMaterialIDs 检查框材质是否都存在
+func ValidateMaterialIDs(materialIDs []int64) error {
+	if len(materialIDs) == 0 {
+		return ErrMaterialNotFound
+	}
+	var count int64
+	if err := config.DB.Model(&models.FrameMaterial{}).Where("id IN ?", materialIDs).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != int64(len(materialIDs)) {
+		return ErrMaterialNotFound
+	}
+	return nil
+}
+
+// ProductMaterialIDs 查询商品当前的全部材质
+func ProductMaterialIDs(productID int64) ([]int64, error) {
+	var materialIDs []int64
+	if err := config.DB.Model(&models.ProductMaterial{}).Where("product_id = ?", productID).
+		Order("frame_material_id").Pluck("frame_material_id", &materialIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch product materials: %v", err)
+	}
+	return materialIDs, nil
+}
+
+// SetProductMaterials 用 materialIDs 替换商品的全部材质，materialIDs 需包含主材质
+func SetProductMaterials(tx *gorm.DB, productID int64, materialIDs []int64) error {
+	if err := tx.Where("product_id = ?", productID).Delete(&models.ProductMaterial{}).Error; err != nil {
+		return fmt.Errorf("failed to delete product materials: %v", err)
+	}
+	if len(materialIDs) == 0 {
+		return nil
+	}
+
+	productMaterials := make([]models.ProductMaterial, 0, len(materialIDs))
+	for _, id := range materialIDs {
+		productMaterials = append(productMaterials, models.ProductMaterial{ProductID: productID, FrameMaterialID: id})
+	}
+	if err := tx.Create(&productMaterials).Error; err != nil {
+		return fmt.Errorf("failed to create product materials: %v", err)
+	}
+	return nil
+}
+
+// ProductMaterials 批量查询商品的全部材质，主材质排在第一位
+func ProductMaterials(productIDs []int64) (map[int64][]ProductMaterialInfo, error) {
+	materials := make(map[int64][]ProductMaterialInfo, len(productIDs))
+	if len(productIDs) == 0 {
+		return materials, nil
+	}
+
+	var rows []struct {
+		ProductID int64
+		ProductMaterialInfo
+	}
+	if err := config.DB.Table("product_material").
+		Select("product_material.product_id, frame_materials.id, frame_materials.name, products.frame_material_id = frame_materials.id AS is_primary").
+		Joins("JOIN frame_materials ON frame_materials.id = product_material.frame_material_id AND frame_materials.deleted_at IS NULL").
+		Joins("JOIN products ON products.id = product_material.product_id").
+		Where("product_material.product_id IN ?", productIDs).
+		Order("product_material.product_id, is_primary DESC, frame_materials.id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch product materials: %v", err)
+	}
+
+	for _, row := range rows {
+		materials[row.ProductID] = append(materials[row.ProductID], row.ProductMaterialInfo)
+	}
+	return materials, nil
+}
+
+// SeriesMaterialMatches 商品的材质中必须包含系列的框材质，混合材质的商品可以归入其中任一材质的系列
+func SeriesMaterialMatches(seriesMaterialID int64, materialIDs []int64) bool {
+	for _, id := range materialIDs {
+		if id == seriesMaterialID {
+			return true
+		}
+	}
+	return false
+}
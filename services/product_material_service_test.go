@@ -0,0 +1,31 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeMaterialIDs(t *testing.T) {
+	tests := []struct {
+		name        string
+		primaryID   int64
+		materialIDs []int64
+		want        []int64
+	}{
+		{"主材质排在最前", 3, []int64{1, 2}, []int64{3, 1, 2}},
+		{"去掉重复的主材质", 2, []int64{1, 2, 3}, []int64{2, 1, 3}},
+		{"去掉重复的 ID", 1, []int64{2, 2, 3, 2}, []int64{1, 2, 3}},
+		{"去掉无效的 ID", 1, []int64{0, -1, 2}, []int64{1, 2}},
+		{"没有主材质", 0, []int64{2, 1}, []int64{2, 1}},
+		{"只有主材质", 5, nil, []int64{5}},
+		{"全部为空", 0, nil, []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeMaterialIDs(tt.primaryID, tt.materialIDs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizeMaterialIDs(%d, %v) = %v, want %v", tt.primaryID, tt.materialIDs, got, tt.want)
+			}
+		})
+	}
+}
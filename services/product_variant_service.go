@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ErrDuplicateSkuCode 款式货号已被其他款式使用
+var ErrDuplicateSkuCode = errors.New("sku code already exists")
+
+// ProductVariantInfo 返回给前端的商品款式
+type ProductVariantInfo struct {
+	ID          int64              `json:"id"`
+	SkuCode     string             `json:"sku_code"`
+	ColorName   string             `json:"color_name"`
+	ColorSwatch string             `json:"color_swatch"` // 色卡图片地址
+	StockStatus string             `json:"stock_status"`
+	SortOrder   int                `json:"sort_order"`
+	Images      []ProductImageInfo `json:"images"` // 款式图片
+}
+
+// LoadProductVariants 查询商品的全部款式及款式图片，按排序返回
+func LoadProductVariants(productID int64) ([]ProductVariantInfo, error) {
+	var variants []models.ProductVariant
+	if err := config.DB.Where("product_id = ?", productID).Order("sort_order, id").
+		Find(&variants).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch product variants: %v", err)
+	}
+
+	var productImages []models.ProductImage
+	if len(variants) > 0 {
+		if err := config.DB.Where("product_id = ? AND variant_id IS NOT NULL", productID).
+			Order(ProductImageOrder).Find(&productImages).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch variant images: %v", err)
+		}
+	}
+
+	variantImages := make(map[int64][]models.ProductImage)
+	for _, img := range productImages {
+		variantImages[img.VariantID.Int64] = append(variantImages[img.VariantID.Int64], img)
+	}
+
+	infos := make([]ProductVariantInfo, 0, len(variants))
+	for _, variant := range variants {
+		infos = append(infos, ProductVariantInfo{
+			ID:          variant.ID,
+			SkuCode:     variant.SkuCode,
+			ColorName:   variant.ColorName,
+			ColorSwatch: AssetURL(variant.ColorSwatch),
+			StockStatus: variant.StockStatus,
+			SortOrder:   variant.SortOrder,
+			Images:      ProductImageInfos(variantImages[variant.ID]),
+		})
+	}
+	return infos, nil
+}
+
+// CheckSkuCodeAvailable 检查款式货号是否已被其他款式使用，excludeID 为正在修改的款式
+func CheckSkuCodeAvailable(tx *gorm.DB, skuCode string, excludeID int64) error {
+	var count int64
+	if err := tx.Model(&models.ProductVariant{}).Where("sku_code = ? AND id <> ?", skuCode, excludeID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateSkuCode
+	}
+	return nil
+}
+
+// AddVariantImages 把图片追加到商品图片并归属到款式，已存在的商品图片改为归属该款式
+func AddVariantImages(tx *gorm.DB, productID, variantID int64, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := AddProductImages(tx, productID, keys); err != nil {
+		return err
+	}
+	return tx.Model(&models.ProductImage{}).Where("product_id = ? AND image_url IN ?", productID, keys).
+		Update("variant_id", variantID).Error
+}
+
+// DeleteProductVariants 删除商品时一并删除其款式，释放全局唯一的款式货号，款式图片保留为商品通用图片
+func DeleteProductVariants(tx *gorm.DB, productIDs []int64) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+	variantIDs := tx.Model(&models.ProductVariant{}).Select("id").Where("product_id IN ?", productIDs)
+	if err := tx.Model(&models.ProductImage{}).Where("variant_id IN (?)", variantIDs).
+		Update("variant_id", nil).Error; err != nil {
+		return fmt.Errorf("failed to detach variant images: %v", err)
+	}
+	if err := tx.Where("product_id IN ?", productIDs).Delete(&models.ProductVariant{}).Error; err != nil {
+		return fmt.Errorf("failed to delete product variants: %v", err)
+	}
+	return nil
+}
+
+// SyncProductSkuCount 按款式数量更新商品的 sku_count
+func SyncProductSkuCount(tx *gorm.DB, productID int64) error {
+	var count int64
+	if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", productID).Update("sku_count", count).Error
+}
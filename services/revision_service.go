@@ -0,0 +1,367 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevisionSnapshot 对象某一时刻的数据，字段与对象返回给前端的 JSON 一致
+type RevisionSnapshot map[string]interface{}
+
+// RevisionChange 一个字段在两个版本之间的变化
+type RevisionChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// RevisionMeta 修订记录的操作人等信息
+type RevisionMeta struct {
+	SysUserID       int64
+	DraftID         int64 // 通过草稿发布时的草稿 ID
+	RestoredVersion int   // 恢复操作对应的历史版本号
+}
+
+// revisionOmittedFields 快照中不记录的关联对象和计算字段
+var revisionOmittedFields = map[string][]string{
+	models.EntityProduct:  {"series", "category", "brand", "frame_material", "category_path", "sku_count"},
+	models.EntitySeries:   {"frame_material", "is_new_design"},
+	models.EntityCategory: {"children"},
+}
+
+// revisionModels 各类对象对应的模型
+var revisionModels = map[string]interface{}{
+	models.EntityProduct:       &models.Product{},
+	models.EntitySeries:        &models.Series{},
+	models.EntityBrand:         &models.Brand{},
+	models.EntityCategory:      &models.Category{},
+	models.EntityFrameMaterial: &models.FrameMaterial{},
+}
+
+// revisionIgnoredFields 不参与版本对比的字段
+var revisionIgnoredFields = map[string]bool{"updated_at": true}
+
+// LoadRevisionSnapshot 读取对象当前的数据，已删除的对象也能读取；在事务中调用时传入 tx
+// 商品的快照还包含全部材质 frame_material_ids 和通用图片 image_urls（对象路径，按顺序排列）
+func LoadRevisionSnapshot(db *gorm.DB, entityType string, entityID int64) (RevisionSnapshot, error) {
+	snapshots, err := LoadRevisionSnapshots(db, entityType, []int64{entityID})
+	if err != nil {
+		return nil, err
+	}
+	snapshot, exists := snapshots[entityID]
+	if !exists {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return snapshot, nil
+}
+
+// LoadRevisionSnapshots 批量读取对象当前的数据，返回以对象 ID 为 key 的快照，不存在的对象不在结果中
+func LoadRevisionSnapshots(db *gorm.DB, entityType string, entityIDs []int64) (map[int64]RevisionSnapshot, error) {
+	snapshots := make(map[int64]RevisionSnapshot, len(entityIDs))
+	if len(entityIDs) == 0 {
+		return snapshots, nil
+	}
+
+	var (
+		list []RevisionSnapshot
+		err  error
+	)
+	switch entityType {
+	case models.EntityProduct:
+		list, err = loadRevisionRecords[models.Product](db, entityIDs)
+	case models.EntitySeries:
+		list, err = loadRevisionRecords[models.Series](db, entityIDs)
+	case models.EntityBrand:
+		list, err = loadRevisionRecords[models.Brand](db, entityIDs)
+	case models.EntityCategory:
+		list, err = loadRevisionRecords[models.Category](db, entityIDs)
+	case models.EntityFrameMaterial:
+		list, err = loadRevisionRecords[models.FrameMaterial](db, entityIDs)
+	default:
+		return nil, fmt.Errorf("unknown entity type %q", entityType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snapshot := range list {
+		for _, field := range revisionOmittedFields[entityType] {
+			delete(snapshot, field)
+		}
+		id, _ := snapshot["id"].(float64)
+		snapshots[int64(id)] = snapshot
+	}
+
+	if entityType == models.EntityProduct {
+		if err := addProductRevisionFields(db, snapshots); err != nil {
+			return nil, err
+		}
+	}
+
+	return snapshots, nil
+}
+
+// loadRevisionRecords 按 ID 查询对象（包括已删除的）并转换为快照
+func loadRevisionRecords[T any](db *gorm.DB, entityIDs []int64) ([]RevisionSnapshot, error) {
+	var records []T
+	if err := db.Unscoped().Where("id IN ?", entityIDs).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	list := make([]RevisionSnapshot, 0, len(records))
+	for i := range records {
+		snapshot, err := toRevisionSnapshot(&records[i])
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, snapshot)
+	}
+	return list, nil
+}
+
+// addProductRevisionFields 在商品快照中加入全部材质和通用图片
+func addProductRevisionFields(db *gorm.DB, snapshots map[int64]RevisionSnapshot) error {
+	productIDs := make([]int64, 0, len(snapshots))
+	for id := range snapshots {
+		productIDs = append(productIDs, id)
+	}
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	var materials []models.ProductMaterial
+	if err := db.Where("product_id IN ?", productIDs).
+		Order("product_id, frame_material_id").Find(&materials).Error; err != nil {
+		return fmt.Errorf("failed to fetch product materials: %v", err)
+	}
+	var images []models.ProductImage
+	if err := db.Select("product_id, image_url").Where("product_id IN ? AND variant_id IS NULL", productIDs).
+		Order("product_id, sort_order, id").Find(&images).Error; err != nil {
+		return fmt.Errorf("failed to fetch product images: %v", err)
+	}
+
+	materialIDs := make(map[int64][]int64, len(productIDs))
+	for _, material := range materials {
+		materialIDs[material.ProductID] = append(materialIDs[material.ProductID], material.FrameMaterialID)
+	}
+	imageKeys := make(map[int64][]string, len(productIDs))
+	for _, image := range images {
+		imageKeys[image.ProductID.Int64] = append(imageKeys[image.ProductID.Int64], image.ImageURL)
+	}
+
+	for id, snapshot := range snapshots {
+		snapshot["frame_material_ids"] = materialIDs[id]
+		snapshot["image_urls"] = imageKeys[id]
+
+		// 统一为 JSON 解码后的类型，保证与其他快照对比时一致
+		normalized, err := toRevisionSnapshot(snapshot)
+		if err != nil {
+			return err
+		}
+		snapshots[id] = normalized
+	}
+	return nil
+}
+
+// RecordRevision 在对象变更后保存一个版本，before 为变更前的快照，新建时为 nil
+// 删除时快照记录删除前的数据，不计算变化字段
+func RecordRevision(db *gorm.DB, entityType string, entityID int64, action string, before RevisionSnapshot, meta RevisionMeta) error {
+	snapshot := before
+	var diff map[string]RevisionChange
+	if action != models.RevisionDelete {
+		after, err := LoadRevisionSnapshot(db, entityType, entityID)
+		if err != nil {
+			return fmt.Errorf("failed to load %s %d: %v", entityType, entityID, err)
+		}
+		snapshot = after
+		diff = diffRevisionSnapshots(before, after)
+
+		// 内容没有变化时不产生新版本
+		if action == models.RevisionUpdate && len(diff) == 0 {
+			return nil
+		}
+	}
+	if snapshot == nil {
+		return fmt.Errorf("missing snapshot of %s %d", entityType, entityID)
+	}
+
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	revision := models.Revision{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Snapshot:   snapshotJSON,
+	}
+	if diff != nil {
+		if revision.Diff, err = json.Marshal(diff); err != nil {
+			return err
+		}
+	}
+	if meta.SysUserID != 0 {
+		revision.SysUserID = &meta.SysUserID
+	}
+	if meta.DraftID != 0 {
+		revision.DraftID = &meta.DraftID
+	}
+	if meta.RestoredVersion != 0 {
+		revision.RestoredVersion = &meta.RestoredVersion
+	}
+
+	// 同一对象并发写入时版本号冲突会由唯一索引拒绝
+	if err := db.Model(&models.Revision{}).Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Select("COALESCE(MAX(version), 0) + 1").Scan(&revision.Version).Error; err != nil {
+		return err
+	}
+	return db.Create(&revision).Error
+}
+
+// CreateWithRevision 在同一个事务中新建对象并保存第一个版本，create 执行实际的新建并返回新对象的 ID
+// create 返回的错误原样返回，调用方可以继续判断唯一约束冲突等错误
+func CreateWithRevision(entityType string, sysUserID int64, create func(tx *gorm.DB) (int64, error)) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		entityID, err := create(tx)
+		if err != nil {
+			return err
+		}
+		if err := RecordRevision(tx, entityType, entityID, models.RevisionCreate, nil, RevisionMeta{SysUserID: sysUserID}); err != nil {
+			return fmt.Errorf("failed to record %s %d revision: %v", entityType, entityID, err)
+		}
+		return nil
+	})
+}
+
+// UpdateWithRevision 在同一个事务中修改对象并保存版本，修改前的快照在锁定对象后读取
+// meta.RestoredVersion 不为 0 时记录为恢复操作；update 返回的错误原样返回
+func UpdateWithRevision(entityType string, entityID int64, meta RevisionMeta, update func(tx *gorm.DB) error) error {
+	model, ok := revisionModels[entityType]
+	if !ok {
+		return fmt.Errorf("unknown entity type %q", entityType)
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var ids []int64
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(model).
+			Where("id = ?", entityID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		before, err := LoadRevisionSnapshot(tx, entityType, entityID)
+		if err != nil {
+			return fmt.Errorf("failed to load %s %d: %v", entityType, entityID, err)
+		}
+
+		if err := update(tx); err != nil {
+			return err
+		}
+
+		action := models.RevisionUpdate
+		if meta.RestoredVersion != 0 {
+			action = models.RevisionRestore
+		}
+		if err := RecordRevision(tx, entityType, entityID, action, before, meta); err != nil {
+			return fmt.Errorf("failed to record %s %d revision: %v", entityType, entityID, err)
+		}
+		return nil
+	})
+}
+
+// DeleteWithRevisions 在同一个事务中删除对象并保存删除前的版本，快照在删除前批量读取
+// del 执行实际的删除，返回错误时不会删除也不会产生修订记录
+func DeleteWithRevisions(entityType string, entityIDs []int64, sysUserID int64, del func(tx *gorm.DB) error) error {
+	model, ok := revisionModels[entityType]
+	if !ok {
+		return fmt.Errorf("unknown entity type %q", entityType)
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		// 之前已经删除的对象不重复记录
+		var ids []int64
+		if err := tx.Model(model).Where("id IN ?", entityIDs).Order("id").Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		snapshots, err := LoadRevisionSnapshots(tx, entityType, ids)
+		if err != nil {
+			return fmt.Errorf("failed to load %s snapshots: %v", entityType, err)
+		}
+
+		if err := del(tx); err != nil {
+			return err
+		}
+
+		meta := RevisionMeta{SysUserID: sysUserID}
+		for _, id := range ids {
+			if err := RecordRevision(tx, entityType, id, models.RevisionDelete, snapshots[id], meta); err != nil {
+				return fmt.Errorf("failed to record %s %d revision: %v", entityType, id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// FindRevision 查询对象的指定版本
+func FindRevision(db *gorm.DB, entityType string, entityID int64, version int) (*models.Revision, error) {
+	var revision models.Revision
+	if err := db.Where("entity_type = ? AND entity_id = ? AND version = ?", entityType, entityID, version).
+		First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// MissingAssets 返回已经不在存储中的文件，恢复历史版本前检查引用的图片和 PDF 是否还在
+func MissingAssets(keys []string) ([]string, error) {
+	var missing []string
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if _, err := storage.Stat(key); err != nil {
+			if !errors.Is(err, ErrObjectNotFound) {
+				return nil, err
+			}
+			missing = append(missing, key)
+		}
+	}
+	return missing, nil
+}
+
+// toRevisionSnapshot 把对象按 JSON 转换为快照
+func toRevisionSnapshot(record interface{}) (RevisionSnapshot, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot RevisionSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// diffRevisionSnapshots 对比两个快照，返回变化的字段
+func diffRevisionSnapshots(before, after RevisionSnapshot) map[string]RevisionChange {
+	diff := make(map[string]RevisionChange)
+	for field, to := range after {
+		if revisionIgnoredFields[field] {
+			continue
+		}
+		from, ok := before[field]
+		if !ok || !reflect.DeepEqual(from, to) {
+			diff[field] = RevisionChange{From: from, To: to}
+		}
+	}
+	for field, from := range before {
+		if _, ok := after[field]; !ok && !revisionIgnoredFields[field] {
+			diff[field] = RevisionChange{From: from}
+		}
+	}
+	return diff
+}
@@ -0,0 +1,498 @@
+package services
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"gorm.io/gorm"
+)
+
+// 默认的索引目录，可通过 SEARCH_INDEX_PATH 修改
+const defaultSearchIndexPath = "data/search.bleve"
+
+// 搜索时每次从索引中读取的结果数，分页读取全部结果后再与数据库的筛选条件求交集
+const searchPageSize = 1000
+
+// 商品变化后延迟更新索引，合并短时间内的多次修改
+const searchIndexDebounce = 2 * time.Second
+
+// 重建索引时每批处理的商品数
+const searchIndexBatchSize = 500
+
+// 型号、货号整体小写后索引，用于前缀匹配
+const searchCodeAnalyzer = "code"
+
+// ErrSearchUnavailable 搜索索引未初始化，调用方应退回数据库查询
+var ErrSearchUnavailable = errors.New("search index unavailable")
+
+// ErrSearchRebuildRunning 已有重建任务在运行
+var ErrSearchRebuildRunning = errors.New("search index rebuild is already running")
+
+var (
+	searchIndex bleve.Index
+
+	searchPendingMu sync.Mutex
+	searchPending   = make(map[int64]bool)
+	searchTimer     *time.Timer
+
+	// 同一时间只运行一次重建
+	searchRebuildMu sync.Mutex
+)
+
+// productDocument 索引中的商品文档，文档 ID 为商品 ID
+type productDocument struct {
+	ModelNO     string `json:"model_no"`
+	ItemCode    string `json:"item_code"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Series      string `json:"series"`
+	Brand       string `json:"brand"`
+	Category    string `json:"category"`
+	Materials   string `json:"materials"`
+}
+
+// ProductSearchHit 一个搜索结果，Highlights 为各字段中用 <mark> 标出的匹配片段
+type ProductSearchHit struct {
+	ID         int64               `json:"id"`
+	Score      float64             `json:"score"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// ProductSearchResult 按相关度从高到低排列的搜索结果
+type ProductSearchResult struct {
+	Hits []ProductSearchHit
+}
+
+// IDs 按相关度排列的商品 ID
+func (r *ProductSearchResult) IDs() []int64 {
+	ids := make([]int64, 0, len(r.Hits))
+	for _, hit := range r.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+// OrderBy 按相关度排序的 ORDER BY 表达式，column 为商品 ID 列；没有结果时返回空字符串
+func (r *ProductSearchResult) OrderBy(column string) string {
+	if len(r.Hits) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString("FIELD(")
+	builder.WriteString(column)
+	for _, hit := range r.Hits {
+		builder.WriteString(",")
+		builder.WriteString(strconv.FormatInt(hit.ID, 10))
+	}
+	builder.WriteString(")")
+	return builder.String()
+}
+
+// Highlights 按商品 ID 索引的高亮片段
+func (r *ProductSearchResult) Highlights() map[int64]map[string][]string {
+	highlights := make(map[int64]map[string][]string, len(r.Hits))
+	for _, hit := range r.Hits {
+		if len(hit.Highlights) > 0 {
+			highlights[hit.ID] = hit.Highlights
+		}
+	}
+	return highlights
+}
+
+// InitSearch 打开商品搜索索引，索引不存在时新建空索引，created 为 true 时需要调用 RebuildSearchIndex
+func InitSearch() (created bool, err error) {
+	indexPath := os.Getenv("SEARCH_INDEX_PATH")
+	if indexPath == "" {
+		indexPath = defaultSearchIndexPath
+	}
+
+	index, err := bleve.Open(indexPath)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		indexMapping, err := newSearchIndexMapping()
+		if err != nil {
+			return false, err
+		}
+		if index, err = bleve.New(indexPath, indexMapping); err != nil {
+			return false, fmt.Errorf("failed to create search index: %v", err)
+		}
+		searchIndex = index
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open search index: %v", err)
+	}
+
+	searchIndex = index
+	return false, nil
+}
+
+// CloseSearch 关闭搜索索引，释放索引目录的文件锁
+func CloseSearch() error {
+	if searchIndex == nil {
+		return nil
+	}
+	return searchIndex.Close()
+}
+
+// newSearchIndexMapping 中文按双字切分，英文按单词切分并转为小写；型号、货号另外整体索引用于前缀匹配
+func newSearchIndexMapping() (*mapping.IndexMappingImpl, error) {
+	indexMapping := bleve.NewIndexMapping()
+	if err := indexMapping.AddCustomAnalyzer(searchCodeAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{lowercase.Name},
+	}); err != nil {
+		return nil, err
+	}
+	indexMapping.DefaultAnalyzer = cjk.AnalyzerName
+
+	textField := func(name string) *mapping.FieldMapping {
+		field := bleve.NewTextFieldMapping()
+		field.Name = name
+		field.Analyzer = cjk.AnalyzerName
+		return field
+	}
+	codeField := func(name string) *mapping.FieldMapping {
+		field := bleve.NewTextFieldMapping()
+		field.Name = name
+		field.Analyzer = searchCodeAnalyzer
+		field.Store = false
+		field.IncludeTermVectors = false
+		field.IncludeInAll = false
+		return field
+	}
+
+	product := bleve.NewDocumentStaticMapping()
+	product.AddFieldMappingsAt("model_no", textField("model_no"), codeField("model_no_code"))
+	product.AddFieldMappingsAt("item_code", textField("item_code"), codeField("item_code_code"))
+	for _, name := range []string{"title", "description", "series", "brand", "category", "materials"} {
+		product.AddFieldMappingsAt(name, textField(name))
+	}
+	indexMapping.DefaultMapping = product
+
+	return indexMapping, nil
+}
+
+// SearchProducts 按关键词搜索商品，多个词之间为“且”的关系，每个词可以出现在任一字段中
+// 型号、货号前缀匹配的权重最高，其次是标题、系列、品牌等名称，描述最低；4 个字母以上的英文单词允许拼写错误
+// 返回全部匹配的商品，不截断，调用方在数据库中按其他条件筛选和分页
+func SearchProducts(keyword string) (*ProductSearchResult, error) {
+	if searchIndex == nil {
+		return nil, ErrSearchUnavailable
+	}
+
+	words := strings.Fields(keyword)
+	if len(words) == 0 {
+		return &ProductSearchResult{}, nil
+	}
+
+	conjuncts := make([]query.Query, 0, len(words))
+	for _, word := range words {
+		conjuncts = append(conjuncts, searchWordQuery(word))
+	}
+
+	// 分页读取全部匹配的商品，列表的总数和分页由数据库在全部结果上计算
+	searchQuery := bleve.NewConjunctionQuery(conjuncts...)
+	result := &ProductSearchResult{}
+	for from := 0; ; from += searchPageSize {
+		request := bleve.NewSearchRequestOptions(searchQuery, searchPageSize, from, false)
+		request.Highlight = bleve.NewHighlightWithStyle(html.Name)
+		request.Highlight.Fields = []string{"model_no", "item_code", "title", "description", "series", "brand", "category", "materials"}
+
+		searchResult, err := searchIndex.Search(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search products: %v", err)
+		}
+
+		for _, hit := range searchResult.Hits {
+			id, err := strconv.ParseInt(hit.ID, 10, 64)
+			if err != nil {
+				continue
+			}
+			result.Hits = append(result.Hits, ProductSearchHit{
+				ID:         id,
+				Score:      hit.Score,
+				Highlights: matchedFragments(hit.Fragments),
+			})
+		}
+		if len(searchResult.Hits) < searchPageSize || uint64(from+len(searchResult.Hits)) >= searchResult.Total {
+			break
+		}
+	}
+	return result, nil
+}
+
+// matchedFragments 去掉没有匹配的字段，高亮器会返回所有存储字段的内容
+func matchedFragments(fragments map[string][]string) map[string][]string {
+	matched := make(map[string][]string, len(fragments))
+	for field, values := range fragments {
+		for _, value := range values {
+			if strings.Contains(value, "<mark>") {
+				matched[field] = append(matched[field], value)
+			}
+		}
+	}
+	return matched
+}
+
+// searchWordQuery 一个搜索词在各字段中的匹配，取分数之和
+func searchWordQuery(word string) query.Query {
+	lower := strings.ToLower(word)
+	disjuncts := make([]query.Query, 0, 16)
+	for _, field := range []string{"model_no_code", "item_code_code"} {
+		// 完全相同的型号排在前缀相同的型号之前
+		term := bleve.NewTermQuery(lower)
+		term.SetField(field)
+		term.SetBoost(20)
+		prefix := bleve.NewPrefixQuery(lower)
+		prefix.SetField(field)
+		prefix.SetBoost(10)
+		disjuncts = append(disjuncts, term, prefix)
+	}
+
+	fieldBoosts := map[string]float64{
+		"model_no":    5,
+		"item_code":   5,
+		"title":       3,
+		"series":      2,
+		"brand":       2,
+		"category":    2,
+		"materials":   2,
+		"description": 1,
+	}
+	for field, boost := range fieldBoosts {
+		match := bleve.NewMatchQuery(word)
+		match.SetField(field)
+		match.SetOperator(query.MatchQueryOperatorAnd) // 中文词切分后的每个双字都要匹配
+		match.SetBoost(boost)
+		disjuncts = append(disjuncts, match)
+	}
+
+	// 拼写容错只用于较长的英文单词，中文双字和短词容错后会匹配到大量无关结果
+	if fuzziness := searchFuzziness(word); fuzziness > 0 {
+		match := bleve.NewMatchQuery(word)
+		match.SetFuzziness(fuzziness)
+		match.SetBoost(0.5)
+		disjuncts = append(disjuncts, match)
+	}
+
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
+
+// searchFuzziness 英文单词允许的编辑距离，4 到 7 个字母允许 1 处错误，更长的允许 2 处
+func searchFuzziness(word string) int {
+	letters := 0
+	for _, r := range word {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			return 0
+		}
+		letters++
+	}
+	switch {
+	case letters >= 8:
+		return 2
+	case letters >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ScheduleSearchIndex 商品新建、修改或删除后调用，延迟更新这些商品的索引
+func ScheduleSearchIndex(productIDs ...int64) {
+	if searchIndex == nil {
+		return
+	}
+
+	searchPendingMu.Lock()
+	defer searchPendingMu.Unlock()
+
+	for _, id := range productIDs {
+		if id != 0 {
+			searchPending[id] = true
+		}
+	}
+	if len(searchPending) == 0 {
+		return
+	}
+
+	if searchTimer != nil {
+		searchTimer.Reset(searchIndexDebounce)
+		return
+	}
+	searchTimer = time.AfterFunc(searchIndexDebounce, func() {
+		searchPendingMu.Lock()
+		ids := make([]int64, 0, len(searchPending))
+		for id := range searchPending {
+			ids = append(ids, id)
+		}
+		searchPending = make(map[int64]bool)
+		searchTimer = nil
+		searchPendingMu.Unlock()
+
+		if err := IndexProducts(ids); err != nil {
+			log.Printf("更新搜索索引失败: %v", err)
+		}
+	})
+}
+
+// ScheduleSearchIndexWhere 系列、品牌、分类或材质改名、删除后调用，延迟更新引用它们的商品
+// condition 为 products 表的查询条件，如 "series_id = ?"
+func ScheduleSearchIndexWhere(condition string, args ...interface{}) {
+	if searchIndex == nil {
+		return
+	}
+
+	var productIDs []int64
+	if err := config.DB.Model(&models.Product{}).Where(condition, args...).
+		Pluck("id", &productIDs).Error; err != nil {
+		log.Printf("查询需要更新索引的商品失败: %v", err)
+		return
+	}
+	ScheduleSearchIndex(productIDs...)
+}
+
+// IndexProducts 从数据库读取商品并更新索引，已删除的商品从索引中移除
+func IndexProducts(productIDs []int64) error {
+	if searchIndex == nil {
+		return ErrSearchUnavailable
+	}
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	var products []models.Product
+	if err := config.DB.Preload("Series").Preload("Category").Preload("Brand").
+		Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return fmt.Errorf("failed to fetch products: %v", err)
+	}
+
+	batch := searchIndex.NewBatch()
+	if err := addProductDocuments(batch, products); err != nil {
+		return err
+	}
+
+	found := make(map[int64]bool, len(products))
+	for _, product := range products {
+		found[product.ID] = true
+	}
+	for _, id := range productIDs {
+		if !found[id] {
+			batch.Delete(strconv.FormatInt(id, 10))
+		}
+	}
+
+	return searchIndex.Batch(batch)
+}
+
+// RebuildSearchIndex 从数据库重建全部商品的索引并移除已删除的商品，返回索引的商品数
+func RebuildSearchIndex() (int, error) {
+	if searchIndex == nil {
+		return 0, ErrSearchUnavailable
+	}
+
+	if !searchRebuildMu.TryLock() {
+		return 0, ErrSearchRebuildRunning
+	}
+	defer searchRebuildMu.Unlock()
+
+	indexed := make(map[string]bool)
+	var products []models.Product
+	err := config.DB.Preload("Series").Preload("Category").Preload("Brand").
+		FindInBatches(&products, searchIndexBatchSize, func(tx *gorm.DB, _ int) error {
+			batch := searchIndex.NewBatch()
+			if err := addProductDocuments(batch, products); err != nil {
+				return err
+			}
+			for _, product := range products {
+				indexed[strconv.FormatInt(product.ID, 10)] = true
+			}
+			return searchIndex.Batch(batch)
+		}).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to index products: %v", err)
+	}
+
+	// 移除数据库中已不存在的商品
+	docCount, err := searchIndex.DocCount()
+	if err != nil {
+		return 0, err
+	}
+	if docCount > uint64(len(indexed)) {
+		request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(docCount), 0, false)
+		searchResult, err := searchIndex.Search(request)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list indexed products: %v", err)
+		}
+		batch := searchIndex.NewBatch()
+		for _, hit := range searchResult.Hits {
+			if !indexed[hit.ID] {
+				batch.Delete(hit.ID)
+			}
+		}
+		if err := searchIndex.Batch(batch); err != nil {
+			return 0, err
+		}
+	}
+
+	log.Printf("已重建搜索索引，共 %d 个商品", len(indexed))
+	return len(indexed), nil
+}
+
+// addProductDocuments 把商品（需预加载系列、分类和品牌）及其全部材质名称加入批量操作
+func addProductDocuments(batch *bleve.Batch, products []models.Product) error {
+	productIDs := make([]int64, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+	materials, err := ProductMaterials(productIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		materialNames := make([]string, 0, len(materials[product.ID]))
+		for _, material := range materials[product.ID] {
+			materialNames = append(materialNames, material.Name)
+		}
+
+		document := productDocument{
+			ModelNO:     product.ModelNO,
+			ItemCode:    product.ItemCode.String,
+			Title:       product.Title,
+			Description: product.Description,
+			Materials:   strings.Join(materialNames, " "),
+		}
+		if product.Series != nil {
+			document.Series = product.Series.Name
+		}
+		if product.Category != nil {
+			document.Category = product.Category.Name
+		}
+		if product.Brand != nil {
+			document.Brand = product.Brand.Name
+		}
+
+		if err := batch.Index(strconv.FormatInt(product.ID, 10), document); err != nil {
+			return fmt.Errorf("failed to index product %d: %v", product.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"bytes"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // 注册 GIF 解码
+	"image/jpeg"
+	_ "image/png" // 注册 PNG 解码
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册 WebP 解码
+	"gorm.io/gorm"
+)
+
+// 自动生成的目录 PDF 在存储中的目录
+const seriesCatalogFolder = "pdfs/catalogs"
+
+// 商品变化后延迟重新生成目录，合并短时间内的多次修改
+const seriesCatalogDebounce = 30 * time.Second
+
+// 目录版式：A4 纵向，每页 3 列 4 行
+const (
+	catalogPageMargin   = 12.0
+	catalogColumns      = 3
+	catalogRows         = 4
+	catalogCellGap      = 4.0
+	catalogCaptionLines = 3
+	catalogLineHeight   = 4.5
+	catalogImageMaxSize = 600 // 嵌入图片的最大边长（像素），与 medium 尺寸一致，避免 PDF 过大
+)
+
+var (
+	// 每个系列同一时间只生成一份目录
+	seriesCatalogLocks sync.Map
+
+	seriesCatalogTimersMu sync.Mutex
+	seriesCatalogTimers   = make(map[int64]*time.Timer)
+)
+
+// catalogImageVariants 目录优先使用的图片尺寸，都没有时使用原图
+var catalogImageVariants = []string{"medium", "large"}
+
+// catalogProduct 目录中的一个商品格子，图片在绘制到格子时才下载，避免同时占用所有图片的内存
+type catalogProduct struct {
+	product  models.Product
+	imageKey string
+}
+
+// GenerateSeriesCatalog 根据系列下的商品生成目录 PDF 并上传，更新 Series.PdfURL 并开启自动更新，同时记录一个版本
+// 带水印的副本会被删除，旧的 PDF 由孤立文件回收任务清理。自动重新生成时 sysUserID 为 0
+func GenerateSeriesCatalog(seriesID, sysUserID int64) (*models.Series, error) {
+	lock, _ := seriesCatalogLocks.LoadOrStore(seriesID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	var series models.Series
+	if err := config.DB.Preload("FrameMaterial").First(&series, seriesID).Error; err != nil {
+		return nil, err
+	}
+
+	var products []models.Product
+	if err := config.DB.Preload("FrameMaterial").
+		Where("series_id = ?", seriesID).
+		Order("model_no").
+		Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]int64, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+	mainImages, err := PrimaryProductImages(productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch product images: %v", err)
+	}
+
+	items := make([]catalogProduct, 0, len(products))
+	for _, product := range products {
+		item := catalogProduct{product: product}
+		if mainImage, exists := mainImages[product.ID]; exists {
+			item.imageKey = catalogImageKey(mainImage)
+		}
+		items = append(items, item)
+	}
+
+	var output bytes.Buffer
+	if err := renderSeriesCatalog(&output, &series, items); err != nil {
+		return nil, fmt.Errorf("failed to render catalog: %v", err)
+	}
+
+	objectKey := fmt.Sprintf("%s/series_%d_%d.pdf", seriesCatalogFolder, series.ID, time.Now().UnixNano())
+	if err := storage.Put(objectKey, &output, "application/pdf"); err != nil {
+		return nil, fmt.Errorf("failed to upload catalog: %v", err)
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := LoadRevisionSnapshot(tx, models.EntitySeries, series.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&series).Updates(map[string]interface{}{
+			"pdf_url":      objectKey,
+			"auto_catalog": true,
+		}).Error; err != nil {
+			return err
+		}
+		return RecordRevision(tx, models.EntitySeries, series.ID, models.RevisionUpdate, before, RevisionMeta{SysUserID: sysUserID})
+	})
+	if err != nil {
+		if err := DeleteFiles([]string{objectKey}); err != nil {
+			log.Printf("Failed to delete unused catalog: %v", err)
+		}
+		return nil, err
+	}
+	series.PdfURL = objectKey
+	series.AutoCatalog = true
+
+	// 旧的 PDF 可能是被其他系列共用的上传文件，由孤立文件回收任务清理
+	if err := DeleteWatermarkedSeriesPDFs(series.ID); err != nil {
+		log.Printf("Failed to delete watermarked PDF files: %v", err)
+	}
+
+	log.Printf("已生成系列 %d 的目录PDF: %s（%d 个商品）", series.ID, objectKey, len(items))
+	return &series, nil
+}
+
+// ScheduleSeriesCatalog 商品变化后调用，延迟重新生成开启了自动更新的系列目录
+func ScheduleSeriesCatalog(seriesIDs ...int64) {
+	seriesCatalogTimersMu.Lock()
+	defer seriesCatalogTimersMu.Unlock()
+
+	for _, seriesID := range seriesIDs {
+		if seriesID == 0 {
+			continue
+		}
+
+		if timer, exists := seriesCatalogTimers[seriesID]; exists {
+			timer.Reset(seriesCatalogDebounce)
+			continue
+		}
+
+		id := seriesID
+		seriesCatalogTimers[id] = time.AfterFunc(seriesCatalogDebounce, func() {
+			seriesCatalogTimersMu.Lock()
+			delete(seriesCatalogTimers, id)
+			seriesCatalogTimersMu.Unlock()
+
+			regenerateSeriesCatalog(id)
+		})
+	}
+}
+
+// regenerateSeriesCatalog 只重新生成开启了自动更新的系列，手动上传的 PDF 不会被覆盖
+func regenerateSeriesCatalog(seriesID int64) {
+	var count int64
+	if err := config.DB.Model(&models.Series{}).
+		Where("id = ? AND auto_catalog = ?", seriesID, true).
+		Count(&count).Error; err != nil {
+		log.Printf("查询系列 %d 失败: %v", seriesID, err)
+		return
+	}
+	if count == 0 {
+		return
+	}
+
+	if _, err := GenerateSeriesCatalog(seriesID, 0); err != nil {
+		log.Printf("自动生成系列 %d 的目录PDF失败: %v", seriesID, err)
+	}
+}
+
+// catalogImageKey 选择嵌入目录的图片，优先使用处理流程生成的中图，避免下载和解码原图
+func catalogImageKey(mainImage models.ProductImage) string {
+	for _, name := range catalogImageVariants {
+		if variant, exists := mainImage.Variants[name]; exists && variant.Key != "" {
+			return variant.Key
+		}
+	}
+	return mainImage.ImageURL
+}
+
+// loadCatalogImage 下载商品主图并缩放，失败时返回 nil，目录中显示空白
+func loadCatalogImage(productID int64, imageKey string) image.Image {
+	body, err := storage.Get(imageKey)
+	if err != nil {
+		log.Printf("下载商品 %d 的主图失败: %v", productID, err)
+		return nil
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			log.Printf("关闭文件错误: %v", err)
+		}
+	}()
+
+	img, _, err := image.Decode(body)
+	if err != nil {
+		log.Printf("解析商品 %d 的主图失败: %v", productID, err)
+		return nil
+	}
+
+	// 缩放并铺白底，透明图片转为 JPEG 后不会变黑
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > catalogImageMaxSize || height > catalogImageMaxSize {
+		if width >= height {
+			height = height * catalogImageMaxSize / width
+			width = catalogImageMaxSize
+		} else {
+			width = width * catalogImageMaxSize / height
+			height = catalogImageMaxSize
+		}
+	}
+	if width == 0 || height == 0 {
+		return nil
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	xdraw.CatmullRom.Scale(canvas, canvas.Bounds(), img, bounds, xdraw.Over, nil)
+	return canvas
+}
+
+// renderSeriesCatalog 绘制目录：封面和商品网格页
+func renderSeriesCatalog(w *bytes.Buffer, series *models.Series, items []catalogProduct) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(catalogPageMargin, catalogPageMargin, catalogPageMargin)
+	pdf.SetAutoPageBreak(false, catalogPageMargin)
+	pdf.SetTitle(series.Name, true)
+
+	// 默认字体只支持拉丁字符，中文内容需要通过 PDF_FONT_FILE 指定 TTF 字体
+	family := "Helvetica"
+	text := pdf.UnicodeTranslatorFromDescriptor("")
+	if fontFile := pdfFontFile(); fontFile != "" {
+		pdf.AddUTF8Font("catalog", "", fontFile)
+		pdf.AddUTF8Font("catalog", "B", fontFile)
+		family = "catalog"
+		text = func(s string) string { return s }
+	}
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	contentWidth := pageWidth - 2*catalogPageMargin
+	generatedAt := time.Now().Format("2006-01-02")
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(pageHeight - catalogPageMargin + 2)
+		pdf.SetFont(family, "", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(contentWidth/2, 5, text(series.Name), "", 0, "L", false, 0, "")
+		pdf.CellFormat(contentWidth/2, 5, fmt.Sprintf("%d", pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+
+	// 封面
+	pdf.AddPage()
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetY(pageHeight / 3)
+	pdf.SetFont(family, "B", 28)
+	pdf.MultiCell(contentWidth, 12, text(series.Name), "", "C", false)
+	pdf.Ln(6)
+	pdf.SetFont(family, "", 12)
+	pdf.CellFormat(contentWidth, 7, text(series.FrameMaterial.Name), "", 1, "C", false, 0, "")
+	pdf.CellFormat(contentWidth, 7, fmt.Sprintf("%d models", len(items)), "", 1, "C", false, 0, "")
+	pdf.CellFormat(contentWidth, 7, generatedAt, "", 1, "C", false, 0, "")
+	if series.Description != "" {
+		pdf.Ln(10)
+		pdf.SetFont(family, "", 11)
+		pdf.MultiCell(contentWidth, 6, text(series.Description), "", "L", false)
+	}
+
+	// 商品网格
+	cellWidth := (contentWidth - catalogCellGap*(catalogColumns-1)) / catalogColumns
+	cellHeight := (pageHeight - 2*catalogPageMargin - catalogCellGap*(catalogRows-1)) / catalogRows
+	imageHeight := cellHeight - catalogCaptionLines*catalogLineHeight - 3
+	perPage := catalogColumns * catalogRows
+
+	for i, item := range items {
+		if i%perPage == 0 {
+			pdf.AddPage()
+		}
+
+		position := i % perPage
+		x := catalogPageMargin + float64(position%catalogColumns)*(cellWidth+catalogCellGap)
+		y := catalogPageMargin + float64(position/catalogColumns)*(cellHeight+catalogCellGap)
+
+		pdf.SetDrawColor(220, 220, 220)
+		pdf.Rect(x, y, cellWidth, cellHeight, "D")
+
+		// 图片在绘制时才下载并编码，注册到 PDF 后只保留压缩后的 JPEG
+		var img image.Image
+		if item.imageKey != "" {
+			img = loadCatalogImage(item.product.ID, item.imageKey)
+		}
+		if img != nil {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err == nil {
+				name := fmt.Sprintf("product_%d", item.product.ID)
+				options := fpdf.ImageOptions{ImageType: "JPG"}
+				info := pdf.RegisterImageOptionsReader(name, options, &buf)
+				if info != nil {
+					// 等比缩放后居中
+					boxWidth, boxHeight := cellWidth-4, imageHeight-2
+					width, height := info.Extent()
+					scale := boxWidth / width
+					if height*scale > boxHeight {
+						scale = boxHeight / height
+					}
+					width, height = width*scale, height*scale
+					pdf.ImageOptions(name, x+(cellWidth-width)/2, y+2+(boxHeight-height)/2, width, height, false, options, 0, "")
+				}
+			}
+		}
+
+		product := item.product
+		pdf.SetXY(x, y+imageHeight+1)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetFont(family, "B", 10)
+		pdf.CellFormat(cellWidth, catalogLineHeight, text(product.ModelNO), "", 2, "C", false, 0, "")
+		pdf.SetFont(family, "", 8)
+		sizes := fmt.Sprintf("%g-%g-%g", product.LensWidth, product.NoseBridge, product.TempleLength)
+		pdf.CellFormat(cellWidth, catalogLineHeight, sizes, "", 2, "C", false, 0, "")
+		material := ""
+		if product.FrameMaterial != nil {
+			material = product.FrameMaterial.Name
+		}
+		pdf.CellFormat(cellWidth, catalogLineHeight, text(material), "", 2, "C", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
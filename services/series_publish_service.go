@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 后台设置发布时间使用的格式，按服务器本地时区解析
+const SeriesPublishTimeLayout = "2006-01-02 15:04:05"
+
+// ErrInvalidPublishWindow 发布时间的先后顺序不正确
+var ErrInvalidPublishWindow = errors.New("vip_from must not be after public_from, and unpublish_at must be after both")
+
+// SeriesPublishWindow 系列的发布时间窗口，nil 的含义见 models.Series
+type SeriesPublishWindow struct {
+	VipFrom     *time.Time
+	PublicFrom  *time.Time
+	UnpublishAt *time.Time
+}
+
+// ParsePublishTime 解析后台传入的发布时间，空字符串表示不设置
+func ParsePublishTime(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(SeriesPublishTimeLayout, value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Validate 检查 VIP 可见时间不晚于公开时间，下架时间晚于上架时间
+func (w SeriesPublishWindow) Validate() error {
+	if w.VipFrom != nil && w.PublicFrom != nil && w.VipFrom.After(*w.PublicFrom) {
+		return ErrInvalidPublishWindow
+	}
+	if w.UnpublishAt != nil {
+		if w.VipFrom != nil && !w.UnpublishAt.After(*w.VipFrom) {
+			return ErrInvalidPublishWindow
+		}
+		if w.PublicFrom != nil && !w.UnpublishAt.After(*w.PublicFrom) {
+			return ErrInvalidPublishWindow
+		}
+	}
+	return nil
+}
+
+// Apply 把发布时间写入系列。公开时间改为未来或取消公开后，到时会重新发出转为公开的事件；
+// 新建时已公开的系列不发出事件
+func (w SeriesPublishWindow) Apply(series *models.Series, creating bool) {
+	now := time.Now()
+	series.VipFrom = localTime(w.VipFrom)
+	series.PublicFrom = localTime(w.PublicFrom)
+	series.UnpublishAt = localTime(w.UnpublishAt)
+
+	switch {
+	case !series.IsPublicAt(now):
+		series.GraduatedAt = nil
+	case creating:
+		series.GraduatedAt = series.PublicFrom
+	}
+	series.IsNewDesign = !series.IsPublicAt(now)
+}
+
+// localTime 转换为模型使用的时间类型
+func localTime(t *time.Time) *models.LocalTime {
+	if t == nil {
+		return nil
+	}
+	lt := models.LocalTime(*t)
+	return &lt
+}
+
+// SeriesVisibility 系列对当前用户可见的条件，用于包含 series 表的查询
+// 公开的系列所有用户可见；VIP 用户从 vip_from 起即可见；过了 unpublish_at 所有用户都不可见
+func SeriesVisibility(isVIP bool) clause.Expr {
+	now := time.Now()
+	if isVIP {
+		return gorm.Expr("((series.vip_from IS NULL OR series.vip_from <= ? OR series.public_from <= ?) AND (series.unpublish_at IS NULL OR series.unpublish_at > ?))", now, now, now)
+	}
+	return gorm.Expr("(series.public_from <= ? AND (series.unpublish_at IS NULL OR series.unpublish_at > ?))", now, now)
+}
+
+// SeriesNewDesignColumn 查询 is_new_design（尚未公开）的表达式，用于 Select
+func SeriesNewDesignColumn() clause.Expr {
+	return gorm.Expr("NOT (series.public_from IS NOT NULL AND series.public_from <= ?)", time.Now())
+}
+
+// GraduateSeries 为已到公开时间的系列发出转为公开的事件，返回处理的系列数
+// 已下架的系列不发出事件；graduated_at 保证每次公开只发出一次
+func GraduateSeries() (int, error) {
+	now := time.Now()
+
+	var seriesList []models.Series
+	if err := config.DB.Select("id, name, public_from").
+		Where("public_from <= ? AND graduated_at IS NULL", now).
+		Where("unpublish_at IS NULL OR unpublish_at > ?", now).
+		Find(&seriesList).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch series to graduate: %v", err)
+	}
+
+	graduated := 0
+	for _, series := range seriesList {
+		emitted := false
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			// 多个实例同时运行时只有一个能更新成功
+			result := tx.Model(&models.Series{}).Where("id = ? AND graduated_at IS NULL", series.ID).
+				Update("graduated_at", now)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			emitted = true
+			return tx.Create(&models.SeriesPublishEvent{
+				SeriesID:   series.ID,
+				Event:      models.SeriesEventGraduated,
+				OccurredAt: time.Time(*series.PublicFrom),
+			}).Error
+		})
+		if err != nil {
+			return graduated, fmt.Errorf("failed to graduate series %d: %v", series.ID, err)
+		}
+		if emitted {
+			graduated++
+			log.Printf("系列 %d（%s）已转为公开", series.ID, series.Name)
+		}
+	}
+	return graduated, nil
+}
+
+// StartSeriesPublishJob 定时检查到达公开时间的系列并发出事件
+func StartSeriesPublishJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := GraduateSeries(); err != nil {
+				log.Printf("处理系列发布事件失败: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrObjectNotFound 文件不存在
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo 文件的基本信息
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// PresignedUpload 浏览器直传文件使用的签名，Method 为 POST 时以表单提交 Fields 和文件，为 PUT 时直接上传文件内容
+type PresignedUpload struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Fields    map[string]string `json:"fields,omitempty"`  // POST 表单字段，file 字段需放在最后
+	Headers   map[string]string `json:"headers,omitempty"` // 上传时需要携带的请求头
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// Storage 文件存储后端，key 为不带前导斜杠的对象路径，例如 images/xxx.jpg
+type Storage interface {
+	// Put 上传文件，contentType 为空时由存储后端自行判断
+	Put(key string, src io.Reader, contentType string) error
+	// Get 读取文件，调用方负责关闭
+	Get(key string) (io.ReadCloser, error)
+	// Delete 删除单个文件，文件不存在时不报错
+	Delete(key string) error
+	// DeleteBatch 批量删除文件
+	DeleteBatch(keys []string) error
+	// Stat 获取文件信息，文件不存在时返回 ErrObjectNotFound
+	Stat(key string) (*ObjectInfo, error)
+	// List 列出指定前缀下的所有文件
+	List(prefix string) ([]ObjectInfo, error)
+	// SignedURL 生成限时访问地址，filename 不为空时浏览器以该文件名下载
+	SignedURL(key string, expires time.Duration, filename string) (string, error)
+	// PublicURL 文件的公开访问地址
+	PublicURL(key string) string
+	// PresignUpload 生成浏览器直传的签名，只允许上传到 key，并限制 Content-Type 和文件大小
+	PresignUpload(key, contentType string, maxSize int64, expires time.Duration) (*PresignedUpload, error)
+}
+
+// 当前使用的存储后端，由 InitStorage 根据 STORAGE_DRIVER 初始化
+var storage Storage
+
+// InitStorage 根据 STORAGE_DRIVER 初始化文件存储：oss（默认）或 local
+func InitStorage() error {
+	driver := strings.ToLower(os.Getenv("STORAGE_DRIVER"))
+	switch driver {
+	case "", "oss":
+		s, err := newOSSStorage()
+		if err != nil {
+			return err
+		}
+		storage = s
+	case "local":
+		s, err := newLocalStorage()
+		if err != nil {
+			return err
+		}
+		storage = s
+	default:
+		return fmt.Errorf("unknown STORAGE_DRIVER: %s", driver)
+	}
+	return nil
+}
+
+// GetStorage 返回当前使用的存储后端
+func GetStorage() Storage {
+	return storage
+}
+
+// DeleteFiles 批量删除文件，参数可以是完整的文件地址或对象路径
+func DeleteFiles(urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	objectKeys := make([]string, 0, len(urls))
+	for _, fileURL := range urls {
+		if objectKey := AssetKey(fileURL, ""); objectKey != "" {
+			objectKeys = append(objectKeys, objectKey)
+		}
+	}
+
+	if len(objectKeys) == 0 {
+		return nil
+	}
+
+	return storage.DeleteBatch(objectKeys)
+}
+
+// SignDownloadURL 为私有文件生成限时下载地址，filename 为浏览器保存时使用的文件名
+func SignDownloadURL(fileURL string, expires time.Duration, filename string) (string, error) {
+	return storage.SignedURL(AssetKey(fileURL, ""), expires, filename)
+}
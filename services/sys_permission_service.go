@@ -1,9 +1,16 @@
 package services
 
 import (
+	"errors"
 	"exam_server/config"
 	"exam_server/models"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // GetSysPermissionTree 获取完整权限树结构，并在内存中根据关键词过滤
@@ -62,3 +69,226 @@ func filterPermissions(permissions []*models.SysPermission, query string) []*mod
 
 	return filteredPermissions
 }
+
+// 后台接口统一前缀，权限表中的 route 可以带也可以不带该前缀
+const sysAdminRoutePrefix = "/api/V1/admin"
+
+// 权限缓存有效期，兜底多实例部署时其它实例修改权限的情况
+const sysPermissionCacheTTL = 10 * time.Minute
+
+// sysUserGrant 后台用户的权限快照
+type sysUserGrant struct {
+	superAdmin bool
+	rules      []sysPermissionRule
+	loadedAt   time.Time
+}
+
+// sysPermissionRule 权限表中的路由和请求方法
+type sysPermissionRule struct {
+	Route  string
+	Method string
+}
+
+var (
+	sysPermissionCacheMu sync.RWMutex
+	sysPermissionCache   = make(map[int64]*sysUserGrant) // key 为后台用户 ID
+)
+
+// InvalidateSysPermissionCache 清空权限缓存，角色、权限或用户角色变更后调用
+func InvalidateSysPermissionCache() {
+	sysPermissionCacheMu.Lock()
+	sysPermissionCache = make(map[int64]*sysUserGrant)
+	sysPermissionCacheMu.Unlock()
+}
+
+// HasSysPermission 判断后台用户是否拥有访问指定路由的权限
+func HasSysPermission(sysUserID int64, method, route string) (bool, error) {
+	grant, err := getSysUserGrant(sysUserID)
+	if err != nil {
+		return false, err
+	}
+
+	if grant.superAdmin {
+		return true, nil
+	}
+
+	route = normalizeSysRoute(route)
+	for _, rule := range grant.rules {
+		if matchSysPermission(rule, method, route) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// IsSysSuperAdmin 判断后台用户是否属于超级管理员角色
+func IsSysSuperAdmin(sysUserID int64) (bool, error) {
+	grant, err := getSysUserGrant(sysUserID)
+	if err != nil {
+		return false, err
+	}
+	return grant.superAdmin, nil
+}
+
+// sysUserHoldsPermissions 判断后台用户的角色是否拥有全部指定名称的权限
+func sysUserHoldsPermissions(sysUserID int64, names []string) (bool, error) {
+	grant, err := getSysUserGrant(sysUserID)
+	if err != nil {
+		return false, err
+	}
+	if grant.superAdmin {
+		return true, nil
+	}
+
+	var count int64
+	err = config.DB.Raw(`
+		SELECT COUNT(DISTINCT p.name)
+		FROM sys_permissions p
+		INNER JOIN sys_role_permission rp ON p.id = rp.sys_permission_id
+		INNER JOIN sys_user_role ur ON rp.sys_role_id = ur.sys_role_id
+		INNER JOIN sys_roles r ON ur.sys_role_id = r.id
+		WHERE ur.sys_user_id = ?
+		AND p.name IN ?
+		AND p.deleted_at IS NULL
+		AND r.deleted_at IS NULL
+	`, sysUserID, names).Scan(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return int(count) == len(names), nil
+}
+
+// getSysUserGrant 优先从缓存获取用户权限，缓存不存在或过期时从数据库加载
+func getSysUserGrant(sysUserID int64) (*sysUserGrant, error) {
+	sysPermissionCacheMu.RLock()
+	grant, ok := sysPermissionCache[sysUserID]
+	sysPermissionCacheMu.RUnlock()
+	if ok && time.Since(grant.loadedAt) < sysPermissionCacheTTL {
+		return grant, nil
+	}
+
+	grant, err := loadSysUserGrant(sysUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	sysPermissionCacheMu.Lock()
+	sysPermissionCache[sysUserID] = grant
+	sysPermissionCacheMu.Unlock()
+
+	return grant, nil
+}
+
+// loadSysUserGrant 从 sys_user_role、sys_role_permission、sys_permissions 加载用户权限
+func loadSysUserGrant(sysUserID int64) (*sysUserGrant, error) {
+	var roleIDs []int64
+	err := config.DB.Raw(`
+		SELECT ur.sys_role_id
+		FROM sys_user_role ur
+		INNER JOIN sys_roles r ON ur.sys_role_id = r.id
+		WHERE ur.sys_user_id = ?
+		AND r.deleted_at IS NULL
+	`, sysUserID).Scan(&roleIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	grant := &sysUserGrant{loadedAt: time.Now()}
+
+	// 配置了 SUPER_ADMIN_ROLE_ID 时，该角色拥有全部权限；未配置时所有角色都按 sys_permissions 校验
+	if superRoleID, ok := superAdminRoleID(); ok {
+		for _, roleID := range roleIDs {
+			if roleID == superRoleID {
+				grant.superAdmin = true
+				return grant, nil
+			}
+		}
+	}
+
+	if len(roleIDs) == 0 {
+		return grant, nil
+	}
+
+	err = config.DB.Raw(`
+		SELECT DISTINCT p.route, p.method
+		FROM sys_permissions p
+		INNER JOIN sys_role_permission rp ON p.id = rp.sys_permission_id
+		WHERE rp.sys_role_id IN ?
+		AND p.deleted_at IS NULL
+	`, roleIDs).Scan(&grant.rules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+// CheckSysPermissionSetup 启动时检查后台权限是否可用：没有配置 SUPER_ADMIN_ROLE_ID 且没有任何角色分配了权限时，
+// 所有后台用户（包括管理权限的用户）都无法访问需要权限的接口，返回说明原因的错误
+func CheckSysPermissionSetup() error {
+	if _, ok := superAdminRoleID(); ok {
+		return nil
+	}
+
+	var count int64
+	err := config.DB.Raw(`
+		SELECT COUNT(*)
+		FROM sys_role_permission rp
+		INNER JOIN sys_permissions p ON p.id = rp.sys_permission_id
+		INNER JOIN sys_roles r ON rp.sys_role_id = r.id
+		WHERE p.deleted_at IS NULL
+		AND r.deleted_at IS NULL
+	`).Scan(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check sys permissions: %v", err)
+	}
+	if count == 0 {
+		return errors.New("SUPER_ADMIN_ROLE_ID is not set and no role has any sys_permissions, every admin will get 403; " +
+			"set SUPER_ADMIN_ROLE_ID to the administrator role ID or assign permissions to roles")
+	}
+	return nil
+}
+
+// superAdminRoleID 读取 SUPER_ADMIN_ROLE_ID，未配置或格式错误时返回 false
+func superAdminRoleID() (int64, bool) {
+	value := strings.TrimSpace(os.Getenv("SUPER_ADMIN_ROLE_ID"))
+	if value == "" {
+		return 0, false
+	}
+	roleID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || roleID <= 0 {
+		log.Printf("SUPER_ADMIN_ROLE_ID 配置无效: %q", value)
+		return 0, false
+	}
+	return roleID, true
+}
+
+// matchSysPermission 判断权限记录是否匹配当前请求，route 以 /* 结尾时按前缀匹配
+func matchSysPermission(rule sysPermissionRule, method, route string) bool {
+	ruleMethod := strings.ToUpper(strings.TrimSpace(rule.Method))
+	if ruleMethod != "*" && ruleMethod != "ANY" && ruleMethod != strings.ToUpper(method) {
+		return false
+	}
+
+	ruleRoute := strings.TrimSpace(rule.Route)
+	if strings.HasSuffix(ruleRoute, "/*") {
+		prefix := normalizeSysRoute(strings.TrimSuffix(ruleRoute, "/*"))
+		return route == prefix || strings.HasPrefix(route, prefix+"/")
+	}
+
+	return normalizeSysRoute(ruleRoute) == route
+}
+
+// normalizeSysRoute 去掉后台前缀和末尾斜杠，统一为 /xxx/yyy 的形式
+func normalizeSysRoute(route string) string {
+	route = strings.TrimSpace(route)
+	route = strings.TrimPrefix(route, sysAdminRoutePrefix)
+	if !strings.HasPrefix(route, "/") {
+		route = "/" + route
+	}
+	if len(route) > 1 {
+		route = strings.TrimSuffix(route, "/")
+	}
+	return route
+}
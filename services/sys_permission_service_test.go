@@ -0,0 +1,53 @@
+package services
+
+import "testing"
+
+func TestMatchSysPermission(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   sysPermissionRule
+		method string
+		route  string
+		want   bool
+	}{
+		{"完全匹配", sysPermissionRule{Route: "/products", Method: "GET"}, "GET", "/products", true},
+		{"方法不区分大小写", sysPermissionRule{Route: "/products", Method: " get "}, "GET", "/products", true},
+		{"方法不同", sysPermissionRule{Route: "/products", Method: "GET"}, "POST", "/products", false},
+		{"路由不同", sysPermissionRule{Route: "/products", Method: "GET"}, "GET", "/series", false},
+		{"* 匹配所有方法", sysPermissionRule{Route: "/products", Method: "*"}, "DELETE", "/products", true},
+		{"ANY 匹配所有方法", sysPermissionRule{Route: "/products", Method: "any"}, "PUT", "/products", true},
+		{"规则带管理后台前缀", sysPermissionRule{Route: "/api/V1/admin/products/", Method: "GET"}, "GET", "/products", true},
+		{"规则缺少开头的 /", sysPermissionRule{Route: "products/:id", Method: "GET"}, "GET", "/products/:id", true},
+		{"通配符匹配自身", sysPermissionRule{Route: "/products/*", Method: "GET"}, "GET", "/products", true},
+		{"通配符匹配子路由", sysPermissionRule{Route: "/products/*", Method: "GET"}, "GET", "/products/:id/images", true},
+		{"通配符不匹配相同前缀的其它路由", sysPermissionRule{Route: "/products/*", Method: "GET"}, "GET", "/products-export", false},
+		{"通配符带管理后台前缀", sysPermissionRule{Route: "/api/V1/admin/series/*", Method: "*"}, "POST", "/series/:id/catalog", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchSysPermission(tt.rule, tt.method, tt.route); got != tt.want {
+				t.Errorf("matchSysPermission(%+v, %q, %q) = %v, want %v", tt.rule, tt.method, tt.route, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeSysRoute(t *testing.T) {
+	tests := []struct {
+		route string
+		want  string
+	}{
+		{"/api/V1/admin/products", "/products"},
+		{"/api/V1/admin", "/"},
+		{"products/", "/products"},
+		{" /series/:id ", "/series/:id"},
+		{"/", "/"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeSysRoute(tt.route); got != tt.want {
+			t.Errorf("normalizeSysRoute(%q) = %q, want %q", tt.route, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// uploadRule 各类文件的上传限制
+type uploadRule struct {
+	ContentType string
+	MaxSize     int64
+	Magic       [][]byte                                // 文件头，满足其一即可
+	Check       func(src io.ReaderAt, size int64) error // 按文件类型进一步校验内容
+}
+
+var (
+	jpegMagic = [][]byte{{0xFF, 0xD8, 0xFF}}
+	pngMagic  = [][]byte{{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}}
+	gifMagic  = [][]byte{[]byte("GIF87a"), []byte("GIF89a")}
+	pdfMagic  = [][]byte{[]byte("%PDF-")}
+	zipMagic  = [][]byte{{'P', 'K', 0x03, 0x04}}
+	oleMagic  = [][]byte{{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}} // xls 等旧版 Office 文件
+)
+
+// uploadRules 允许上传的文件类型，按扩展名区分
+var uploadRules = map[string]uploadRule{
+	".jpg":  {ContentType: "image/jpeg", MaxSize: 20 << 20, Magic: jpegMagic, Check: checkImage},
+	".jpeg": {ContentType: "image/jpeg", MaxSize: 20 << 20, Magic: jpegMagic, Check: checkImage},
+	".png":  {ContentType: "image/png", MaxSize: 20 << 20, Magic: pngMagic, Check: checkImage},
+	".gif":  {ContentType: "image/gif", MaxSize: 10 << 20, Magic: gifMagic, Check: checkImage},
+	".webp": {ContentType: "image/webp", MaxSize: 20 << 20, Check: checkImage},
+	// 系列 PDF 可能有几百 MB
+	".pdf":  {ContentType: "application/pdf", MaxSize: 1 << 30, Magic: pdfMagic, Check: checkPDF},
+	".xlsx": {ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", MaxSize: 50 << 20, Magic: zipMagic, Check: checkXLSX},
+	".xls":  {ContentType: "application/vnd.ms-excel", MaxSize: 50 << 20, Magic: oleMagic},
+}
+
+// 图片最多 5000 万像素，防止解码时占用过多内存
+const maxImagePixels = 50_000_000
+
+// PDF 中可能自动执行脚本或外部程序的动作
+var pdfActiveContent = [][]byte{[]byte("/JavaScript"), []byte("/JS"), []byte("/Launch")}
+
+// UploadValidationError 文件不符合上传要求，错误信息可以直接返回给前端
+type UploadValidationError struct {
+	Message string
+}
+
+func (e *UploadValidationError) Error() string {
+	return e.Message
+}
+
+func invalidUpload(format string, args ...interface{}) error {
+	return &UploadValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+// UploadContentType 扩展名对应的 Content-Type，不允许上传的类型返回 false
+func UploadContentType(ext string) (string, bool) {
+	rule, ok := uploadRules[strings.ToLower(ext)]
+	return rule.ContentType, ok
+}
+
+// UploadMaxSize 扩展名对应的文件大小上限
+func UploadMaxSize(ext string) int64 {
+	return uploadRules[strings.ToLower(ext)].MaxSize
+}
+
+// SanitizeFilename 去掉文件名中的目录、控制字符和特殊符号，只保留字母（含中文）、数字、- 和 _
+// 扩展名统一为小写，文件名中间的 . 会被替换，避免 x.php.jpg 这类双扩展名
+func SanitizeFilename(filename string) string {
+	filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	ext := strings.ToLower(path.Ext(filename))
+	base := strings.TrimSuffix(filename, path.Ext(filename))
+
+	var b strings.Builder
+	count := 0
+	for _, r := range base {
+		if count >= 80 {
+			break
+		}
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			b.WriteRune(r)
+		case !strings.HasSuffix(b.String(), "-"):
+			b.WriteRune('-')
+		default:
+			continue
+		}
+		count++
+	}
+
+	base = strings.Trim(b.String(), "-_")
+	if base == "" {
+		base = "file"
+	}
+
+	cleanExt := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.TrimPrefix(ext, "."))
+	if cleanExt == "" {
+		return base
+	}
+	return base + "." + cleanExt
+}
+
+// NewUploadKey 生成上传文件的对象路径，文件名经过清理
+func NewUploadKey(folder, filename string) string {
+	return fmt.Sprintf("%s/%d_%s", folder, time.Now().UnixNano(), SanitizeFilename(filename))
+}
+
+// ValidatedFile 通过校验的文件
+type ValidatedFile struct {
+	Filename    string // 清理后的文件名
+	Ext         string
+	ContentType string
+	Size        int64
+	SHA256      string
+}
+
+// ValidateUpload 按文件内容校验上传的文件：扩展名是否允许、大小是否超限、文件头与扩展名是否一致，
+// 并按类型检查图片尺寸、PDF 结构和 Excel 内容，最后计算 SHA-256
+// 不符合要求时返回 *UploadValidationError
+func ValidateUpload(filename string, src io.ReaderAt, size int64) (*ValidatedFile, error) {
+	ext := strings.ToLower(path.Ext(filename))
+	rule, ok := uploadRules[ext]
+	if !ok {
+		return nil, invalidUpload("不支持的文件类型 %s", ext)
+	}
+	if size <= 0 {
+		return nil, invalidUpload("文件为空")
+	}
+	if size > rule.MaxSize {
+		return nil, invalidUpload("文件不能超过 %dMB", rule.MaxSize>>20)
+	}
+
+	head := make([]byte, 16)
+	n, err := src.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	if !matchMagic(ext, rule, head[:n]) {
+		return nil, invalidUpload("文件内容与扩展名 %s 不符", ext)
+	}
+
+	if rule.Check != nil {
+		if err := rule.Check(src, size); err != nil {
+			return nil, err
+		}
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(src, 0, size)); err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+
+	return &ValidatedFile{
+		Filename:    SanitizeFilename(filename),
+		Ext:         ext,
+		ContentType: rule.ContentType,
+		Size:        size,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// matchMagic 检查文件头，WebP 的文件头为 RIFF????WEBP
+func matchMagic(ext string, rule uploadRule, head []byte) bool {
+	if ext == ".webp" {
+		return len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP"))
+	}
+	for _, magic := range rule.Magic {
+		if bytes.HasPrefix(head, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkImage 解析图片头部，拒绝无法解析或像素过多的图片
+func checkImage(src io.ReaderAt, size int64) error {
+	imageConfig, _, err := image.DecodeConfig(io.NewSectionReader(src, 0, size))
+	if err != nil {
+		return invalidUpload("图片已损坏或格式不正确")
+	}
+	if imageConfig.Width <= 0 || imageConfig.Height <= 0 {
+		return invalidUpload("图片尺寸无效")
+	}
+	if int64(imageConfig.Width)*int64(imageConfig.Height) > maxImagePixels {
+		return invalidUpload("图片尺寸过大（%dx%d）", imageConfig.Width, imageConfig.Height)
+	}
+	return nil
+}
+
+// checkPDF 检查 PDF 的结束标记和文档结构，并拒绝包含 JavaScript、启动外部程序等动作的文件
+// 压缩对象流中的动作无法通过字节扫描发现，这里只做基本防护
+func checkPDF(src io.ReaderAt, size int64) error {
+	tailSize := int64(1024)
+	if size < tailSize {
+		tailSize = size
+	}
+	tail := make([]byte, tailSize)
+	if _, err := src.ReadAt(tail, size-tailSize); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		return invalidUpload("PDF 文件不完整")
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	if err := api.Validate(io.NewSectionReader(src, 0, size), conf); err != nil {
+		log.Printf("PDF 结构校验失败: %v", err)
+		return invalidUpload("PDF 文件结构不正确")
+	}
+
+	if name, found := scanActiveContent(io.NewSectionReader(src, 0, size)); found {
+		return invalidUpload("PDF 包含不允许的动作 %s", name)
+	}
+	return nil
+}
+
+// scanActiveContent 分块扫描 PDF 中的动作名称，块之间保留重叠部分，避免名称被截断
+func scanActiveContent(r io.Reader) (string, bool) {
+	const chunkSize = 1 << 20
+	overlap := 0
+	for _, name := range pdfActiveContent {
+		if len(name) > overlap {
+			overlap = len(name)
+		}
+	}
+
+	buf := make([]byte, overlap+chunkSize)
+	kept := 0
+	for {
+		n, err := io.ReadFull(r, buf[kept:])
+		window := buf[:kept+n]
+		for _, name := range pdfActiveContent {
+			if containsPDFName(window, name) {
+				return string(name), true
+			}
+		}
+		if err != nil {
+			return "", false
+		}
+		kept = copy(buf, window[len(window)-overlap:])
+	}
+}
+
+// containsPDFName 查找 PDF 名称，名称后必须是分隔符，避免 /JS 匹配到 /JSomething
+func containsPDFName(data, name []byte) bool {
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], name)
+		if i < 0 {
+			return false
+		}
+		end := offset + i + len(name)
+		if end >= len(data) || isPDFDelimiter(data[end]) {
+			return true
+		}
+		offset = end
+	}
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte(" \t\r\n\f\x00()<>[]{}/%", c) >= 0
+}
+
+// checkXLSX 检查是否为 Excel 工作簿，并拒绝包含宏的文件
+func checkXLSX(src io.ReaderAt, size int64) error {
+	reader, err := zip.NewReader(src, size)
+	if err != nil {
+		return invalidUpload("Excel 文件已损坏")
+	}
+
+	hasWorkbook := false
+	for _, file := range reader.File {
+		name := strings.ToLower(file.Name)
+		if name == "xl/workbook.xml" {
+			hasWorkbook = true
+		}
+		if strings.HasSuffix(name, "vbaproject.bin") {
+			return invalidUpload("不允许上传包含宏的 Excel 文件")
+		}
+	}
+	if !hasWorkbook {
+		return invalidUpload("文件不是有效的 Excel 工作簿")
+	}
+	return nil
+}
+
+// StoredUpload 上传结果，Deduplicated 为 true 时返回的是之前上传过的相同文件
+type StoredUpload struct {
+	Key          string
+	Deduplicated bool
+	Image        *ProcessedImage // 图片的尺寸及各尺寸版本，其他文件为 nil
+}
+
+// StoreUpload 校验并上传表单文件，图片会去掉元数据并生成各尺寸
+// 相同内容（SHA-256）的文件已经上传过且仍然存在时直接返回已有文件
+func StoreUpload(file *multipart.FileHeader, folderName string, sysUserID int64) (*StoredUpload, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %v", err)
+	}
+	defer func() {
+		if err := src.Close(); err != nil {
+			log.Printf("关闭文件错误: %v", err)
+		}
+	}()
+
+	validated, err := ValidateUpload(file.Filename, src, file.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing, err := FindUploadByHash(validated.SHA256); err != nil {
+		return nil, err
+	} else if existing != nil {
+		stored := &StoredUpload{Key: existing.ObjectKey, Deduplicated: true}
+		if isImageExt(validated.Ext) {
+			if stored.Image, err = DescribeImage(existing.ObjectKey); err != nil {
+				return nil, err
+			}
+		}
+		return stored, nil
+	}
+
+	key := NewUploadKey(folderName, validated.Filename)
+	stored := &StoredUpload{Key: key}
+	if isImageExt(validated.Ext) {
+		data, err := io.ReadAll(io.NewSectionReader(src, 0, file.Size))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %v", err)
+		}
+		if stored.Image, err = processImage(data, key); err != nil {
+			return nil, err
+		}
+	} else if err := storage.Put(key, io.NewSectionReader(src, 0, file.Size), validated.ContentType); err != nil {
+		return nil, fmt.Errorf("failed to upload file: %v", err)
+	}
+
+	now := time.Now()
+	upload := models.Upload{
+		SysUserID:   sysUserID,
+		ObjectKey:   key,
+		Filename:    validated.Filename,
+		ContentType: validated.ContentType,
+		MaxSize:     UploadMaxSize(validated.Ext),
+		Size:        validated.Size,
+		SHA256:      validated.SHA256,
+		Status:      models.UploadStatusCompleted,
+		CompletedAt: &now,
+	}
+	if err := config.DB.Create(&upload).Error; err != nil {
+		// 记录失败只影响去重，文件已上传成功
+		log.Printf("保存上传记录 %s 失败: %v", key, err)
+	}
+
+	return stored, nil
+}
+
+// ValidateStoredObject 校验直传到存储中的文件，先下载到临时文件再按内容校验
+func ValidateStoredObject(key string) (*ValidatedFile, error) {
+	body, err := storage.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			log.Printf("关闭文件错误: %v", err)
+		}
+	}()
+
+	tmp, err := os.CreateTemp("", "upload-validate-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer func() {
+		tmp.Close()
+		if err := os.Remove(tmp.Name()); err != nil {
+			log.Printf("删除临时文件错误: %v", err)
+		}
+	}()
+
+	// 多读 1 字节即可判断是否超过大小上限
+	size, err := io.Copy(tmp, io.LimitReader(body, UploadMaxSize(path.Ext(key))+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %v", err)
+	}
+
+	return ValidateUpload(path.Base(key), tmp, size)
+}
+
+// FindUploadByHash 查找内容相同且仍然存在的已上传文件，没有时返回 nil
+// 文件可能已被孤立文件回收删除，因此需要确认存储中仍然存在。找到时更新 last_used_at，
+// 返回的文件可能暂时没有被引用，孤立文件回收在 assetGCMinAge 内不会删除它
+func FindUploadByHash(sha256Hex string) (*models.Upload, error) {
+	var uploads []models.Upload
+	if err := config.DB.Where("sha256 = ? AND status = ?", sha256Hex, models.UploadStatusCompleted).
+		Order("id DESC").Find(&uploads).Error; err != nil {
+		return nil, fmt.Errorf("failed to find upload: %v", err)
+	}
+
+	for i := range uploads {
+		_, err := storage.Stat(uploads[i].ObjectKey)
+		if err == nil {
+			now := time.Now()
+			if err := config.DB.Model(&uploads[i]).Update("last_used_at", now).Error; err != nil {
+				return nil, fmt.Errorf("failed to update upload: %v", err)
+			}
+			uploads[i].LastUsedAt = &now
+			return &uploads[i], nil
+		}
+		if !errors.Is(err, ErrObjectNotFound) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// isImageExt 是否为会经过图片处理流程的扩展名
+func isImageExt(ext string) bool {
+	return strings.HasPrefix(uploadRules[strings.ToLower(ext)].ContentType, "image/")
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// testPNG 生成一张纯色 PNG 图片
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// testJPEG 生成一张纯色 JPEG 图片
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// testGIFHeader 只有文件头和逻辑屏幕尺寸的 GIF，用于测试尺寸限制而不需要真正生成大图
+func testGIFHeader(width, height uint16) []byte {
+	data := []byte("GIF89a")
+	data = binary.LittleEndian.AppendUint16(data, width)
+	data = binary.LittleEndian.AppendUint16(data, height)
+	return append(data, 0x00, 0x00, 0x00)
+}
+
+// testPDF 生成一页的 PDF，script 不为空时加入文档级 JavaScript
+func testPDF(t *testing.T, script string) []byte {
+	t.Helper()
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.Cell(40, 10, "catalog")
+	if script != "" {
+		pdf.SetJavascript(script)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatalf("generate pdf: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// isUploadValidationError 是否为可以直接返回给前端的校验错误
+func isUploadValidationError(err error) bool {
+	var validationErr *UploadValidationError
+	return errors.As(err, &validationErr)
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{"扩展名转小写", "photo.JPG", "photo.jpg"},
+		{"去掉目录", "../../etc/passwd", "passwd"},
+		{"去掉 Windows 目录并替换空格", `C:\Users\a\报告 2024.pdf`, "报告-2024.pdf"},
+		{"替换双扩展名", "x.php.jpg", "x-php.jpg"},
+		{"连续的特殊符号只保留一个 -", "a  &&  b.png", "a-b.png"},
+		{"只有特殊符号时使用 file", "...png", "file.png"},
+		{"空文件名", "", "file"},
+		{"扩展名中的特殊符号被去掉", "a.p%g", "a.pg"},
+		{"文件名最多 80 个字符", strings.Repeat("a", 100) + ".png", strings.Repeat("a", 80) + ".png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFilename(tt.filename); got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateUpload(t *testing.T) {
+	pngData := testPNG(t, 2, 2)
+
+	invalid := []struct {
+		name     string
+		filename string
+		data     []byte
+		size     int64
+	}{
+		{"不支持的扩展名", "a.exe", []byte("MZ"), 2},
+		{"空文件", "a.png", nil, 0},
+		{"超过大小上限", "a.gif", testGIFHeader(1, 1), 11 << 20},
+		{"文件头与扩展名不符", "a.png", testJPEG(t), 0},
+		{"WebP 文件头不正确", "a.webp", []byte("RIFF0000WAVEfmt "), 0},
+		{"图片内容损坏", "a.png", append(append([]byte{}, pngData[:16]...), "broken"...), 0},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == 0 {
+				size = int64(len(tt.data))
+			}
+			_, err := ValidateUpload(tt.filename, bytes.NewReader(tt.data), size)
+			if !isUploadValidationError(err) {
+				t.Errorf("ValidateUpload(%q) error = %v, want *UploadValidationError", tt.filename, err)
+			}
+		})
+	}
+
+	t.Run("合法图片", func(t *testing.T) {
+		validated, err := ValidateUpload("My Photo.PNG", bytes.NewReader(pngData), int64(len(pngData)))
+		if err != nil {
+			t.Fatalf("ValidateUpload() error = %v", err)
+		}
+		sum := sha256.Sum256(pngData)
+		want := ValidatedFile{
+			Filename:    "My-Photo.png",
+			Ext:         ".png",
+			ContentType: "image/png",
+			Size:        int64(len(pngData)),
+			SHA256:      hex.EncodeToString(sum[:]),
+		}
+		if *validated != want {
+			t.Errorf("ValidateUpload() = %+v, want %+v", *validated, want)
+		}
+	})
+}
+
+func TestCheckImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"PNG", testPNG(t, 3, 2), false},
+		{"JPEG", testJPEG(t), false},
+		{"无法解析", []byte("not an image"), true},
+		{"尺寸为 0", testGIFHeader(0, 10), true},
+		{"像素过多", testGIFHeader(10000, 10000), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkImage(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if tt.wantErr && !isUploadValidationError(err) {
+				t.Errorf("checkImage() error = %v, want *UploadValidationError", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("checkImage() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestCheckPDF(t *testing.T) {
+	valid := testPDF(t, "")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string // 为空时不应返回错误
+	}{
+		{"合法 PDF", valid, ""},
+		{"缺少结束标记", valid[:len(valid)/2], "PDF 文件不完整"},
+		{"结构损坏", []byte("%PDF-1.4\nnot a pdf\n%%EOF\n"), "PDF 文件结构不正确"},
+		{"包含 JavaScript", testPDF(t, "app.alert('hi');"), "PDF 包含不允许的动作"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPDF(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkPDF() error = %v, want nil", err)
+				}
+				return
+			}
+			if !isUploadValidationError(err) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkPDF() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestContainsPDFName(t *testing.T) {
+	tests := []struct {
+		data string
+		name string
+		want bool
+	}{
+		{"<< /S /JavaScript /JS (x) >>", "/JavaScript", true},
+		{"<< /JS(x) >>", "/JS", true},
+		{"<< /JSomething 1 >>", "/JS", false},
+		{"<< /JSomething 1 /JS[1] >>", "/JS", true},
+		{"<< /Launch", "/Launch", true},
+		{"<< /Type /Page >>", "/JS", false},
+	}
+
+	for _, tt := range tests {
+		if got := containsPDFName([]byte(tt.data), []byte(tt.name)); got != tt.want {
+			t.Errorf("containsPDFName(%q, %q) = %v, want %v", tt.data, tt.name, got, tt.want)
+		}
+	}
+}
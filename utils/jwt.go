@@ -2,44 +2,116 @@
 package utils
 
 import (
+	"errors"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // var jwtKey = []byte("your_secret_key") // 应该从配置文件或环境变量中读取
 var jwtKey = []byte(os.Getenv("JWT_SECRET"))
 
+const (
+	AccessTokenLifetime  = 15 * time.Minute   // 访问 Token 有效期
+	RefreshTokenLifetime = 7 * 24 * time.Hour // 刷新 Token 有效期，也是所有 Token 的最长有效期
+)
+
+// Token 所属用户类型
+const (
+	UserTypeSysUser = "sys_user" // 后台用户
+	UserTypeUser    = "user"     // 前台用户
+)
+
+// Token 类型
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// Token 受众，后台和前台的 Token 不能混用
+const (
+	AudienceAdmin = "admin"
+	AudienceFront = "front"
+)
+
+var (
+	ErrTokenRevoked      = errors.New("token has been revoked")       // Token 已被注销
+	ErrTokenTypeMismatch = errors.New("token type mismatch")          // Token 类型或受众不匹配
+	ErrUnknownUserType   = errors.New("unknown token user type")      // 未知的用户类型
+	ErrRefreshTokenReuse = errors.New("refresh token reuse detected") // 刷新 Token 被重复使用
+	ErrUserDisabled      = errors.New("account is disabled")          // 账号已禁用或不存在
+)
+
 // Claims 定义JWT的声明
 type Claims struct {
-	UserID uint `json:"userID"`
+	UserID    uint   `json:"userID"`
+	UserType  string `json:"userType"`
+	TokenType string `json:"tokenType"`
+	FamilyID  string `json:"fid"` // 同一次登录签发的 Token 共享同一个 FamilyID
+	Version   int64  `json:"tv"`  // 签发时用户的 Token 版本，注销用户全部会话后版本加一
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT 生成JWT Token
-func GenerateJWT(userID uint) (string, error) {
-	expirationTime := time.Now().Add(72 * time.Hour) // Token 有效期为72小时
+// TokenPair 登录或刷新后返回给客户端的 Token
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问 Token 剩余秒数
+}
+
+// audienceFor 根据用户类型获取 Token 受众
+func audienceFor(userType string) (string, error) {
+	switch userType {
+	case UserTypeSysUser:
+		return AudienceAdmin, nil
+	case UserTypeUser:
+		return AudienceFront, nil
+	default:
+		return "", ErrUnknownUserType
+	}
+}
+
+// generateJWT 生成JWT Token，返回 Token 字符串和声明
+func generateJWT(userID uint, userType, tokenType, familyID string, version int64, lifetime time.Duration) (string, *Claims, error) {
+	audience, err := audienceFor(userType)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		UserType:  userType,
+		TokenType: tokenType,
+		FamilyID:  familyID,
+		Version:   version,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(), // jti，用于注销单个 Token
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(lifetime)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(jwtKey)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return tokenString, nil
+	return tokenString, claims, nil
 }
 
-// ValidateJWT 验证并解析JWT Token
-func ValidateJWT(tokenStr string) (*Claims, error) {
+// ValidateJWT 验证并解析JWT Token，同时校验用户类型、受众和 Token 类型
+func ValidateJWT(tokenStr, userType, tokenType string) (*Claims, error) {
+	audience, err := audienceFor(userType)
+	if err != nil {
+		return nil, err
+	}
+
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
@@ -47,7 +119,7 @@ func ValidateJWT(tokenStr string) (*Claims, error) {
 			return nil, jwt.ErrSignatureInvalid
 		}
 		return jwtKey, nil
-	})
+	}, jwt.WithAudience(audience))
 
 	if err != nil {
 		return nil, err
@@ -57,5 +129,18 @@ func ValidateJWT(tokenStr string) (*Claims, error) {
 		return nil, jwt.ErrTokenInvalidId
 	}
 
+	if claims.UserType != userType || claims.TokenType != tokenType {
+		return nil, ErrTokenTypeMismatch
+	}
+
+	// 检查 Token 是否已被注销
+	revoked, err := IsTokenRevoked(claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
 	return claims, nil
 }
@@ -0,0 +1,150 @@
+// refresh_token.go
+package utils
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IssueTokenPair 登录成功后签发访问 Token 和刷新 Token，开启新的 Token 家族
+func IssueTokenPair(userID uint, userType string) (*TokenPair, error) {
+	pair, _, err := issueTokenPair(config.DB, userID, userType, uuid.New().String())
+	return pair, err
+}
+
+// issueTokenPair 在指定家族下签发一对 Token，并持久化刷新 Token，同时返回刷新 Token 的声明
+func issueTokenPair(db *gorm.DB, userID uint, userType, familyID string) (*TokenPair, *Claims, error) {
+	version, err := currentTokenVersion(db, userType, int64(userID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accessToken, _, err := generateJWT(userID, userType, TokenTypeAccess, familyID, version, AccessTokenLifetime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, refreshClaims, err := generateJWT(userID, userType, TokenTypeRefresh, familyID, version, RefreshTokenLifetime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := models.RefreshToken{
+		Jti:       refreshClaims.ID,
+		FamilyID:  familyID,
+		UserType:  userType,
+		UserID:    int64(userID),
+		ExpiresAt: refreshClaims.ExpiresAt.Time,
+		CreatedAt: time.Now(),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(AccessTokenLifetime / time.Second),
+	}, refreshClaims, nil
+}
+
+// RotateRefreshToken 使用刷新 Token 换取新的 Token，旧刷新 Token 立即失效
+// 已使用过的刷新 Token 再次出现时视为泄露，注销整个 Token 家族
+func RotateRefreshToken(refreshTokenStr, userType string) (*TokenPair, error) {
+	claims, err := ValidateJWT(refreshTokenStr, userType, TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	// 禁用的账号不能刷新 Token，已签发的访问 Token 过期后即无法继续使用
+	if err := checkUserActive(config.DB, userType, claims.UserID); err != nil {
+		return nil, err
+	}
+
+	tx := config.DB.Begin()
+
+	var record models.RefreshToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("jti = ?", claims.ID).First(&record).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTokenRevoked
+		}
+		return nil, err
+	}
+
+	if record.RevokedAt != nil {
+		tx.Rollback()
+		return nil, ErrTokenRevoked
+	}
+
+	if record.UsedAt != nil {
+		tx.Rollback()
+		log.Printf("检测到刷新 Token 重复使用，注销 Token 家族 %s（%s %d）", record.FamilyID, record.UserType, record.UserID)
+		if err := RevokeTokenFamily(record.FamilyID); err != nil {
+			log.Printf("注销 Token 家族 %s 失败: %v", record.FamilyID, err)
+		}
+		return nil, ErrRefreshTokenReuse
+	}
+
+	pair, newClaims, err := issueTokenPair(tx, claims.UserID, userType, record.FamilyID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// 标记旧 Token 已使用，并记录被哪个新 Token 替换，便于追踪
+	if err := tx.Model(&record).Updates(map[string]interface{}{
+		"used_at":     time.Now(),
+		"replaced_by": newClaims.ID,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// checkUserActive 检查账号是否存在且已启用（status = 1）
+func checkUserActive(db *gorm.DB, userType string, userID uint) error {
+	var model interface{}
+	switch userType {
+	case UserTypeSysUser:
+		model = &models.SysUser{}
+	case UserTypeUser:
+		model = &models.User{}
+	default:
+		return ErrUnknownUserType
+	}
+
+	var status int64
+	if err := db.Model(model).Where("id = ?", userID).Select("status").Scan(&status).Error; err != nil {
+		return err
+	}
+	if status != 1 {
+		return ErrUserDisabled
+	}
+	return nil
+}
+
+// RevokeTokenFamily 注销同一次登录签发的所有刷新 Token
+func RevokeTokenFamily(familyID string) error {
+	if familyID == "" {
+		return nil
+	}
+
+	return config.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -35,3 +35,18 @@ func ErrorResponse(c *gin.Context, message string, status int, errors ...interfa
 
 	c.JSON(status, response)
 }
+
+// ErrorResponseWithResult 用于返回失败但需要附带数据的响应，例如导入失败时的逐行校验结果
+func ErrorResponseWithResult(c *gin.Context, message string, status int, data interface{}) {
+	response := gin.H{
+		"code":    0,
+		"message": message,
+	}
+
+	// 如果有数据，添加到响应中
+	if data != nil {
+		response["result"] = data
+	}
+
+	c.JSON(status, response)
+}
@@ -0,0 +1,120 @@
+// token_revocation.go
+package utils
+
+import (
+	"errors"
+	"exam_server/config"
+	"exam_server/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IsTokenRevoked 检查 Token 是否已被单独注销，或所属用户的全部会话已被注销
+func IsTokenRevoked(claims *Claims) (bool, error) {
+	// 没有 jti 的旧 Token 无法注销，直接视为失效
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return true, nil
+	}
+
+	var count int64
+	if err := config.DB.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	var revocation models.UserTokenRevocation
+	err := config.DB.Where("user_type = ? AND user_id = ?", claims.UserType, claims.UserID).First(&revocation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Token 签发时间只精确到秒，用版本号判断，同一秒内注销前后签发的 Token 也能区分
+	return claims.Version < revocation.TokenVersion, nil
+}
+
+// currentTokenVersion 查询用户当前的 Token 版本，从未注销过全部会话时为 0
+func currentTokenVersion(db *gorm.DB, userType string, userID int64) (int64, error) {
+	var version int64
+	err := db.Model(&models.UserTokenRevocation{}).
+		Where("user_type = ? AND user_id = ?", userType, userID).
+		Select("token_version").Scan(&version).Error
+	return version, err
+}
+
+// RevokeToken 注销当前访问 Token 及同一次登录签发的刷新 Token（退出登录）
+func RevokeToken(claims *Claims) error {
+	expiresAt := time.Now().Add(AccessTokenLifetime)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	record := models.RevokedToken{
+		Jti:       claims.ID,
+		UserType:  claims.UserType,
+		UserID:    int64(claims.UserID),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	// 重复退出时忽略主键冲突
+	if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+		return err
+	}
+
+	return RevokeTokenFamily(claims.FamilyID)
+}
+
+// RevokeAllUserTokens 注销用户当前所有会话，用于禁用、删除用户或重置密码
+func RevokeAllUserTokens(userType string, userID int64) error {
+	// 版本加一后，之前签发的 Token 版本都小于当前版本
+	now := time.Now()
+	record := models.UserTokenRevocation{
+		UserType:      userType,
+		UserID:        userID,
+		TokenVersion:  1,
+		RevokedBefore: now,
+		UpdatedAt:     now,
+	}
+
+	return config.DB.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"token_version":  gorm.Expr("token_version + 1"),
+			"revoked_before": now,
+			"updated_at":     now,
+		}),
+	}).Create(&record).Error
+}
+
+// CleanupRevokedTokens 清理已过期、不再需要保留的注销记录和刷新 Token
+func CleanupRevokedTokens() error {
+	now := time.Now()
+
+	if err := config.DB.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	// 用户级别的注销记录保存着 Token 版本，删除后版本会重新从 0 开始，因此不清理
+	return config.DB.Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error
+}
+
+// StartRevokedTokenCleanup 定时清理注销记录
+func StartRevokedTokenCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := CleanupRevokedTokens(); err != nil {
+				log.Printf("清理已注销的 Token 失败: %v", err)
+			}
+		}
+	}()
+}